@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime falls back to the modification time where the access time
+// isn't exposed.
+func accessTime(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// tempPrefixLength is the number of random characters in a temporary name.
+const tempPrefixLength = 8
+
+// isSymlink reports whether path exists and is a symbolic link.
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+
+	return err == nil && info.Mode()&fs.ModeSymlink != 0
+}
+
+// tempPath returns a hidden, randomly named path in the directory of dest,
+// so that renaming it onto dest stays within one filesystem.
+func tempPath(dest string) string {
+	return tempPathIn(filepath.Dir(dest), dest)
+}
+
+// tempPathIn returns a hidden, randomly named path for dest in dir.
+func tempPathIn(dir, dest string) string {
+	name := "." + filepath.Base(dest) + ".cp-" + rand.Text()[:tempPrefixLength]
+
+	return filepath.Join(dir, name)
+}
+
+// replaceAtomically calls write with a temporary path next to dest, or in
+// --temp-dir if given, and then renames the result over dest. Readers of dest
+// see either the old entry or the complete new file; a symlink at dest is
+// replaced rather than followed. The temporary file is removed if write or
+// the rename fails. A file written to a --temp-dir on another filesystem
+// can't be renamed, so it is copied next to dest first.
+func replaceAtomically(opts *options, dest string, write func(temp string) error) error {
+	if opts.tempDir == "" {
+		return renameOver(tempPath(dest), dest, write)
+	}
+
+	if opts.sameDevice(opts.tempDir, filepath.Dir(dest)) {
+		return renameOver(tempPathIn(opts.tempDir, dest), dest, write)
+	}
+
+	staged := tempPathIn(opts.tempDir, dest)
+	defer os.Remove(staged)
+
+	if err := write(staged); err != nil {
+		return err
+	}
+
+	return renameOver(tempPath(dest), dest, func(temp string) error {
+		return copyStaged(staged, temp)
+	})
+}
+
+// replaceVerified calls write with a temporary path for a copy to dest that
+// must be verified before replacing it, as replaceAtomically does, but
+// otherwise treats dest as a plain copy would: a symlink at dest is followed
+// unless --no-dereference-dest is given, and the file replaced keeps its
+// mode. A file with other hard links is overwritten with the verified
+// temporary file instead, since renaming over it would detach it from them.
+// A symlink to nothing is refused rather than replaced.
+func replaceVerified(opts *options, dest string, write func(path string) error) error {
+	target := dest
+
+	if !opts.noDereferenceDest {
+		resolved, err := filepath.EvalSymlinks(dest)
+
+		switch {
+		case err == nil:
+			target = resolved
+		case isSymlink(dest):
+			return fmt.Errorf("destination '%s' is a dangling symlink", dest) //nolint:err113
+		}
+	}
+
+	existing, err := os.Lstat(target)
+	if err != nil || !existing.Mode().IsRegular() {
+		return replaceAtomically(opts, target, write)
+	}
+
+	if _, linked := hardLinkKey(existing); linked {
+		return overwriteVerified(opts, target, write)
+	}
+
+	return replaceAtomically(opts, target, func(temp string) error {
+		if err := write(temp); err != nil {
+			return err
+		}
+
+		if err := os.Chmod(temp, existing.Mode().Perm()); err != nil {
+			return fmt.Errorf("setting temporary file mode: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// overwriteVerified calls write with a temporary path for a copy to dest,
+// next to dest or in --temp-dir, and then copies the result into dest
+// itself, keeping its identity.
+func overwriteVerified(opts *options, dest string, write func(path string) error) error {
+	dir := filepath.Dir(dest)
+	if opts.tempDir != "" {
+		dir = opts.tempDir
+	}
+
+	staged := tempPathIn(dir, dest)
+	defer os.Remove(staged)
+
+	if err := write(staged); err != nil {
+		return err
+	}
+
+	return writeStaged(staged, dest, os.O_WRONLY|os.O_TRUNC)
+}
+
+// renameOver calls write with temp and renames the result over dest,
+// removing temp if either fails.
+func renameOver(temp, dest string, write func(temp string) error) error {
+	if err := write(temp); err != nil {
+		os.Remove(temp)
+
+		return err
+	}
+
+	if err := os.Rename(temp, dest); err != nil {
+		os.Remove(temp)
+
+		return fmt.Errorf("replacing destination file: %w", err)
+	}
+
+	return nil
+}
+
+// sameDevice reports whether the directories dir and other may lie on the
+// same filesystem, so that files can be renamed from one to the other. It
+// only reports false when both devices are known and differ.
+func (opts *options) sameDevice(dir, other string) bool {
+	dirInfo, err := os.Stat(dir)
+	if err != nil {
+		return true
+	}
+
+	otherInfo, err := os.Stat(other)
+	if err != nil {
+		return true
+	}
+
+	dirDevice, dirOK := opts.fileDevice(dirInfo)
+	otherDevice, otherOK := opts.fileDevice(otherInfo)
+
+	return !dirOK || !otherOK || dirDevice == otherDevice
+}
+
+// copyStaged copies the file staged in a --temp-dir on another filesystem to
+// temp, keeping its permissions.
+func copyStaged(staged, temp string) error {
+	return writeStaged(staged, temp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+}
+
+// writeStaged copies a staged file to path, opened with flag and given the
+// staged file's permissions if created.
+func writeStaged(staged, path string, flag int) error {
+	source, err := os.Open(staged)
+	if err != nil {
+		return fmt.Errorf("opening staged file: %w", err)
+	}
+
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("getting staged file info: %w", err)
+	}
+
+	file, err := os.OpenFile(path, flag, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("creating destination file: %w", err)
+	}
+
+	defer file.Close()
+
+	if _, err := io.Copy(file, source); err != nil {
+		return fmt.Errorf("copying staged file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("closing destination file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	// newContent is what a test copies over an existing destination.
+	newContent = "new content"
+	// oldContent is what the destination held before it was replaced.
+	oldContent = "old content"
+)
+
+// setupDestSymlink creates a source file and a destination symlink pointing
+// at a separate target file, returning the source, link and target paths.
+func setupDestSymlink(t *testing.T) (string, string, string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	targetFile := filepath.Join(tmpDir, "target.txt")
+	linkFile := filepath.Join(tmpDir, "link.txt")
+
+	if err := os.WriteFile(sourceFile, []byte(newContent), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(targetFile, []byte(oldContent), 0o600); err != nil {
+		t.Fatalf("failed to create target file: %v", err)
+	}
+
+	if err := os.Symlink(targetFile, linkFile); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	return sourceFile, linkFile, targetFile
+}
+
+// TestCopyFile_DereferenceDest tests that by default the bytes are written
+// through a destination symlink into the file it points to.
+func TestCopyFile_DereferenceDest(t *testing.T) {
+	t.Parallel()
+
+	// Setup: Destination is a symlink to another file
+	sourceFile, linkFile, targetFile := setupDestSymlink(t)
+
+	// Test: Copy onto the symlink
+	if _, err := copyFile(t.Context(), new(options), sourceFile, linkFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The link is kept and its target holds the new content
+	info, err := os.Lstat(linkFile)
+	if err != nil {
+		t.Fatalf("failed to stat destination: %v", err)
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected destination to remain a symlink, got mode %v", info.Mode())
+	}
+
+	got, err := os.ReadFile(targetFile)
+	if err != nil {
+		t.Fatalf("failed to read target file: %v", err)
+	}
+
+	if string(got) != newContent {
+		t.Errorf("target content = %q, want %q", got, newContent)
+	}
+}
+
+// TestCopyFile_NoDereferenceDest tests that --no-dereference-dest replaces the
+// destination symlink with a regular file and leaves its target untouched.
+func TestCopyFile_NoDereferenceDest(t *testing.T) {
+	t.Parallel()
+
+	// Setup: Destination is a symlink to another file
+	sourceFile, linkFile, targetFile := setupDestSymlink(t)
+
+	// Test: Copy onto the symlink without dereferencing it
+	opts := new(options)
+	opts.noDereferenceDest = true
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, linkFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The link became a regular file with the new content
+	info, err := os.Lstat(linkFile)
+	if err != nil {
+		t.Fatalf("failed to stat destination: %v", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		t.Errorf("expected destination to be a regular file, got mode %v", info.Mode())
+	}
+
+	got, err := os.ReadFile(linkFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if string(got) != newContent {
+		t.Errorf("destination content = %q, want %q", got, newContent)
+	}
+
+	// Verify: The former target is unchanged and no temporary file is left
+	got, err = os.ReadFile(targetFile)
+	if err != nil {
+		t.Fatalf("failed to read target file: %v", err)
+	}
+
+	if string(got) != oldContent {
+		t.Errorf("target content = %q, want %q", got, oldContent)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(linkFile))
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Errorf("expected 3 entries after copy, got %d", len(entries))
+	}
+}
+
+// TestCopyFile_TempDir tests that --temp-dir stages an atomic replacement in
+// the given directory, whether it shares the destination's filesystem or is
+// on another one, simulated with a device hook, and cleans up after itself.
+func TestCopyFile_TempDir(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		crossDevice bool
+	}{
+		{name: "same filesystem", crossDevice: false},
+		{name: "other filesystem", crossDevice: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Setup: Destination is a symlink, and a separate staging directory
+			sourceFile, linkFile, _ := setupDestSymlink(t)
+			tempDir := t.TempDir()
+
+			opts := new(options)
+			opts.noDereferenceDest = true
+			opts.tempDir = tempDir
+
+			if tt.crossDevice {
+				opts.device = func(info fs.FileInfo) (uint64, bool) {
+					if info.Name() == filepath.Base(tempDir) {
+						return 2, true
+					}
+
+					return 1, true
+				}
+			}
+
+			// Test: Replace the symlink staging through the temp dir
+			if _, err := copyFile(t.Context(), opts, sourceFile, linkFile); err != nil {
+				t.Fatalf("copyFile() failed: %v", err)
+			}
+
+			// Verify: The destination holds the copy and no staged file is left
+			checkRegularFile(t, linkFile, newContent)
+			checkNoStagedFiles(t, tempDir, filepath.Dir(linkFile))
+		})
+	}
+}
+
+// checkNoStagedFiles checks that no temporary file of a copy is left in
+// dirs.
+func checkNoStagedFiles(t *testing.T, dirs ...string) {
+	t.Helper()
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", dir, err)
+		}
+
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), ".cp-") {
+				t.Errorf("temporary file left behind: %s", filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+}
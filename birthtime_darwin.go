@@ -0,0 +1,72 @@
+//go:build darwin
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// attrBitMapCount is ATTR_BIT_MAP_COUNT from <sys/attr.h>.
+	attrBitMapCount = 5
+	// attrCmnCrtime is ATTR_CMN_CRTIME from <sys/attr.h>.
+	attrCmnCrtime = 0x00000200
+)
+
+// attrList mirrors struct attrlist from <sys/attr.h>.
+type attrList struct {
+	bitmapCount uint16
+	reserved    uint16
+	commonAttr  uint32
+	volAttr     uint32
+	dirAttr     uint32
+	fileAttr    uint32
+	forkAttr    uint32
+}
+
+// birthTime returns the birth time recorded in info.
+func birthTime(info fs.FileInfo) (time.Time, bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Birthtimespec.Unix()), true
+	}
+
+	return time.Time{}, false
+}
+
+// setBirthTime sets the birth time of path with setattrlist(2).
+func setBirthTime(path string, birth time.Time) error {
+	name, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return fmt.Errorf("encoding path: %w", err)
+	}
+
+	attrs := attrList{
+		bitmapCount: attrBitMapCount,
+		reserved:    0,
+		commonAttr:  attrCmnCrtime,
+		volAttr:     0,
+		dirAttr:     0,
+		fileAttr:    0,
+		forkAttr:    0,
+	}
+	created := syscall.NsecToTimespec(birth.UnixNano())
+
+	_, _, errno := syscall.Syscall6(
+		syscall.SYS_SETATTRLIST,
+		uintptr(unsafe.Pointer(name)),
+		uintptr(unsafe.Pointer(&attrs)),
+		uintptr(unsafe.Pointer(&created)),
+		unsafe.Sizeof(created),
+		0,
+		0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("setattrlist: %w", errno)
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"io/fs"
+	"time"
+)
+
+// birthTime reports that birth times can't be carried over on this platform.
+func birthTime(fs.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// setBirthTime reports that birth times can't be set on this platform.
+func setBirthTime(string, time.Time) error {
+	return errBirthTimeUnsupported
+}
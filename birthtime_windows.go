@@ -0,0 +1,52 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileWriteAttributes is the FILE_WRITE_ATTRIBUTES access right.
+const fileWriteAttributes = 0x0100
+
+// birthTime returns the creation time recorded in info.
+func birthTime(info fs.FileInfo) (time.Time, bool) {
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, data.CreationTime.Nanoseconds()), true
+	}
+
+	return time.Time{}, false
+}
+
+// setBirthTime sets the creation time of path with SetFileTime.
+func setBirthTime(path string, birth time.Time) error {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return fmt.Errorf("encoding path: %w", err)
+	}
+
+	handle, err := syscall.CreateFile(
+		name,
+		fileWriteAttributes,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS,
+		0,
+	)
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+
+	defer syscall.CloseHandle(handle)
+
+	created := syscall.NsecToFiletime(birth.UnixNano())
+	if err := syscall.SetFileTime(handle, &created, nil, nil); err != nil {
+		return fmt.Errorf("setting creation time: %w", err)
+	}
+
+	return nil
+}
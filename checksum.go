@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/md5"  //nolint:gosec
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// defaultChecksum is the algorithm used when --checksum is not given.
+const defaultChecksum = "sha256"
+
+// errVerifyMismatch is returned by --verify when a copy doesn't match its
+// source.
+var errVerifyMismatch = errors.New("verification failed: checksum mismatch")
+
+// checksumAlgorithm pairs an algorithm name with its hash constructor.
+// Only seeded algorithms use the --checksum-seed passed to new.
+type checksumAlgorithm struct {
+	name string
+	new  func(seed uint64) hash.Hash
+}
+
+// checksumAlgorithms returns the supported checksum algorithms.
+func checksumAlgorithms() []checksumAlgorithm {
+	return []checksumAlgorithm{
+		// A checksum detects corruption, not tampering, so the weak
+		// algorithms are fine.
+		{name: "md5", new: func(uint64) hash.Hash { return md5.New() }},   //nolint:gosec
+		{name: "sha1", new: func(uint64) hash.Hash { return sha1.New() }}, //nolint:gosec
+		{name: "sha256", new: func(uint64) hash.Hash { return sha256.New() }},
+		{name: "crc32", new: func(uint64) hash.Hash { return crc32.NewIEEE() }},
+		{name: "xxhash", new: func(seed uint64) hash.Hash { return newXXHash64(seed) }},
+	}
+}
+
+// newHash builds the hash for the named checksum algorithm, starting from
+// seed where the algorithm takes one.
+func newHash(algo string, seed uint64) (hash.Hash, error) {
+	for _, alg := range checksumAlgorithms() {
+		if alg.name == algo {
+			return alg.new(seed), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown checksum algorithm '%s'", algo) //nolint:err113
+}
+
+// hashFile returns the hex-encoded checksum of the file at path.
+func hashFile(algo string, seed uint64, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening file for checksum: %w", err)
+	}
+
+	defer file.Close()
+
+	return hashReader(algo, seed, file)
+}
+
+// hashReader returns the hex-encoded checksum of everything read from reader.
+func hashReader(algo string, seed uint64, reader io.Reader) (string, error) {
+	hasher, err := newHash(algo, seed)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", fmt.Errorf("reading file for checksum: %w", err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// printChecksumAlgorithms implements --list-checksums, writing the name of
+// every supported algorithm to out, one per line.
+func printChecksumAlgorithms(out io.Writer) error {
+	for _, alg := range checksumAlgorithms() {
+		if _, err := fmt.Fprintln(out, alg.name); err != nil {
+			return fmt.Errorf("writing checksum algorithms: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// printChecksum implements --checksum-only, writing the checksum of the file
+// at path to out in the format of sha256sum and friends.
+func printChecksum(out io.Writer, algo string, seed uint64, path string) error {
+	sum, err := hashFile(algo, seed, path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(out, "%s  %s\n", sum, path); err != nil {
+		return fmt.Errorf("writing checksum: %w", err)
+	}
+
+	return nil
+}
+
+// checkDestChecksum implements --verify-before-overwrite, refusing to
+// replace an existing dest whose checksum isn't the one the user expects.
+func checkDestChecksum(opts *options, dest string) error {
+	sum, err := hashFile(opts.checksum, opts.checksumSeed, dest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(sum, opts.expectDestSum) {
+		return fmt.Errorf( //nolint:err113
+			"destination '%s' has changed: %s checksum is %s, expected %s",
+			dest, opts.checksum, sum, opts.expectDestSum,
+		)
+	}
+
+	return nil
+}
+
+// verifyCopy re-reads source and checks that its checksum matches that of
+// dest, which it returns. The source's checksum may come from
+// --checksum-cache instead. The checksum of dest is taken from written, which
+// hashed the bytes as they were written, or under --verify=strict by reading
+// dest back.
+func verifyCopy(opts *options, source, dest string, written hash.Hash) (string, error) {
+	sourceSum, err := opts.sourceChecksum(source)
+	if err != nil {
+		return "", err
+	}
+
+	var destSum string
+
+	if written != nil {
+		destSum = hex.EncodeToString(written.Sum(nil))
+	} else if destSum, err = hashFile(opts.checksum, opts.checksumSeed, dest); err != nil {
+		return "", err
+	}
+
+	if sourceSum != destSum {
+		return "", errVerifyMismatch
+	}
+
+	return destSum, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestCopyFile_VerifyChecksums tests that --verify passes for every algorithm.
+func TestCopyFile_VerifyChecksums(t *testing.T) {
+	t.Parallel()
+
+	for _, algo := range []string{"md5", "sha1", "sha256", "crc32"} {
+		t.Run(algo, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "source.txt")
+			destFile := filepath.Join(tmpDir, "dest.txt")
+
+			// Setup: Create source file
+			if err := os.WriteFile(sourceFile, []byte("verify me"), 0o600); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			// Test: Copy with verification
+			opts, err := parseArgs([]string{"--verify", "--checksum=" + algo, sourceFile, destFile})
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			// Verify: Copy and verification succeed
+			if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+				t.Errorf("copyFile() failed: %v", err)
+			}
+		})
+	}
+}
+
+// TestParseArgs_UnknownChecksum tests that unknown algorithms are rejected.
+func TestParseArgs_UnknownChecksum(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parseArgs([]string{"--checksum=whirlpool", "a", "b"}); err == nil {
+		t.Error("expected error for unknown checksum algorithm, got nil")
+	}
+}
+
+// TestVerifyCopy_Mismatch tests that differing files fail verification.
+func TestVerifyCopy_Mismatch(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create two differing files
+	if err := os.WriteFile(sourceFile, []byte("original"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(destFile, []byte("corrupted"), 0o600); err != nil {
+		t.Fatalf("failed to create destination file: %v", err)
+	}
+
+	// Test & Verify: Verification reports the mismatch
+	opts := new(options)
+	opts.checksum = "crc32"
+
+	if _, err := verifyCopy(opts, sourceFile, destFile, nil); err == nil {
+		t.Error("expected verification error, got nil")
+	}
+}
+
+// TestPrintChecksum tests that --checksum-only prints the known hash of fixed content.
+func TestPrintChecksum(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Print the sha256 checksum
+	var out bytes.Buffer
+	if err := printChecksum(&out, "sha256", 0, sourceFile); err != nil {
+		t.Fatalf("printChecksum() failed: %v", err)
+	}
+
+	// Verify: Output matches sha256sum
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  " + sourceFile + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+// TestCopyFile_VerifyBeforeOverwrite tests that a destination whose checksum
+// doesn't match --verify-before-overwrite is left untouched.
+func TestCopyFile_VerifyBeforeOverwrite(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create source and a destination that changed since it was hashed
+	if err := os.WriteFile(sourceFile, []byte("new"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(destFile, []byte("edited elsewhere"), 0o600); err != nil {
+		t.Fatalf("failed to create destination file: %v", err)
+	}
+
+	// sha256 of "hello", the content the destination was expected to have
+	expected := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	// Test: Copy with the stale expected checksum
+	opts, err := parseArgs([]string{"--verify-before-overwrite=" + expected, sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	_, err = copyFile(t.Context(), opts, sourceFile, destFile)
+
+	// Verify: The copy is refused and the destination kept
+	if err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Fatalf("expected checksum mismatch error, got: %v", err)
+	}
+
+	if got, _ := os.ReadFile(destFile); string(got) != "edited elsewhere" {
+		t.Errorf("destination was modified: %q", got)
+	}
+
+	// Test & Verify: A missing destination is written regardless
+	if err := os.Remove(destFile); err != nil {
+		t.Fatalf("failed to remove destination file: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() to a missing destination failed: %v", err)
+	}
+}
+
+// TestPrintChecksumAlgorithms tests that --list-checksums names the
+// supported algorithms.
+func TestPrintChecksumAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	if err := printChecksumAlgorithms(&out); err != nil {
+		t.Fatalf("printChecksumAlgorithms() failed: %v", err)
+	}
+
+	names := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for _, want := range []string{"sha256", "crc32"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("expected %s in %q", want, names)
+		}
+	}
+}
+
+// TestCopyFile_VerifyModes tests that both --verify and --verify=strict pass
+// a faithful copy and catch bytes that differ from the source.
+func TestCopyFile_VerifyModes(t *testing.T) {
+	t.Parallel()
+
+	for _, flag := range []string{"--verify", "--verify=strict"} {
+		t.Run(flag, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "source.txt")
+			destFile := filepath.Join(tmpDir, "dest.txt")
+
+			// Setup: Create source file
+			content := []byte(strings.Repeat("verify me\n", 1000))
+			if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			opts, err := parseArgs([]string{"-q", flag, sourceFile, destFile})
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			// Test & Verify: A faithful copy passes
+			if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+				t.Fatalf("copyFile() failed: %v", err)
+			}
+
+			// Test & Verify: A copy whose bytes were altered in flight fails
+			opts.readSource = func(io.Reader) io.Reader {
+				return strings.NewReader("corrupted")
+			}
+
+			_, err = copyFile(t.Context(), opts, sourceFile, destFile)
+			if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+				t.Errorf("expected checksum mismatch, got: %v", err)
+			}
+		})
+	}
+}
+
+// TestParseArgs_UnknownVerifyMode tests that only --verify=strict is
+// accepted as a value.
+func TestParseArgs_UnknownVerifyMode(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseArgs([]string{"--verify=lax", "a", "b"})
+	if err == nil || !strings.Contains(err.Error(), "unknown verify mode 'lax'") {
+		t.Fatalf("expected unknown verify mode error, got: %v", err)
+	}
+}
+
+// BenchmarkCopyFile_Verify compares hashing the written bytes with reading
+// dest back under --verify=strict.
+func BenchmarkCopyFile_Verify(b *testing.B) {
+	tmpDir := b.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	content := bytes.Repeat([]byte("benchmark"), 1<<20)
+
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		b.Fatalf("failed to create source file: %v", err)
+	}
+
+	for _, flag := range []string{"--verify", "--verify=strict"} {
+		b.Run(flag, func(b *testing.B) {
+			destFile := filepath.Join(b.TempDir(), "dest.bin")
+
+			opts, err := parseArgs([]string{"-q", flag, sourceFile, destFile})
+			if err != nil {
+				b.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for range b.N {
+				if _, err := copyFile(b.Context(), opts, sourceFile, destFile); err != nil {
+					b.Fatalf("copyFile() failed: %v", err)
+				}
+			}
+		})
+	}
+}
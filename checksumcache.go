@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// checksumCacheEntry is a --checksum-cache record: the sha256 of a file as
+// it was when it had the given size and modification time.
+type checksumCacheEntry struct {
+	size    int64
+	modTime int64
+	sum     string
+}
+
+// checksumCache implements --checksum-cache, remembering source checksums
+// across runs so unchanged files aren't read again to verify a copy.
+type checksumCache struct {
+	path    string
+	entries map[string]checksumCacheEntry
+	dirty   bool
+}
+
+// loadChecksumCache reads the cache file at path, whose records are
+// "path,size,mtime,sha256" lines. A missing file is an empty cache.
+func loadChecksumCache(path string) (*checksumCache, error) {
+	cache := &checksumCache{path: path, entries: make(map[string]checksumCacheEntry), dirty: false}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("opening checksum cache: %w", err)
+	}
+
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 4
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading checksum cache: %w", err)
+	}
+
+	for _, record := range records {
+		size, sizeErr := strconv.ParseInt(record[1], 10, 64)
+		modTime, timeErr := strconv.ParseInt(record[2], 10, 64)
+
+		if sizeErr != nil || timeErr != nil {
+			return nil, fmt.Errorf( //nolint:err113
+				"invalid checksum cache record for '%s'", record[0],
+			)
+		}
+
+		cache.entries[record[0]] = checksumCacheEntry{size: size, modTime: modTime, sum: record[3]}
+	}
+
+	return cache, nil
+}
+
+// lookup returns the cached checksum of path, provided info shows the file
+// unchanged since it was recorded.
+func (cache *checksumCache) lookup(path string, info fs.FileInfo) (string, bool) {
+	entry, ok := cache.entries[path]
+	if !ok || entry.size != info.Size() || entry.modTime != info.ModTime().UnixNano() {
+		return "", false
+	}
+
+	return entry.sum, true
+}
+
+// store records sum as the checksum of path in the state described by info,
+// replacing any stale entry.
+func (cache *checksumCache) store(path string, info fs.FileInfo, sum string) {
+	cache.entries[path] = checksumCacheEntry{
+		size:    info.Size(),
+		modTime: info.ModTime().UnixNano(),
+		sum:     sum,
+	}
+	cache.dirty = true
+}
+
+// save writes the cache back to its file if it changed.
+func (cache *checksumCache) save() error {
+	if !cache.dirty {
+		return nil
+	}
+
+	paths := make([]string, 0, len(cache.entries))
+	for path := range cache.entries {
+		paths = append(paths, path)
+	}
+
+	slices.Sort(paths)
+
+	records := make([][]string, 0, len(paths))
+	for _, path := range paths {
+		entry := cache.entries[path]
+		records = append(records, []string{
+			path, strconv.FormatInt(entry.size, 10), strconv.FormatInt(entry.modTime, 10), entry.sum,
+		})
+	}
+
+	file, err := os.Create(cache.path)
+	if err != nil {
+		return fmt.Errorf("creating checksum cache: %w", err)
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("writing checksum cache: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("closing checksum cache: %w", err)
+	}
+
+	cache.dirty = false
+
+	return nil
+}
+
+// sourceChecksum returns the checksum of the source file at path, taken from
+// --checksum-cache when the file hasn't changed since it was last hashed.
+func (opts *options) sourceChecksum(path string) (string, error) {
+	if opts.checksumCache == nil {
+		return opts.hashFile(path)
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("getting absolute path for checksum cache: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("getting file info for checksum: %w", err)
+	}
+
+	if sum, ok := opts.checksumCache.lookup(key, info); ok {
+		return sum, nil
+	}
+
+	sum, err := opts.hashFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	opts.checksumCache.store(key, info, sum)
+
+	return sum, nil
+}
+
+// hashFile hashes the file at path with the --checksum algorithm, opening it
+// through opts.openFile.
+func (opts *options) hashFile(path string) (string, error) {
+	open := opts.openFile
+	if open == nil {
+		open = func(name string) (io.ReadCloser, error) { return os.Open(name) }
+	}
+
+	file, err := open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening file for checksum: %w", err)
+	}
+
+	defer file.Close()
+
+	return hashReader(opts.checksum, opts.checksumSeed, file)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFile_ChecksumCache tests that --checksum-cache skips re-reading an
+// unchanged source when verifying, and re-reads it once it changes.
+func TestCopyFile_ChecksumCache(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+	cacheFile := filepath.Join(tmpDir, "checksums.csv")
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, []byte("cache me"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	args := []string{"--verify", "--checksum-cache=" + cacheFile, sourceFile, destFile}
+
+	// copyVerified runs one verified copy with the cache and returns how
+	// often the source was opened for hashing.
+	copyVerified := func() int {
+		opts, err := parseArgs(args)
+		if err != nil {
+			t.Fatalf("parseArgs() failed: %v", err)
+		}
+
+		if opts.checksumCache, err = loadChecksumCache(cacheFile); err != nil {
+			t.Fatalf("loadChecksumCache() failed: %v", err)
+		}
+
+		opens := 0
+		opts.openFile = func(name string) (io.ReadCloser, error) {
+			opens++
+
+			return os.Open(name)
+		}
+
+		if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+			t.Fatalf("copyFile() failed: %v", err)
+		}
+
+		if err := opts.checksumCache.save(); err != nil {
+			t.Fatalf("saving checksum cache failed: %v", err)
+		}
+
+		return opens
+	}
+
+	// Test & Verify: The first run hashes the source and fills the cache
+	if opens := copyVerified(); opens != 1 {
+		t.Errorf("first run opened source %d times, want 1", opens)
+	}
+
+	// Test & Verify: The second run reuses the cached checksum
+	if opens := copyVerified(); opens != 0 {
+		t.Errorf("second run opened source %d times, want 0", opens)
+	}
+
+	// Test & Verify: A changed source invalidates its entry
+	if err := os.WriteFile(sourceFile, []byte("cache me again"), 0o600); err != nil {
+		t.Fatalf("failed to update source file: %v", err)
+	}
+
+	if opens := copyVerified(); opens != 1 {
+		t.Errorf("run after change opened source %d times, want 1", opens)
+	}
+}
+
+// TestParseArgs_ChecksumCacheAlgorithm tests that --checksum-cache is
+// refused with a checksum other than sha256.
+func TestParseArgs_ChecksumCacheAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"--checksum=md5", "--checksum-cache=c.csv", "a", "b"}
+	if _, err := parseArgs(args); err == nil {
+		t.Error("expected an error for --checksum-cache with md5")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// compareChunkSize is how many bytes are compared at a time by --compare.
+const compareChunkSize = 64 * 1024
+
+// errFilesDiffer is returned by --compare when the files are not identical.
+var errFilesDiffer = errors.New("files differ")
+
+// runCompare implements --compare, reporting whether source and dest differ
+// without writing anything.
+func runCompare(opts *options, source, dest string) error {
+	diff, err := compareFiles(source, dest)
+	if err != nil {
+		return err
+	}
+
+	if diff == "" {
+		if opts.verbose {
+			fmt.Fprintf(opts.output(), "%s and %s are identical\n", source, dest)
+		}
+
+		return nil
+	}
+
+	if opts.verbose {
+		fmt.Fprintf(opts.output(), "%s and %s differ: %s\n", source, dest, diff)
+	}
+
+	return errFilesDiffer
+}
+
+// compareFiles compares source and dest byte by byte. It returns an empty
+// string when they are identical, or a description of the first difference.
+func compareFiles(source, dest string) (string, error) {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return "", fmt.Errorf("opening source file: %w", err)
+	}
+
+	defer sourceFile.Close()
+
+	destFile, err := os.Open(dest)
+	if err != nil {
+		return "", fmt.Errorf("opening destination file: %w", err)
+	}
+
+	defer destFile.Close()
+
+	sourceInfo, err := sourceFile.Stat()
+	if err != nil {
+		return "", fmt.Errorf("getting source file info: %w", err)
+	}
+
+	destInfo, err := destFile.Stat()
+	if err != nil {
+		return "", fmt.Errorf("getting destination file info: %w", err)
+	}
+
+	if sourceInfo.Size() != destInfo.Size() {
+		return fmt.Sprintf("size %d != %d bytes", sourceInfo.Size(), destInfo.Size()), nil
+	}
+
+	return compareContents(sourceFile, destFile)
+}
+
+// compareContents compares the bytes of source and dest, which have the
+// same size, a chunk at a time, describing the first difference as
+// compareFiles does.
+func compareContents(sourceFile, destFile io.Reader) (string, error) {
+	sourceBuf := make([]byte, compareChunkSize)
+	destBuf := make([]byte, compareChunkSize)
+
+	var offset int64
+
+	for {
+		sourceN, sourceErr := io.ReadFull(sourceFile, sourceBuf)
+		destN, destErr := io.ReadFull(destFile, destBuf)
+
+		if idx := firstDifference(sourceBuf[:sourceN], destBuf[:destN]); idx >= 0 {
+			return fmt.Sprintf("first difference at byte %d", offset+int64(idx)), nil
+		}
+
+		offset += int64(sourceN)
+
+		if errors.Is(sourceErr, io.EOF) || errors.Is(sourceErr, io.ErrUnexpectedEOF) {
+			return "", nil
+		}
+
+		if sourceErr != nil {
+			return "", fmt.Errorf("reading source file: %w", sourceErr)
+		}
+
+		if destErr != nil {
+			return "", fmt.Errorf("reading destination file: %w", destErr)
+		}
+	}
+}
+
+// firstDifference returns the index of the first differing byte, or -1 if the
+// slices are equal.
+func firstDifference(left, right []byte) int {
+	if bytes.Equal(left, right) {
+		return -1
+	}
+
+	for idx := range min(len(left), len(right)) {
+		if left[idx] != right[idx] {
+			return idx
+		}
+	}
+
+	return min(len(left), len(right))
+}
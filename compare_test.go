@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCompareFiles tests --compare for identical and differing files.
+func TestCompareFiles(t *testing.T) {
+	t.Parallel()
+
+	large := bytes.Repeat([]byte("a"), 3*compareChunkSize)
+	changed := bytes.Clone(large)
+	changed[2*compareChunkSize+7] = 'b'
+
+	tests := []struct {
+		name     string
+		source   []byte
+		dest     []byte
+		wantDiff string
+	}{
+		{
+			name:     "identical",
+			source:   large,
+			dest:     large,
+			wantDiff: "",
+		},
+		{
+			name:     "size mismatch",
+			source:   []byte("short"),
+			dest:     []byte("longer"),
+			wantDiff: "size 5 != 6 bytes",
+		},
+		{
+			name:     "content mismatch",
+			source:   large,
+			dest:     changed,
+			wantDiff: "first difference at byte 131079",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			checkCompare(t, tt.source, tt.dest, tt.wantDiff)
+		})
+	}
+}
+
+// checkCompare compares a source and a destination file holding source and
+// dest, checking that --compare reports wantDiff and writes nothing.
+func checkCompare(t *testing.T, source, dest []byte, wantDiff string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+
+	// Setup: Create both files
+	if err := os.WriteFile(sourceFile, source, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(destFile, dest, 0o600); err != nil {
+		t.Fatalf("failed to create destination file: %v", err)
+	}
+
+	// Test: Compare the files
+	diff, err := compareFiles(sourceFile, destFile)
+	if err != nil {
+		t.Fatalf("compareFiles() failed: %v", err)
+	}
+
+	// Verify: The difference is reported correctly
+	if diff != wantDiff {
+		t.Errorf("diff mismatch: got %q, want %q", diff, wantDiff)
+	}
+
+	// Verify: The exit status reflects the difference
+	err = runCompare(new(options), sourceFile, destFile)
+	if errors.Is(err, errFilesDiffer) != (wantDiff != "") {
+		t.Errorf("unexpected runCompare() result: %v", err)
+	}
+
+	// Verify: Nothing was written
+	got, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if !bytes.Equal(got, dest) {
+		t.Error("destination file was modified by compare")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// compressGzip is the --compress value selecting gzip.
+const compressGzip = "gzip"
+
+// magicSize is how many leading bytes looksCompressed reads, enough for the
+// longest of compressedMagic.
+const magicSize = 8
+
+// compressedMagic lists the leading bytes of common compressed formats.
+func compressedMagic() [][]byte {
+	return [][]byte{
+		{0x1f, 0x8b},                       // gzip
+		{0x28, 0xb5, 0x2f, 0xfd},           // zstd
+		{0xfd, '7', 'z', 'X', 'Z', 0x00},   // xz
+		{'B', 'Z', 'h'},                    // bzip2
+		{'P', 'K', 0x03, 0x04},             // zip
+		{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}, // 7z
+	}
+}
+
+// looksCompressed reports whether file starts with a known compression magic.
+func looksCompressed(file *os.File) bool {
+	header := make([]byte, magicSize)
+
+	n, _ := file.ReadAt(header, 0)
+	for _, magic := range compressedMagic() {
+		if bytes.HasPrefix(header[:n], magic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reportCompressed prints the success message with raw and compressed sizes.
+func reportCompressed(opts *options, source, dest string) error {
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("getting source file info: %w", err)
+	}
+
+	destInfo, err := os.Stat(dest)
+	if err != nil {
+		return fmt.Errorf("getting destination file info: %w", err)
+	}
+
+	opts.successf("File copied from %s to %s successfully (%d bytes compressed to %d bytes).\n",
+		source, dest, sourceInfo.Size(), destInfo.Size())
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFile_CompressGzip tests that --compress=gzip writes a gzip stream of the source.
+func TestCopyFile_CompressGzip(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "big.log")
+	destFile := filepath.Join(tmpDir, "big.log.gz")
+	content := bytes.Repeat([]byte("log line with repeated content\n"), 10000)
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with compression
+	opts, err := parseArgs([]string{"--compress=gzip", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Destination decompresses back to the source
+	file, err := os.Open(destFile)
+	if err != nil {
+		t.Fatalf("failed to open destination file: %v", err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("destination is not a gzip stream: %v", err)
+	}
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decompress destination: %v", err)
+	}
+
+	if !bytes.Equal(got, content) {
+		t.Errorf("decompressed content mismatch: got %d bytes, want %d", len(got), len(content))
+	}
+}
+
+// TestParseArgs_UnsupportedCompression tests that unknown formats are rejected.
+func TestParseArgs_UnsupportedCompression(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parseArgs([]string{"--compress=lzma", "a", "b"}); err == nil {
+		t.Error("expected error for unsupported compression, got nil")
+	}
+}
+
+// TestCopyFile_Decompress tests that --decompress and --auto gunzip the source.
+func TestCopyFile_Decompress(t *testing.T) {
+	t.Parallel()
+
+	for _, flag := range []string{"--decompress", "--auto"} {
+		t.Run(flag, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "archive.txt.gz")
+			destFile := filepath.Join(tmpDir, "archive.txt")
+			content := []byte("original uncompressed content\n")
+
+			// Setup: Create a gzipped source
+			var buf bytes.Buffer
+
+			writer := gzip.NewWriter(&buf)
+			if _, err := writer.Write(content); err != nil {
+				t.Fatalf("failed to compress content: %v", err)
+			}
+
+			if err := writer.Close(); err != nil {
+				t.Fatalf("failed to finish compression: %v", err)
+			}
+
+			if err := os.WriteFile(sourceFile, buf.Bytes(), 0o600); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			// Test: Copy with decompression
+			opts, err := parseArgs([]string{flag, sourceFile, destFile})
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+				t.Fatalf("copyFile() failed: %v", err)
+			}
+
+			// Verify: Destination holds the original content
+			got, err := os.ReadFile(destFile)
+			if err != nil {
+				t.Fatalf("failed to read destination file: %v", err)
+			}
+
+			if !bytes.Equal(got, content) {
+				t.Errorf("content mismatch: got %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+// TestCopyFile_DecompressCorrupt tests that a truncated gzip source fails
+// without leaving a partial destination.
+func TestCopyFile_DecompressCorrupt(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "broken.gz")
+	destFile := filepath.Join(tmpDir, "broken.txt")
+
+	// Setup: Create a truncated gzip stream
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(bytes.Repeat([]byte("data"), 1000)); err != nil {
+		t.Fatalf("failed to compress content: %v", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to finish compression: %v", err)
+	}
+
+	if err := os.WriteFile(sourceFile, buf.Bytes()[:buf.Len()/2], 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with decompression
+	opts := new(options)
+	opts.decompress = true
+
+	_, err := copyFile(t.Context(), opts, sourceFile, destFile)
+
+	// Verify: The copy fails and no destination is left behind
+	if err == nil {
+		t.Fatal("expected error for corrupt gzip source, got nil")
+	}
+
+	if _, err := os.Stat(destFile); !os.IsNotExist(err) {
+		t.Errorf("expected partial destination to be removed, got err: %v", err)
+	}
+}
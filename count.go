@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// treeCount is the size of a copy as measured by --count-only.
+type treeCount struct {
+	files int
+	bytes int64
+}
+
+// countTree walks the tree rooted at source as a recursive copy would,
+// honouring --max-depth, --exclude and the size and time filters, and totals
+// the regular files it would copy. Symlinks inside the tree aren't followed,
+// nor is a symlink source under -P, which copies it as a link.
+func countTree(opts *options, source string) (treeCount, error) {
+	var count treeCount
+
+	root := source
+	if opts.dereference != derefNever {
+		resolved, err := filepath.EvalSymlinks(source)
+		if err != nil {
+			return count, fmt.Errorf("resolving source: %w", err)
+		}
+
+		root = resolved
+	}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		return count.add(opts, root, path, entry)
+	})
+	if err != nil {
+		return count, fmt.Errorf("counting '%s': %w", source, err)
+	}
+
+	return count, nil
+}
+
+// add counts the entry at path, walked in the tree rooted at root, if a
+// copy would copy it as a regular file.
+func (count *treeCount) add(opts *options, root, path string, entry fs.DirEntry) error {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return fmt.Errorf("resolving relative path: %w", err)
+	}
+
+	if rel != "." {
+		depth := strings.Count(rel, string(filepath.Separator))
+		if (opts.maxDepth != nil && depth > *opts.maxDepth) || opts.excluded(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+	}
+
+	if !entry.Type().IsRegular() {
+		return nil
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return fmt.Errorf("getting file info: %w", err)
+	}
+
+	if opts.filteredOut(info) == "" {
+		count.files++
+		count.bytes += info.Size()
+	}
+
+	return nil
+}
+
+// printCount implements --count-only, writing the number of files and bytes
+// a copy of source would copy to the standard output.
+func printCount(opts *options, source string) error {
+	count, err := countTree(opts, source)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(opts.output(), "%d files, %d bytes\n", count.files, count.bytes)
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRun_CountOnly tests that --count-only totals the files and bytes of a
+// tree, honouring --exclude, without copying anything.
+func TestRun_CountOnly(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+
+	// Setup: Four files of known size, one of them excluded
+	files := map[string]string{
+		"a.txt":         "12345",
+		"b.txt":         "1234567890",
+		"sub/c.txt":     "123",
+		"sub/deep/d.go": "1234567",
+		"sub/skip.tmp":  "ignored",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(sourceDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	opts, err := parseArgs([]string{"--count-only", "--exclude=*.tmp", sourceDir})
+	if err != nil {
+		t.Fatalf("failed to parse args: %v", err)
+	}
+
+	var output bytes.Buffer
+
+	opts.stdout = &output
+
+	// Test: Count the tree
+	if err := opts.execute("cp"); err != nil {
+		t.Fatalf("failed to count tree: %v", err)
+	}
+
+	// Verify: The totals cover the four included files
+	if got, want := output.String(), "4 files, 25 bytes\n"; got != want {
+		t.Errorf("count = %q, want %q", got, want)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to read temp dir: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected --count-only to copy nothing, found %d entries", len(entries))
+	}
+}
+
+// TestRun_CountOnlyNoDereference tests that --count-only with -P counts a
+// symlink source as the link a copy would make, not the tree it points to.
+func TestRun_CountOnlyNoDereference(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	link := filepath.Join(tmpDir, "link")
+
+	// Setup: A symlink to a directory holding one file
+	if err := os.Mkdir(sourceDir, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte("12345"), 0o600); err != nil {
+		t.Fatalf("failed to create a.txt: %v", err)
+	}
+
+	if err := os.Symlink(sourceDir, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "followed by default",
+			args: []string{"--count-only", link},
+			want: "1 files, 5 bytes\n",
+		},
+		{
+			name: "kept as a link under -P",
+			args: []string{"--count-only", "-P", link},
+			want: "0 files, 0 bytes\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := parseArgs(tt.args)
+			if err != nil {
+				t.Fatalf("failed to parse args: %v", err)
+			}
+
+			var output bytes.Buffer
+
+			opts.stdout = &output
+
+			// Test: Count the symlink source
+			if err := opts.execute("cp"); err != nil {
+				t.Fatalf("failed to count tree: %v", err)
+			}
+
+			// Verify: The totals match the dereference mode
+			if got := output.String(); got != tt.want {
+				t.Errorf("count = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
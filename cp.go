@@ -1,63 +1,1042 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 )
 
-const requiredNumberArgs = 3
+const requiredNumberArgs = 2
 
 func main() {
-	if err := run(); err != nil {
-		fmt.Printf("Error: %v\n", err)
+	if err := run(os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	if len(os.Args) != requiredNumberArgs {
-		return fmt.Errorf("usage: %s <source file> <destination file>", os.Args[0]) //nolint:err113
+// run runs cp with args, the command line including the program name, which
+// usage messages report.
+func run(args []string) error {
+	program := "cp"
+	if len(args) > 0 {
+		program, args = args[0], args[1:]
 	}
 
-	source := os.Args[1]
-	dest := os.Args[2]
+	defaults, err := defaultFlags()
+	if err != nil {
+		return err
+	}
 
-	sourceAbs, err := filepath.Abs(source)
+	opts, err := parseArgsWithDefaults(defaults, args)
 	if err != nil {
-		return fmt.Errorf("getting absolute path of source file: %w", err)
+		return err
+	}
+
+	err = opts.execute(program)
+	if err != nil && opts.verboseErrors {
+		opts.printErrorChain(err)
+	}
+
+	return err
+}
+
+// execute carries out the copy or other operation described by opts.
+func (opts *options) execute(program string) error {
+	if opts.umask != nil {
+		defer applyUmask(opts, *opts.umask)()
+	}
+
+	if done, err := opts.runWithoutDest(program); done {
+		return err
+	}
+
+	if err := opts.checkUsage(program); err != nil {
+		return err
 	}
 
-	destAbs, err := filepath.Abs(dest)
+	source := opts.paths[0]
+	dest := opts.paths[len(opts.paths)-1]
+
+	if opts.into != "" {
+		var err error
+		if dest, err = intoTarget(opts, source); err != nil {
+			return err
+		}
+	}
+
+	if done, err := opts.runOnPair(source, dest); done {
+		return err
+	}
+
+	if opts.makeDirs {
+		if err := makeParentDirs(opts, dest); err != nil {
+			return err
+		}
+	}
+
+	ctx, cleanup, err := opts.setUp(dest)
+	defer cleanup()
+
 	if err != nil {
-		return fmt.Errorf("getting absolute path of destination file: %w", err)
+		return err
+	}
+
+	return opts.copyPaths(ctx, source, dest)
+}
+
+// runWithoutDest runs the operations that take no destination: listing
+// checksum algorithms, --verify-manifest, --to-tar, --checksum-only and
+// --count-only. It reports whether opts asked for one of them.
+func (opts *options) runWithoutDest(program string) (bool, error) {
+	switch {
+	case opts.listChecksums:
+		return true, printChecksumAlgorithms(opts.output())
+	case opts.verifyManifest != "":
+		if len(opts.paths) != 0 {
+			return true, fmt.Errorf("usage: %s --verify-manifest=FILE", program) //nolint:err113
+		}
+
+		return true, runVerifyManifest(opts, opts.verifyManifest)
+	case opts.toTar != "":
+		if len(opts.paths) == 0 {
+			return true, fmt.Errorf( //nolint:err113
+				"usage: %s --to-tar=ARCHIVE [options] <source> [<source> ...]", program,
+			)
+		}
+
+		return true, runToTar(opts, opts.paths)
+	case opts.checksumOnly:
+		if len(opts.paths) != 1 {
+			return true, fmt.Errorf( //nolint:err113
+				"usage: %s --checksum-only [--checksum=ALGO] <source file>", program,
+			)
+		}
+
+		return true, printChecksum(opts.output(), opts.checksum, opts.checksumSeed, opts.paths[0])
+	case opts.countOnly:
+		if len(opts.paths) != 1 {
+			return true, fmt.Errorf( //nolint:err113
+				"usage: %s --count-only [options] <source>", program,
+			)
+		}
+
+		return true, printCount(opts, opts.paths[0])
 	}
 
-	if sourceAbs == destAbs {
-		return errors.New("source and destination files are the same") //nolint:err113
+	return false, nil
+}
+
+// checkUsage checks the number of paths given for a copy.
+func (opts *options) checkUsage(program string) error {
+	switch {
+	case opts.filesFrom != "":
+		if len(opts.paths) != 1 {
+			return fmt.Errorf( //nolint:err113
+				"usage: %s --files-from=LIST [options] <destination directory>", program,
+			)
+		}
+	case opts.into != "":
+		if len(opts.paths) != 1 {
+			return fmt.Errorf( //nolint:err113
+				"usage: %s --into=DIR [--as=NAME] [options] <source file>", program,
+			)
+		}
+	case len(opts.paths) != requiredNumberArgs:
+		return fmt.Errorf( //nolint:err113
+			"usage: %s [options] <source file> <destination file>", program,
+		)
 	}
 
-	sourceFile, err := os.Open(source)
+	return nil
+}
+
+// runOnPair runs the operations on source and dest other than copying:
+// --compare, --exchange and --from-tar. It reports whether opts asked for
+// one of them.
+func (opts *options) runOnPair(source, dest string) (bool, error) {
+	switch {
+	case opts.compare:
+		return true, runCompare(opts, source, dest)
+	case opts.exchange:
+		return true, runExchange(opts, source, dest)
+	case opts.fromTar != "":
+		return true, runFromTar(opts, source, dest)
+	}
+
+	return false, nil
+}
+
+// setUp prepares a copy to dest: it takes the --lock, opens the --progress-to output, loads the
+// --checksum-cache and starts the --timeout and the --summary clock. The
+// returned cleanup undoes what was set up, in reverse, and must be called
+// even if setting up failed.
+func (opts *options) setUp(dest string) (context.Context, func(), error) {
+	var cleanups []func()
+
+	cleanup := func() {
+		for idx := len(cleanups) - 1; idx >= 0; idx-- {
+			cleanups[idx]()
+		}
+	}
+
+	ctx := context.Background()
+
+	if opts.lock {
+		unlock, err := acquireLock(opts, dest)
+		if err != nil {
+			return ctx, cleanup, err
+		}
+
+		cleanups = append(cleanups, unlock)
+	}
+
+	closeProgress, err := opts.openProgressOutput()
 	if err != nil {
-		return fmt.Errorf("opening source file: %w", err)
+		return ctx, cleanup, err
+	}
+
+	cleanups = append(cleanups, closeProgress)
+
+	if opts.checksumCacheFile != "" {
+		saveCache, err := opts.openChecksumCache()
+		if err != nil {
+			return ctx, cleanup, err
+		}
+
+		cleanups = append(cleanups, saveCache)
+	}
+
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		cleanups = append(cleanups, cancel)
+	}
+
+	if opts.summary {
+		start := time.Now()
+
+		cleanups = append(cleanups, func() { opts.printSummary(start) })
+	}
+
+	return ctx, cleanup, nil
+}
+
+// openChecksumCache loads the --checksum-cache, returning the function that
+// saves it back.
+func (opts *options) openChecksumCache() (func(), error) {
+	cache, err := loadChecksumCache(opts.checksumCacheFile)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.checksumCache = cache
+
+	return func() {
+		if err := cache.save(); err != nil {
+			opts.warnf("%v", err)
+		}
+	}, nil
+}
+
+// copyPaths copies source, or the sources of --files-from, to dest.
+func (opts *options) copyPaths(ctx context.Context, source, dest string) error {
+	if opts.filesFrom != "" {
+		return runFilesFrom(ctx, opts, dest)
+	}
+
+	if isWindowsGlob(source) {
+		return runGlob(ctx, opts, source, dest)
+	}
+
+	if opts.recursive || opts.dereference == derefNever {
+		return runRecursive(ctx, opts, source, dest)
+	}
+
+	batch := newBatchCopy(opts)
+	if err := batch.copyOne(ctx, source, dest); err != nil {
+		return err
+	}
+
+	return batch.err()
+}
+
+// batchCopy copies files one at a time: the source file on the command
+// line, or the files of a --files-from list or a Windows wildcard.
+type batchCopy struct {
+	opts *options
+	// failures counts the files that failed under --ignore-errors.
+	failures int
+}
+
+// newBatchCopy starts a batch of copies under opts.
+func newBatchCopy(opts *options) *batchCopy {
+	return &batchCopy{opts: opts, failures: 0}
+}
+
+// copyOne copies the file source to dest, unless skipSource skips it, and
+// reports the copy. A failure is logged and returned, or under
+// --ignore-errors reported, counted and left for err.
+func (batch *batchCopy) copyOne(ctx context.Context, source, dest string) error {
+	opts := batch.opts
+
+	err := batch.copyReported(ctx, source, dest)
+	if err == nil {
+		return nil
+	}
+
+	opts.logError(source, err)
+
+	if !opts.ignoreErrors {
+		return err
+	}
+
+	batch.failures++
+
+	fmt.Fprintf(opts.errorOutput(), "Error: %s: %v\n", source, err)
+
+	return nil
+}
+
+// err returns the error summarizing the failures under --ignore-errors, or
+// nil if there were none.
+func (batch *batchCopy) err() error {
+	if batch.failures > 0 {
+		return fmt.Errorf("%d files failed", batch.failures) //nolint:err113
+	}
+
+	return nil
+}
+
+// copyReported copies source to dest unless skipSource skips it, printing
+// the success message of a copy that isn't a --dry-run.
+func (batch *batchCopy) copyReported(ctx context.Context, source, dest string) error {
+	opts := batch.opts
+
+	skipped, err := opts.skipSource(source, dest)
+	if err != nil || skipped {
+		return err
+	}
+
+	if _, err := copyFile(ctx, opts, source, dest); err != nil {
+		return err
+	}
+
+	switch {
+	case opts.dryRun:
+		return nil
+	case opts.compress != "":
+		return reportCompressed(opts, source, dest)
+	default:
+		opts.successf("File copied from %s to %s successfully.\n", source, dest)
+
+		return nil
+	}
+}
+
+// intoTarget implements --into and --as, returning the destination of source
+// inside the --into directory.
+func intoTarget(opts *options, source string) (string, error) {
+	if info, err := os.Stat(opts.into); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("target '%s' is not a directory", opts.into) //nolint:err113
+	}
+
+	name := opts.as
+	if name == "" {
+		name = filepath.Base(source)
+	}
+
+	return filepath.Join(opts.into, name), nil
+}
+
+// makeParentDirs implements -D, creating the missing parents of dest.
+func makeParentDirs(opts *options, dest string) error {
+	mode := opts.dirMode
+	if mode == 0 {
+		mode = dirMode
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), mode); err != nil {
+		return fmt.Errorf("creating destination directories: %w", err)
+	}
+
+	return nil
+}
+
+// successf prints a success message to stdout unless -q was given.
+func (opts *options) successf(format string, args ...any) {
+	if !opts.quiet {
+		fmt.Fprintf(opts.output(), format, args...)
+	}
+}
+
+// input returns the reader standing in for stdin.
+func (opts *options) input() io.Reader {
+	if opts.stdin == nil {
+		return os.Stdin
+	}
+
+	return opts.stdin
+}
+
+// output returns the writer for messages, which go to stdout by default.
+func (opts *options) output() io.Writer {
+	if opts.stdout == nil {
+		return os.Stdout
+	}
+
+	return opts.stdout
+}
+
+// errorOutput returns the writer for warnings, per-file errors, --progress
+// and --log-format output, which go to stderr by default.
+func (opts *options) errorOutput() io.Writer {
+	if opts.stderr == nil {
+		return os.Stderr
+	}
+
+	return opts.stderr
+}
+
+// warnf prints a non-fatal warning to stderr.
+func (opts *options) warnf(format string, args ...any) {
+	fmt.Fprintf(opts.errorOutput(), "Warning: "+format+"\n", args...)
+}
+
+// copyFile copies the contents of source to dest according to opts,
+// trying again on transient errors under --retry. Under --on-conflict=rename
+// an existing dest is left alone and a free name next to it used instead.
+// Under --dry-run the copy is only previewed, and reported as skipped if it
+// would change nothing.
+func copyFile(ctx context.Context, opts *options, source, dest string) (copyResult, error) {
+	if opts.destExists == policyRename {
+		dest = freeName(dest)
+	}
+
+	if opts.dryRun {
+		identical, err := previewCopy(opts, source, dest)
+
+		return copyResult{bytes: 0, method: "", skipped: identical, checksum: ""}, err
+	}
+
+	if opts.retry == 0 {
+		return copyFileOnce(ctx, opts, source, dest)
+	}
+
+	var result copyResult
+
+	err := opts.withRetry(ctx, source, func() error {
+		var err error
+
+		result, err = copyFileOnce(ctx, opts, source, dest)
+
+		return err
+	})
+
+	return result, err
+}
+
+// sameFile reports whether source and dest name the same file once made
+// absolute and, where they exist, with symlinks resolved, so that copying a
+// symlink onto its own target is caught. A dest symlink that
+// --no-dereference-dest will replace is compared as the link itself.
+func sameFile(opts *options, source, dest string) (bool, error) {
+	sourcePath, err := resolvePath(source)
+	if err != nil {
+		return false, fmt.Errorf("getting absolute path of source file: %w", err)
+	}
+
+	resolve := resolvePath
+	if opts.noDereferenceDest && isSymlink(dest) {
+		resolve = filepath.Abs
+	}
+
+	destPath, err := resolve(dest)
+	if err != nil {
+		return false, fmt.Errorf("getting absolute path of destination file: %w", err)
+	}
+
+	return sourcePath == destPath, nil
+}
+
+// resolvePath returns the absolute path of path with symlinks resolved, or
+// just the absolute path if path doesn't exist yet.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err //nolint:wrapcheck
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return abs, nil //nolint:nilerr
+	}
+
+	return resolved, nil
+}
+
+// copyResult is the outcome of copyFile, for the summary, the JSON log and
+// programs embedding the copy.
+type copyResult struct {
+	// bytes is the number of source bytes copied.
+	bytes  int64
+	method copyMethod
+	// skipped is set when dest was left alone, as it is when a --dry-run
+	// finds it identical to the source.
+	skipped bool
+	// checksum is the --checksum of the copy, when --verify computed it.
+	checksum string
+}
+
+// copyFileOnce makes a single attempt at copying source to dest.
+func copyFileOnce(ctx context.Context, opts *options, source, dest string) (copyResult, error) {
+	var failed copyResult
+
+	sourceFile, info, err := openCopySource(opts, source, dest)
+	if err != nil {
+		return failed, err
 	}
 
 	defer sourceFile.Close()
 
-	destFile, err := os.Create(dest)
+	length, err := opts.rangeLength(info.Size())
+	if err != nil {
+		return failed, err
+	}
+
+	if err := prepareDestination(opts, dest, info, length); err != nil {
+		return failed, err
+	}
+
+	tee, err := newCopyTee(opts)
+	if err != nil {
+		return failed, err
+	}
+
+	method, checksum, err := writeCopy(ctx, opts, sourceFile, info, dest, tee, length)
+	if err != nil {
+		return failed, err
+	}
+
+	if err := finishCopy(opts, source, dest, info, tee.manifest); err != nil {
+		return failed, err
+	}
+
+	result := copyResult{bytes: length, method: method, skipped: false, checksum: checksum}
+	opts.logCopy(source, dest, result)
+
+	return result, nil
+}
+
+// openCopySource opens source for copying to dest, refusing to copy a file
+// onto itself, a directory, or onto a directory.
+func openCopySource(opts *options, source, dest string) (*os.File, fs.FileInfo, error) {
+	same, err := sameFile(opts, source, dest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if same {
+		return nil, nil, errors.New("source and destination files are the same") //nolint:err113
+	}
+
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening source file: %w", err)
+	}
+
+	info, err := sourceFile.Stat()
+	if err != nil {
+		sourceFile.Close()
+
+		return nil, nil, fmt.Errorf("getting source file info: %w", err)
+	}
+
+	if info.IsDir() {
+		sourceFile.Close()
+
+		return nil, nil, fmt.Errorf("omitting directory '%s' (use -r)", source) //nolint:err113
+	}
+
+	if err := checkNotDirectory(dest); err != nil {
+		sourceFile.Close()
+
+		return nil, nil, err
+	}
+
+	return sourceFile, info, nil
+}
+
+// checkNotDirectory refuses to overwrite dest with a file if it's a directory.
+func checkNotDirectory(dest string) error {
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		return fmt.Errorf( //nolint:err113
+			"cannot overwrite directory '%s' with non-directory", dest,
+		)
+	}
+
+	return nil
+}
+
+// prepareDestination runs the checks and removals of dest that come before
+// copying length bytes of the source described by info into it:
+// --check-space, --verify-before-overwrite and --replace-newer-only, then
+// clearDestination.
+func prepareDestination(opts *options, dest string, info fs.FileInfo, length int64) error {
+	if opts.checkSpace {
+		if err := checkSpace(opts, dest, length); err != nil {
+			return err
+		}
+	}
+
+	if opts.expectDestSum != "" {
+		if err := checkDestChecksum(opts, dest); err != nil {
+			return err
+		}
+	}
+
+	if opts.replaceNewerOnly {
+		if err := checkDestNotNewer(opts, dest, info); err != nil {
+			return err
+		}
+	}
+
+	return clearDestination(opts, dest)
+}
+
+// clearDestination implements --trash and --remove-destination, moving or
+// removing dest before the copy.
+func clearDestination(opts *options, dest string) error {
+	if opts.trash != "" {
+		if err := trashDest(opts, dest); err != nil {
+			return err
+		}
+	}
+
+	if opts.removeDestination {
+		if err := os.Remove(dest); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("removing destination file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeCopy writes length bytes of sourceFile to dest the way opts asks
+// for, through tee, and verifies the copy under --verify, returning how it
+// was copied and the verified checksum.
+func writeCopy(
+	ctx context.Context,
+	opts *options,
+	sourceFile *os.File,
+	info fs.FileInfo,
+	dest string,
+	tee copyTee,
+	length int64,
+) (copyMethod, string, error) {
+	method, checksum, err := writeDestination(ctx, opts, sourceFile, info, dest, tee, length)
 	if err != nil {
-		return fmt.Errorf("creating destination file: %w", err)
+		return "", "", err
+	}
+
+	if opts.verbose {
+		fmt.Fprintf(opts.output(), "%s -> %s: %s\n", sourceFile.Name(), dest, method)
+	}
+
+	if opts.verify && checksum == "" {
+		if checksum, err = verifyCopy(opts, sourceFile.Name(), dest, tee.written); err != nil {
+			return "", "", err
+		}
+	}
+
+	return method, checksum, nil
+}
+
+// writeDestination writes length bytes of sourceFile to dest through tee:
+// resuming the copy under --resume, through a partial file under
+// --partial-suffix, verifying each attempt under --verify with --retry or
+// replacing a symlink under --no-dereference-dest. The returned checksum is
+// empty unless the copy was verified already.
+func writeDestination(
+	ctx context.Context,
+	opts *options,
+	sourceFile *os.File,
+	info fs.FileInfo,
+	dest string,
+	tee copyTee,
+	length int64,
+) (copyMethod, string, error) {
+	method := methodCopied
+	checksum := ""
+
+	var err error
+
+	switch {
+	case opts.resume:
+		err = copyResumable(ctx, sourceFile, info, dest, tee.Writer)
+	case opts.partialSuffix != "":
+		method, err = copyPartial(ctx, opts, sourceFile, info, dest, tee.Writer, length)
+	case opts.verify && opts.retry > 0:
+		// Each attempt is verified in a fresh temporary file, so a
+		// corrupted copy never replaces dest.
+		err = replaceVerified(opts, dest, func(path string) error {
+			method, err = copyContents(ctx, opts, sourceFile, info, path, tee.Writer, length)
+			if err != nil {
+				return err
+			}
+
+			checksum, err = verifyCopy(opts, sourceFile.Name(), path, tee.written)
+
+			return err
+		})
+	case opts.noDereferenceDest && isSymlink(dest):
+		err = replaceAtomically(opts, dest, func(temp string) error {
+			method, err = copyContents(ctx, opts, sourceFile, info, temp, tee.Writer, length)
+
+			return err
+		})
+	default:
+		method, err = copyContents(ctx, opts, sourceFile, info, dest, tee.Writer, length)
+	}
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return method, checksum, nil
+}
+
+// finishCopy applies the attributes of the source described by info to the
+// copy at dest and records it in the --manifest, whose hash of the copied
+// bytes is manifest.
+func finishCopy(opts *options, source, dest string, info fs.FileInfo, manifest hash.Hash) error {
+	if opts.preserve != 0 {
+		if err := applyAttributes(opts, source, dest, info); err != nil {
+			return err
+		}
+	}
+
+	if err := normalizePermissions(opts, dest, info); err != nil {
+		return err
+	}
+
+	if err := overrideMode(opts, dest); err != nil {
+		return err
+	}
+
+	if err := overrideOwnership(opts, dest); err != nil {
+		return err
+	}
+
+	if manifest != nil {
+		sum := hex.EncodeToString(manifest.Sum(nil))
+		if err := appendManifest(opts.manifest, opts.manifestFormat, sum, dest); err != nil {
+			return err
+		}
+	}
+
+	applyFileFlags(opts, source, dest, info)
+
+	return nil
+}
+
+// copyPartial implements --partial-suffix, copying into dest plus the suffix
+// and renaming the result to dest once complete. A failed copy leaves the
+// partial file behind for inspection or a manual resume.
+func copyPartial(
+	ctx context.Context,
+	opts *options,
+	sourceFile *os.File,
+	info fs.FileInfo,
+	dest string,
+	tee io.Writer,
+	length int64,
+) (copyMethod, error) {
+	partial := dest + opts.partialSuffix
+
+	method, err := copyContents(ctx, opts, sourceFile, info, partial, tee, length)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(partial, dest); err != nil {
+		return "", fmt.Errorf("renaming partial file: %w", err)
+	}
+
+	return method, nil
+}
+
+// openDestination creates or truncates dest for writing, or opens it for
+// appending under --append. An existing
+// destination that isn't writable is an error unless -f was given, in which
+// case it is removed and created again.
+func openDestination(opts *options, dest string, perm fs.FileMode) (*os.File, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if opts.appendDest {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	destFile, err := os.OpenFile(dest, flag, perm)
+	if err == nil {
+		return destFile, nil
+	}
+
+	if _, statErr := os.Lstat(dest); !errors.Is(err, fs.ErrPermission) || statErr != nil {
+		return nil, fmt.Errorf("creating destination file: %w", err)
+	}
+
+	if !opts.force {
+		return nil, fmt.Errorf("destination '%s' is not writable (use -f)", dest) //nolint:err113
+	}
+
+	if err := os.Remove(dest); err != nil {
+		return nil, fmt.Errorf("removing unwritable destination: %w", err)
+	}
+
+	destFile, err = os.OpenFile(dest, flag, perm)
+	if err != nil {
+		return nil, fmt.Errorf("creating destination file: %w", err)
+	}
+
+	return destFile, nil
+}
+
+// copyContents creates dest and streams length bytes of sourceFile into it,
+// starting at the --skip offset.
+// A new destination gets the permission bits of the source, masked by umask.
+// When tee is non-nil, every byte written to dest is also written to tee.
+// --transform stages apply after --compress and before the tee.
+// Unless --reflink=never, the source is cloned where the filesystem allows;
+// the returned method tells whether it was.
+// A source whose size changed while it was read, such as a log being
+// appended to, is reported with a warning, or as an error under --strict.
+// A copy interrupted by a timeout, a corrupt compressed source or a binary
+// source under --text removes the partial destination, unless appending or
+// keeping partial files.
+func copyContents(
+	ctx context.Context,
+	opts *options,
+	sourceFile *os.File,
+	info fs.FileInfo,
+	dest string,
+	tee io.Writer,
+	length int64,
+) (copyMethod, error) {
+	source := opts.sourceReader(sourceFile, length)
+
+	counter, reader, err := opts.decompressReader(sourceFile.Name(), source)
+	if err != nil {
+		return "", err
+	}
+
+	decompressing := counter != nil
+
+	destFile, err := openDestination(opts, dest, info.Mode().Perm())
+	if err != nil {
+		return "", err
 	}
 
 	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
+	cloned, err := cloneDestination(opts, destFile, sourceFile)
+	if err != nil {
+		return "", err
+	}
+
+	if cloned {
+		return methodCloned, nil
+	}
+
+	preallocated := !decompressing && preallocateDestination(opts, destFile, length)
+
+	writer := newWriteChain(opts, destFile, sourceFile, tee)
+
+	buf := opts.copyBuffers().get()
+	defer opts.copyBuffers().put(buf)
+
+	copied, err := opts.copyLoop()(ctx, writer, reader, *buf)
+
+	err = trimDestination(destFile, preallocated, err)
+	if err != nil {
+		return "", opts.failedCopy(destFile, dest, err, decompressing)
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	if err := destFile.Close(); err != nil {
+		return "", fmt.Errorf("closing destination file: %w", err)
+	}
+
+	if counter != nil {
+		copied = counter.read
+	}
+
+	return methodCopied, opts.checkCopied(sourceFile.Name(), length, copied)
+}
+
+// sourceReader returns the reader of the length bytes copyContents copies
+// from sourceFile, starting at the --skip offset, with progress reports.
+func (opts *options) sourceReader(sourceFile *os.File, length int64) io.Reader {
+	var source io.Reader = sourceFile
+	if opts.hasRange() {
+		source = io.NewSectionReader(sourceFile, opts.skipBytes, length)
+	}
+
+	if opts.readSource != nil {
+		source = opts.readSource(source)
+	}
+
+	if report := opts.progressFunc(); report != nil {
+		source = newProgressReader(source, length, report)
+	}
+
+	return source
+}
+
+// decompressReader returns the reader gunzipping source if the source file
+// at path is to be decompressed, along with the counter of the compressed
+// bytes it reads, as the copy counts decompressed bytes. Otherwise source is
+// returned as is, keeping the fast paths io.Copy takes for an *os.File, and
+// the counter is nil.
+func (opts *options) decompressReader(
+	path string,
+	source io.Reader,
+) (*countingReader, io.Reader, error) {
+	if !opts.shouldDecompress(path) {
+		return nil, source, nil
+	}
+
+	counter := &countingReader{reader: source, read: 0}
+
+	gzipReader, err := gzip.NewReader(counter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source is not a valid gzip stream: %w", err)
+	}
+
+	return counter, gzipReader, nil
+}
+
+// cloneDestination clones sourceFile into destFile and closes it, unless
+// --reflink=never or the copy changes the bytes. A failed clone is only an
+// error under --reflink=always.
+func cloneDestination(opts *options, destFile, sourceFile *os.File) (bool, error) {
+	if !opts.canClone() {
+		return false, nil
+	}
+
+	err := cloneFile(destFile, sourceFile)
+	if err == nil {
+		if err := destFile.Close(); err != nil {
+			return false, fmt.Errorf("closing destination file: %w", err)
+		}
+
+		return true, nil
+	}
+
+	if opts.reflink == reflinkAlways {
+		return false, fmt.Errorf("cloning '%s': %w", sourceFile.Name(), err)
+	}
+
+	return false, nil
+}
+
+// preallocateDestination implements --preallocate for a copy that writes
+// the length bytes it reads, reporting whether the space was preallocated.
+// A failure is only noted under -v.
+func preallocateDestination(opts *options, destFile *os.File, length int64) bool {
+	if !opts.preallocate || opts.appendDest || opts.text || opts.compress != "" ||
+		len(opts.transforms) > 0 || length <= 0 {
+		return false
+	}
+
+	err := preallocate(destFile, length)
+	if err != nil && opts.verbose {
+		fmt.Fprintf(opts.output(), "Note: preallocating %s failed: %v\n", destFile.Name(), err)
+	}
+
+	return err == nil
+}
+
+// trimDestination cuts destFile back to the bytes written to it if it was
+// preallocated, whether or not the copy failed with copyErr, which it
+// returns.
+func trimDestination(destFile *os.File, preallocated bool, copyErr error) error {
+	if !preallocated {
+		return copyErr
+	}
+
+	if err := trimPreallocated(destFile); err != nil && copyErr == nil {
+		return fmt.Errorf("trimming destination file: %w", err)
+	}
+
+	return copyErr
+}
+
+// copyLoop returns the loop copyContents streams the bytes with, which
+// copies in chunks under --chunk-size or with an OnChunk callback.
+func (opts *options) copyLoop() func(context.Context, io.Writer, io.Reader, []byte) (int64, error) {
+	if opts.chunkSize == 0 && opts.onChunk == nil {
+		return copyStream
+	}
+
+	return func(ctx context.Context, dst io.Writer, src io.Reader, buf []byte) (int64, error) {
+		return copyChunks(ctx, dst, src, buf, opts.onChunk)
+	}
+}
+
+// failedCopy describes the error of an interrupted copy into destFile,
+// removing the partial destination unless appending or keeping partial files
+// when the rest of the copy couldn't have been usable.
+func (opts *options) failedCopy(
+	destFile *os.File,
+	dest string,
+	err error,
+	decompressing bool,
+) error {
+	corrupt := errors.Is(err, errCopyTimedOut) || errors.Is(err, errBinaryText)
+	if !corrupt && !decompressing {
 		return fmt.Errorf("copying file: %w", err)
 	}
 
-	fmt.Printf("File copied from %s to %s successfully.\n", source, dest)
+	destFile.Close()
+
+	if !opts.appendDest && opts.partialSuffix == "" {
+		os.Remove(dest)
+	}
+
+	if corrupt {
+		return err
+	}
+
+	return fmt.Errorf("decompressing source: %w", err)
+}
+
+// checkCopied reports a source whose size changed while it was copied, with
+// a warning or, under --strict, an error.
+func (opts *options) checkCopied(name string, length, copied int64) error {
+	if copied == length {
+		return nil
+	}
+
+	err := fmt.Errorf( //nolint:err113
+		"source changed during copy: expected %d, copied %d", length, copied,
+	)
+	if opts.strict {
+		return err
+	}
+
+	opts.warnf("%s: %v", name, err)
 
 	return nil
 }
@@ -62,10 +62,19 @@ func newE2EEnv(t *testing.T) *e2eTestEnv {
 func (env *e2eTestEnv) runCmd(args ...string) (string, string, int) {
 	env.t.Helper()
 
+	return env.runCmdIn("", args...)
+}
+
+// runCmdIn is like runCmd, but runs the binary in dir, so that args can
+// hold relative paths.
+func (env *e2eTestEnv) runCmdIn(dir string, args ...string) (string, string, int) {
+	env.t.Helper()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, env.binPath, args...) //nolint:gosec
+	cmd.Dir = dir
 
 	var outBuf, errBuf bytes.Buffer
 
@@ -635,3 +644,266 @@ func TestE2E_ConcurrentCopies(t *testing.T) {
 		}
 	}
 }
+
+// TestE2E_ArchiveMode tests that -a reproduces a tree with symlinks, modes and timestamps.
+func TestE2E_ArchiveMode(t *testing.T) {
+	t.Parallel()
+
+	env := newE2EEnv(t)
+	defer os.RemoveAll(env.tempDir)
+
+	sourceDir := filepath.Join(env.tempDir, "src")
+	destDir := filepath.Join(env.tempDir, "dst")
+	script := filepath.Join(sourceDir, "bin", "run.sh")
+	private := filepath.Join(sourceDir, "private")
+	link := filepath.Join(sourceDir, "bin", "current")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Arrange: Create a tree with special modes, a symlink and old mtimes
+	env.createFile(script, "#!/bin/sh\necho hi\n")
+
+	if err := os.Mkdir(private, 0o700); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	if err := os.Chmod(script, 0o751); err != nil {
+		t.Fatalf("failed to chmod script: %v", err)
+	}
+
+	if err := os.Symlink("run.sh", link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	for _, path := range []string{script, private} {
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("failed to set times: %v", err)
+		}
+	}
+
+	// Act
+	_, stderr, exitCode := env.runCmd("-a", sourceDir, destDir)
+
+	// Assert
+	if exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d\nstderr: %s", exitCode, stderr)
+	}
+
+	for _, rel := range []string{"bin/run.sh", "private"} {
+		checkModeAndTime(t, filepath.Join(sourceDir, rel), filepath.Join(destDir, rel))
+	}
+
+	target, err := os.Readlink(filepath.Join(destDir, "bin", "current"))
+	if err != nil {
+		t.Fatalf("expected symlink to be preserved: %v", err)
+	}
+
+	if target != "run.sh" {
+		t.Errorf("symlink target mismatch: got %q, want %q", target, "run.sh")
+	}
+}
+
+// checkModeAndTime checks that the copy at dest kept the mode and
+// modification time of source.
+func checkModeAndTime(t *testing.T, source, dest string) {
+	t.Helper()
+
+	want, err := os.Stat(source)
+	if err != nil {
+		t.Fatalf("failed to stat source %s: %v", source, err)
+	}
+
+	got, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("failed to stat copy %s: %v", dest, err)
+	}
+
+	if got.Mode() != want.Mode() {
+		t.Errorf("mode mismatch for %s: got %v, want %v", dest, got.Mode(), want.Mode())
+	}
+
+	if !got.ModTime().Equal(want.ModTime()) {
+		t.Errorf("mtime mismatch for %s: got %v, want %v", dest, got.ModTime(), want.ModTime())
+	}
+}
+
+// TestE2E_QuietMode tests that -q suppresses the success message.
+func TestE2E_QuietMode(t *testing.T) {
+	t.Parallel()
+
+	env := newE2EEnv(t)
+	defer os.RemoveAll(env.tempDir)
+
+	sourceFile := filepath.Join(env.tempDir, "source.txt")
+	destFile := filepath.Join(env.tempDir, "dest.txt")
+
+	// Arrange
+	env.createFile(sourceFile, "quiet please")
+
+	// Act: -q also overrides -v
+	stdout, stderr, exitCode := env.runCmd("-v", "-q", sourceFile, destFile)
+
+	// Assert
+	if exitCode != 0 {
+		t.Errorf("expected exit code 0, got %d\nstderr: %s", exitCode, stderr)
+	}
+
+	if stdout != "" {
+		t.Errorf("expected empty stdout, got: %q", stdout)
+	}
+
+	if content := env.readFile(destFile); content != "quiet please" {
+		t.Errorf("content mismatch: got %q, want %q", content, "quiet please")
+	}
+}
+
+// TestE2E_MakeDirs tests that -D creates missing parent directories.
+func TestE2E_MakeDirs(t *testing.T) {
+	t.Parallel()
+
+	env := newE2EEnv(t)
+	defer os.RemoveAll(env.tempDir)
+
+	sourceFile := filepath.Join(env.tempDir, "source.txt")
+	destDir := filepath.Join(env.tempDir, "a", "b", "c")
+	destFile := filepath.Join(destDir, "dest.txt")
+
+	// Arrange
+	env.createFile(sourceFile, "deep copy")
+
+	// Act
+	_, stderr, exitCode := env.runCmd("-D", "--dir-mode=0700", sourceFile, destFile)
+
+	// Assert
+	if exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d\nstderr: %s", exitCode, stderr)
+	}
+
+	if content := env.readFile(destFile); content != "deep copy" {
+		t.Errorf("content mismatch: got %q, want %q", content, "deep copy")
+	}
+
+	info, err := os.Stat(destDir)
+	if err != nil {
+		t.Fatalf("failed to stat created directory: %v", err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Errorf("created directory mode = %o, want 700", perm)
+	}
+}
+
+// TestE2E_StripTrailingSlashes tests that a trailing slash on a symlinked
+// directory source no longer resolves the link under -P.
+func TestE2E_StripTrailingSlashes(t *testing.T) {
+	t.Parallel()
+
+	env := newE2EEnv(t)
+	defer os.RemoveAll(env.tempDir)
+
+	targetDir := filepath.Join(env.tempDir, "target")
+	linkDir := filepath.Join(env.tempDir, "link")
+	withSlash := filepath.Join(env.tempDir, "with-slash")
+	withoutSlash := filepath.Join(env.tempDir, "without-slash")
+
+	// Arrange
+	env.createFile(filepath.Join(targetDir, "file.txt"), "inside")
+
+	if err := os.Symlink(targetDir, linkDir); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	// Act
+	_, stderr, exitCode := env.runCmd("-P", "--strip-trailing-slashes", linkDir+"/", withSlash)
+	if exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d\nstderr: %s", exitCode, stderr)
+	}
+
+	_, stderr, exitCode = env.runCmd("-P", linkDir, withoutSlash)
+	if exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d\nstderr: %s", exitCode, stderr)
+	}
+
+	// Assert: Both copies are symlinks to the same target
+	for _, dest := range []string{withSlash, withoutSlash} {
+		target, err := os.Readlink(dest)
+		if err != nil {
+			t.Fatalf("expected %s to be a symlink: %v", dest, err)
+		}
+
+		if target != targetDir {
+			t.Errorf("symlink target = %q, want %q", target, targetDir)
+		}
+	}
+}
+
+// TestE2E_Summary tests that --summary ends a recursive copy with the totals.
+func TestE2E_Summary(t *testing.T) {
+	t.Parallel()
+
+	env := newE2EEnv(t)
+	defer os.RemoveAll(env.tempDir)
+
+	sourceDir := filepath.Join(env.tempDir, "src")
+	outDir := filepath.Join(env.tempDir, "out")
+
+	// Arrange: c.txt already exists at the destination and is kept
+	env.createFile(filepath.Join(sourceDir, "a.txt"), "aaa")
+	env.createFile(filepath.Join(sourceDir, "b.txt"), "bbbb")
+	env.createFile(filepath.Join(sourceDir, "c.txt"), "cc")
+	env.createFile(filepath.Join(outDir, "src", "c.txt"), "old")
+
+	// Act
+	stdout, stderr, exitCode := env.runCmd("-r", "--update=none", "--summary", sourceDir, outDir)
+
+	// Assert
+	if exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d\nstderr: %s", exitCode, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	last := lines[len(lines)-1]
+
+	if want := "2 files, 7 bytes copied, 1 skipped, 0 errors in "; !strings.HasPrefix(last, want) {
+		t.Errorf("summary = %q, want prefix %q", last, want)
+	}
+
+	// Act: -q suppresses the summary
+	stdout, _, _ = env.runCmd("-q", "-r", "--update=none", "--summary", sourceDir, outDir)
+
+	// Assert
+	if stdout != "" {
+		t.Errorf("expected empty stdout under -q, got: %q", stdout)
+	}
+}
+
+// TestE2E_EndOfOptions tests that "--" lets a file whose name starts with a
+// dash be copied instead of being taken for an option.
+func TestE2E_EndOfOptions(t *testing.T) {
+	t.Parallel()
+
+	env := newE2EEnv(t)
+	defer os.RemoveAll(env.tempDir)
+
+	// Arrange
+	env.createFile(filepath.Join(env.tempDir, "-weird-filename"), "dashing")
+
+	// Act
+	_, stderr, exitCode := env.runCmdIn(env.tempDir, "-q", "--", "-weird-filename", "-copy")
+
+	// Assert
+	if exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d\nstderr: %s", exitCode, stderr)
+	}
+
+	if content := env.readFile(filepath.Join(env.tempDir, "-copy")); content != "dashing" {
+		t.Errorf("expected content 'dashing', got '%s'", content)
+	}
+
+	// Act: Without "--" the name is taken for options
+	_, _, exitCode = env.runCmdIn(env.tempDir, "-weird-filename", "-copy")
+
+	// Assert
+	if exitCode == 0 {
+		t.Error("expected failure without --, got exit code 0")
+	}
+}
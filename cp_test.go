@@ -3,8 +3,11 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -23,9 +26,7 @@ func TestCopyFile_Success(t *testing.T) {
 	}
 
 	// Test: Copy file
-	os.Args = []string{"cp", sourceFile, destFile}
-
-	err = run()
+	err = run([]string{"cp", sourceFile, destFile})
 	if err != nil {
 		t.Errorf("run() failed: %v", err)
 	}
@@ -57,9 +58,7 @@ func TestCopyFile_LargeFile(t *testing.T) {
 	}
 
 	// Test: Copy large file
-	os.Args = []string{"cp", sourceFile, destFile}
-
-	err = run()
+	err = run([]string{"cp", sourceFile, destFile})
 	if err != nil {
 		t.Errorf("run() failed: %v", err)
 	}
@@ -96,9 +95,7 @@ func TestCopyFile_BinaryFile(t *testing.T) {
 	}
 
 	// Test: Copy binary file
-	os.Args = []string{"cp", sourceFile, destFile}
-
-	err = run()
+	err = run([]string{"cp", sourceFile, destFile})
 	if err != nil {
 		t.Errorf("run() failed: %v", err)
 	}
@@ -130,9 +127,7 @@ func TestCopyFile_EmptyFile(t *testing.T) {
 	f.Close() // Close the file immediately
 
 	// Test: Copy empty file
-	os.Args = []string{"cp", sourceFile, destFile}
-
-	err = run()
+	err = run([]string{"cp", sourceFile, destFile})
 	if err != nil {
 		t.Errorf("run() failed: %v", err)
 	}
@@ -175,9 +170,7 @@ func TestCopyFile_RelativePaths(t *testing.T) { //nolint:paralleltest
 	}
 
 	// Test: Copy with relative paths
-	os.Args = []string{"cp", sourceFile, destFile}
-
-	if err = run(); err != nil {
+	if err = run([]string{"cp", sourceFile, destFile}); err != nil {
 		t.Errorf("run() failed: %v", err)
 	}
 
@@ -205,8 +198,7 @@ func TestCopyFile_SameSourceAndDest(t *testing.T) {
 	}
 
 	// Test: Try to copy same file
-	os.Args = []string{"cp", sourceFile, sourceFile}
-	err = run()
+	err = run([]string{"cp", sourceFile, sourceFile})
 
 	// Verify: Should error when source equals destination
 	if err == nil {
@@ -218,6 +210,53 @@ func TestCopyFile_SameSourceAndDest(t *testing.T) {
 	}
 }
 
+// TestCopyFile_SameFileThroughSymlink tests that a symlink naming the
+// destination's real path is caught as the same file, in either operand, and
+// that the destination is left intact.
+func TestCopyFile_SameFileThroughSymlink(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args func(link, target string) []string
+	}{
+		{
+			name: "link as source",
+			args: func(link, target string) []string { return []string{"cp", link, target} },
+		},
+		{
+			name: "link as destination",
+			args: func(link, target string) []string { return []string{"cp", target, link} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			realFile := filepath.Join(tmpDir, "real.txt")
+			linkFile := filepath.Join(tmpDir, "link.txt")
+
+			// Setup: Create a file and a symlink to it
+			writeFiles(t, tmpDir, map[string]string{"real.txt": oldContent})
+
+			if err := os.Symlink(realFile, linkFile); err != nil {
+				t.Skipf("symlinks not supported: %v", err)
+			}
+
+			// Test: Copy between the link and the file it points to
+			err := run(tt.args(linkFile, realFile))
+
+			// Verify: The copy is refused and the file keeps its content
+			if err == nil || err.Error() != "source and destination files are the same" {
+				t.Errorf("expected same-file error, got: %v", err)
+			}
+
+			checkRegularFile(t, realFile, oldContent)
+		})
+	}
+}
+
 // TestCopyFile_SourceNotFound tests error when source file doesn't exist.
 func TestCopyFile_SourceNotFound(t *testing.T) {
 	t.Parallel()
@@ -226,8 +265,7 @@ func TestCopyFile_SourceNotFound(t *testing.T) {
 	destFile := filepath.Join(tmpDir, "dest.txt")
 
 	// Test: Try to copy non-existent file
-	os.Args = []string{"cp", sourceFile, destFile}
-	err := run()
+	err := run([]string{"cp", sourceFile, destFile})
 
 	// Verify: Should error
 	if err == nil {
@@ -250,8 +288,7 @@ func TestCopyFile_InvalidDestinationPath(t *testing.T) {
 	}
 
 	// Test: Try to copy to non-existent directory
-	os.Args = []string{"cp", sourceFile, destFile}
-	err = run()
+	err = run([]string{"cp", sourceFile, destFile})
 
 	// Verify: Should error
 	if err == nil {
@@ -285,9 +322,7 @@ func TestCopyFile_MissingArguments(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			os.Args = tt.args
-
-			err := run()
+			err := run(tt.args)
 			if err == nil {
 				t.Error("expected error for invalid arguments, got nil")
 			}
@@ -295,6 +330,57 @@ func TestCopyFile_MissingArguments(t *testing.T) {
 	}
 }
 
+// TestRun_UsageProgramName tests that the usage message names the program
+// as given in args[0].
+func TestRun_UsageProgramName(t *testing.T) {
+	t.Parallel()
+
+	err := run([]string{"/usr/local/bin/mycp", "only-source"})
+	if err == nil || !strings.HasPrefix(err.Error(), "usage: /usr/local/bin/mycp ") {
+		t.Errorf("expected usage naming the program, got: %v", err)
+	}
+}
+
+// TestRun_IntoAs tests that --into and --as copy a source into a directory
+// under a new name.
+func TestRun_IntoAs(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "app-v2.bin")
+	targetDir := filepath.Join(tmpDir, "deploy")
+
+	// Setup: Create source file and target directory
+	if err := os.WriteFile(sourceFile, []byte("release"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Mkdir(targetDir, 0o755); err != nil {
+		t.Fatalf("failed to create target directory: %v", err)
+	}
+
+	// Test: Copy into the directory under a new name
+	args := []string{"cp", "-q", "--into=" + targetDir, "--as=app.bin", sourceFile}
+	if err := run(args); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+
+	// Verify: The copy has the new name inside the directory
+	got, err := os.ReadFile(filepath.Join(targetDir, "app.bin"))
+	if err != nil {
+		t.Fatalf("expected renamed copy in target directory: %v", err)
+	}
+
+	if string(got) != "release" {
+		t.Errorf("content = %q, want %q", got, "release")
+	}
+
+	// Test & Verify: A missing target directory is refused
+	err = run([]string{"cp", "-q", "--into=" + filepath.Join(tmpDir, "missing"), sourceFile})
+	if err == nil || !strings.Contains(err.Error(), "is not a directory") {
+		t.Errorf("expected missing directory error, got: %v", err)
+	}
+}
+
 // TestCopyFile_FilePermissions tests that file permissions are preserved.
 func TestCopyFile_FilePermissions(t *testing.T) {
 	t.Parallel()
@@ -309,9 +395,7 @@ func TestCopyFile_FilePermissions(t *testing.T) {
 	}
 
 	// Test: Copy file
-	os.Args = []string{"cp", sourceFile, destFile}
-
-	err = run()
+	err = run([]string{"cp", sourceFile, destFile})
 	if err != nil {
 		t.Errorf("run() failed: %v", err)
 	}
@@ -343,9 +427,7 @@ func TestCopyFile_OverwriteExistingFile(t *testing.T) {
 	}
 
 	// Test: Copy file over existing destination
-	os.Args = []string{"cp", sourceFile, destFile}
-
-	err = run()
+	err = run([]string{"cp", sourceFile, destFile})
 	if err != nil {
 		t.Errorf("run() failed: %v", err)
 	}
@@ -382,9 +464,7 @@ func TestCopyFile_SymlinkAsSource(t *testing.T) {
 	}
 
 	// Test: Copy via symlink
-	os.Args = []string{"cp", linkFile, destFile}
-
-	err = run()
+	err = run([]string{"cp", linkFile, destFile})
 	if err != nil {
 		t.Errorf("run() failed: %v", err)
 	}
@@ -400,6 +480,299 @@ func TestCopyFile_SymlinkAsSource(t *testing.T) {
 	}
 }
 
+// TestCopyFile_UnreadableSourceKeepsDest tests that a pre-existing destination
+// is untouched when the source can't be read.
+func TestCopyFile_UnreadableSourceKeepsDest(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("unreadable files can't be created with chmod on Windows")
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("root can read files regardless of permissions")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+	oldContent := "keep me"
+
+	// Setup: Create an unreadable source and an existing destination
+	if err := os.WriteFile(sourceFile, []byte("secret"), 0o000); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(destFile, []byte(oldContent), 0o600); err != nil {
+		t.Fatalf("failed to create destination file: %v", err)
+	}
+
+	// Test: Try to copy the unreadable source
+	if _, err := copyFile(t.Context(), new(options), sourceFile, destFile); err == nil {
+		t.Fatal("expected error for unreadable source, got nil")
+	}
+
+	// Verify: Destination is untouched
+	content, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if string(content) != oldContent {
+		t.Errorf("destination was modified: got %q, want %q", content, oldContent)
+	}
+}
+
+// setupReadOnlyDest creates a source and a read-only destination, skipping
+// where read-only files can still be written to.
+func setupReadOnlyDest(t *testing.T) (string, string) {
+	t.Helper()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("read-only files can't be removed on Windows")
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("root can write files regardless of permissions")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	if err := os.WriteFile(sourceFile, []byte(newContent), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(destFile, []byte(oldContent), 0o400); err != nil {
+		t.Fatalf("failed to create destination file: %v", err)
+	}
+
+	return sourceFile, destFile
+}
+
+// TestCopyFile_ReadOnlyDest tests the error for a read-only destination without -f.
+func TestCopyFile_ReadOnlyDest(t *testing.T) {
+	t.Parallel()
+
+	// Setup: Create a read-only destination
+	sourceFile, destFile := setupReadOnlyDest(t)
+
+	// Test: Copy without -f
+	_, err := copyFile(t.Context(), new(options), sourceFile, destFile)
+
+	// Verify: The error suggests -f and the destination is untouched
+	want := fmt.Sprintf("destination '%s' is not writable (use -f)", destFile)
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got: %v", want, err)
+	}
+
+	if content, _ := os.ReadFile(destFile); string(content) != oldContent {
+		t.Errorf("destination was modified: got %q", content)
+	}
+}
+
+// TestCopyFile_ReadOnlyDestForce tests that -f replaces a read-only destination.
+func TestCopyFile_ReadOnlyDestForce(t *testing.T) {
+	t.Parallel()
+
+	// Setup: Create a read-only destination
+	sourceFile, destFile := setupReadOnlyDest(t)
+
+	// Test: Copy with -f
+	opts := new(options)
+	opts.force = true
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The destination holds the new content
+	content, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if string(content) != newContent {
+		t.Errorf("content mismatch: got %q, want %q", content, newContent)
+	}
+}
+
+// TestCopyFile_RemoveDestination tests that --remove-destination unlinks a
+// hard-linked destination instead of writing through the shared inode.
+func TestCopyFile_RemoveDestination(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	otherFile := filepath.Join(tmpDir, "other.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create a source and a destination hard-linked to another file
+	if err := os.WriteFile(sourceFile, []byte(newContent), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(otherFile, []byte("shared content"), 0o600); err != nil {
+		t.Fatalf("failed to create other file: %v", err)
+	}
+
+	if err := os.Link(otherFile, destFile); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	// Test: Copy with --remove-destination
+	opts := new(options)
+	opts.removeDestination = true
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The destination has the new content, the other link doesn't
+	if content, _ := os.ReadFile(destFile); string(content) != newContent {
+		t.Errorf("destination content = %q, want %q", content, newContent)
+	}
+
+	if content, _ := os.ReadFile(otherFile); string(content) != "shared content" {
+		t.Errorf("other file was modified: got %q", content)
+	}
+}
+
+// TestCopyFile_Append tests that --append adds the source after the
+// existing destination content.
+func TestCopyFile_Append(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "new.log")
+	destFile := filepath.Join(tmpDir, "all.log")
+
+	// Setup: Create a source and a pre-populated destination
+	if err := os.WriteFile(sourceFile, []byte("line 2\n"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(destFile, []byte("line 1\n"), 0o600); err != nil {
+		t.Fatalf("failed to create destination file: %v", err)
+	}
+
+	// Test: Append the source
+	opts := new(options)
+	opts.appendDest = true
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The destination holds old and new content
+	content, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if want := "line 1\nline 2\n"; string(content) != want {
+		t.Errorf("content mismatch: got %q, want %q", content, want)
+	}
+
+	// Verify: Appending a file to itself is still rejected
+	if _, err := copyFile(t.Context(), opts, destFile, destFile); err == nil {
+		t.Error("expected error appending a file to itself, got nil")
+	}
+}
+
+// TestCopyFile_DirectorySourceKeepsDest tests that a directory source is
+// rejected before the destination is truncated.
+func TestCopyFile_DirectorySourceKeepsDest(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	destFile := filepath.Join(tmpDir, "dest.txt")
+	oldContent := "keep me"
+
+	// Setup: Create an existing destination
+	if err := os.WriteFile(destFile, []byte(oldContent), 0o600); err != nil {
+		t.Fatalf("failed to create destination file: %v", err)
+	}
+
+	// Test: Try to copy a directory without -r
+	if _, err := copyFile(t.Context(), new(options), tmpDir, destFile); err == nil {
+		t.Fatal("expected error for directory source, got nil")
+	}
+
+	// Verify: Destination is untouched
+	content, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if string(content) != oldContent {
+		t.Errorf("destination was modified: got %q, want %q", content, oldContent)
+	}
+}
+
+// TestCopyFile_ExecutableBit tests that the source permission bits are kept
+// without -p.
+func TestCopyFile_ExecutableBit(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("permission bits are not supported on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "script.sh")
+	destFile := filepath.Join(tmpDir, "script_copy.sh")
+
+	// Setup: Create an executable script
+	if err := os.WriteFile(sourceFile, []byte("#!/bin/sh\necho hi\n"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Chmod(sourceFile, 0o755); err != nil {
+		t.Fatalf("failed to chmod source file: %v", err)
+	}
+
+	// Test: Copy without -p
+	if _, err := copyFile(t.Context(), new(options), sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Destination is executable by its owner
+	info, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatalf("failed to stat destination file: %v", err)
+	}
+
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("expected destination to be executable, got mode %v", info.Mode())
+	}
+}
+
+// TestCopyFile_DestinationIsDirectory tests the error when the destination is
+// an existing directory and -r isn't given.
+func TestCopyFile_DestinationIsDirectory(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "file.txt")
+	destDir := filepath.Join(tmpDir, "somedir")
+
+	// Setup: Create source file and destination directory
+	if err := os.WriteFile(sourceFile, []byte("test"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Mkdir(destDir, 0o755); err != nil {
+		t.Fatalf("failed to create destination directory: %v", err)
+	}
+
+	// Test: Copy onto the directory
+	_, err := copyFile(t.Context(), new(options), sourceFile, destDir)
+
+	// Verify: Friendly error is returned
+	want := fmt.Sprintf("cannot overwrite directory '%s' with non-directory", destDir)
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error: got %v, want %q", err, want)
+	}
+}
+
 // BenchmarkCopyFile benchmarks the file copy operation.
 func BenchmarkCopyFile(b *testing.B) {
 	tmpDir := b.TempDir()
@@ -417,9 +790,7 @@ func BenchmarkCopyFile(b *testing.B) {
 	b.ResetTimer()
 
 	for i := range b.N {
-		os.Args = []string{"cp", sourceFile, fmt.Sprintf("%s.%d", destFile, i)}
-
-		err := run()
+		err := run([]string{"cp", sourceFile, fmt.Sprintf("%s.%d", destFile, i)})
 		if err != nil {
 			b.Fatalf("run() failed: %v", err)
 		}
@@ -431,3 +802,97 @@ func TestMain(m *testing.M) {
 	code := m.Run()
 	os.Exit(code)
 }
+
+// TestCopyFile_Result tests the result copyFile reports for a plain copy, a
+// copy left alone because the destination is identical, and a verified copy.
+func TestCopyFile_Result(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("result content")
+	size := int64(len(content))
+
+	want, err := hashReader("sha256", 0, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("failed to hash content: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		destExists bool
+		want       copyResult
+	}{
+		{
+			name:       "copied",
+			args:       []string{"--reflink=never"},
+			destExists: false,
+			want:       copyResult{bytes: size, method: methodCopied, skipped: false, checksum: ""},
+		},
+		{
+			name:       "skipped",
+			args:       []string{"--dry-run"},
+			destExists: true,
+			want:       copyResult{bytes: 0, method: "", skipped: true, checksum: ""},
+		},
+		{
+			name:       "verified",
+			args:       []string{"--reflink=never", "--verify"},
+			destExists: false,
+			want: copyResult{
+				bytes:    size,
+				method:   methodCopied,
+				skipped:  false,
+				checksum: want,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Test: Copy the file
+			result := copyResultOf(t, content, tt.args, tt.destExists)
+
+			// Verify: The result describes what happened
+			if result != tt.want {
+				t.Errorf("result = %+v, want %+v", result, tt.want)
+			}
+		})
+	}
+}
+
+// copyResultOf copies a source file holding content under args, over an
+// identical destination if destExists, and returns the result of the copy.
+func copyResultOf(t *testing.T, content []byte, args []string, destExists bool) copyResult {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create the source, and an identical destination if wanted
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if destExists {
+		if err := os.WriteFile(destFile, content, 0o600); err != nil {
+			t.Fatalf("failed to create destination file: %v", err)
+		}
+	}
+
+	opts, err := parseArgs(append(args, sourceFile, destFile))
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	opts.stdout = io.Discard
+
+	result, err := copyFile(t.Context(), opts, sourceFile, destFile)
+	if err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	return result
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// defaultFlagsEnv names the environment variable holding default flags.
+const defaultFlagsEnv = "CP_DEFAULT_FLAGS"
+
+// defaultFlags returns the words of CP_DEFAULT_FLAGS, which are parsed as
+// their own argument list ahead of the command line, so that flags given on
+// the command line take precedence.
+func defaultFlags() ([]string, error) {
+	defaults, err := splitShellWords(os.Getenv(defaultFlagsEnv))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", defaultFlagsEnv, err)
+	}
+
+	if slices.Contains(defaults, "--") {
+		return nil, fmt.Errorf("parsing %s: '--' is not an option", defaultFlagsEnv) //nolint:err113
+	}
+
+	return defaults, nil
+}
+
+// shellWords accumulates the words of a shell-style string.
+type shellWords struct {
+	words   []string
+	word    strings.Builder
+	inWord  bool
+	quote   rune
+	escaped bool
+}
+
+// splitShellWords splits s into words like a POSIX shell, honoring single
+// quotes, double quotes and backslash escapes. Empty input yields no words.
+func splitShellWords(s string) ([]string, error) {
+	var split shellWords
+
+	for _, char := range s {
+		split.add(char)
+	}
+
+	if split.quote != 0 || split.escaped {
+		return nil, errors.New("unterminated quote or escape") //nolint:err113
+	}
+
+	split.endWord()
+
+	return split.words, nil
+}
+
+// add takes the next character of the string being split.
+func (split *shellWords) add(char rune) {
+	switch {
+	case split.escaped:
+		split.word.WriteRune(char)
+
+		split.escaped = false
+	case split.quote != 0:
+		split.addQuoted(char)
+	default:
+		split.addUnquoted(char)
+	}
+}
+
+// addQuoted takes a character inside quotes, where only a closing quote and,
+// within double quotes, a backslash are special.
+func (split *shellWords) addQuoted(char rune) {
+	switch {
+	case char == split.quote:
+		split.quote = 0
+	case char == '\\' && split.quote == '"':
+		split.escaped = true
+	default:
+		split.word.WriteRune(char)
+	}
+}
+
+// addUnquoted takes a character outside quotes, where blanks end a word.
+func (split *shellWords) addUnquoted(char rune) {
+	switch char {
+	case '\\':
+		split.escaped, split.inWord = true, true
+	case '\'', '"':
+		split.quote, split.inWord = char, true
+	case ' ', '\t', '\n':
+		split.endWord()
+	default:
+		split.word.WriteRune(char)
+
+		split.inWord = true
+	}
+}
+
+// endWord ends the word being accumulated, if any.
+func (split *shellWords) endWord() {
+	if !split.inWord {
+		return
+	}
+
+	split.words = append(split.words, split.word.String())
+	split.word.Reset()
+
+	split.inWord = false
+}
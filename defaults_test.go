@@ -0,0 +1,138 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestSplitShellWords tests shell-style word splitting.
+func TestSplitShellWords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			input:   "  ",
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name:    "plain",
+			input:   "-p  -v",
+			want:    []string{"-p", "-v"},
+			wantErr: false,
+		},
+		{
+			name:    "quoted",
+			input:   `--manifest="my sums.txt" '--checksum=md5'`,
+			want:    []string{"--manifest=my sums.txt", "--checksum=md5"},
+			wantErr: false,
+		},
+		{
+			name:    "escaped",
+			input:   `--manifest=a\ b`,
+			want:    []string{"--manifest=a b"},
+			wantErr: false,
+		},
+		{
+			name:    "unterminated",
+			input:   `-v "oops`,
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := splitShellWords(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("words mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDefaultFlags tests that CP_DEFAULT_FLAGS applies unless overridden.
+func TestDefaultFlags(t *testing.T) {
+	t.Setenv(defaultFlagsEnv, "-v --checksum sha1")
+
+	// Test: Parse with defaults, one taking a separate value, and an overriding flag
+	defaults, err := defaultFlags()
+	if err != nil {
+		t.Fatalf("defaultFlags() failed: %v", err)
+	}
+
+	opts, err := parseArgsWithDefaults(defaults, []string{"--retry=2", "src", "dst"})
+	if err != nil {
+		t.Fatalf("parseArgsWithDefaults() failed: %v", err)
+	}
+
+	// Verify: The defaults apply, and the command line's arguments stay paths
+	if !opts.verbose || opts.checksum != "sha1" || opts.retry != 2 {
+		t.Errorf(
+			"expected -v, --checksum=sha1 and --retry=2, got %v, %q and %d",
+			opts.verbose, opts.checksum, opts.retry,
+		)
+	}
+
+	if !slices.Equal(opts.paths, []string{"src", "dst"}) {
+		t.Errorf("paths mismatch: got %q", opts.paths)
+	}
+
+	// Test & Verify: A flag on the command line wins
+	opts, err = parseArgsWithDefaults(defaults, []string{"--checksum=crc32", "src", "dst"})
+	if err != nil {
+		t.Fatalf("parseArgsWithDefaults() failed: %v", err)
+	}
+
+	if opts.checksum != "crc32" {
+		t.Errorf("expected command-line checksum to win, got %q", opts.checksum)
+	}
+}
+
+// TestParseArgsWithDefaults_Errors tests that CP_DEFAULT_FLAGS words are
+// checked on their own, without reaching into the command line.
+func TestParseArgsWithDefaults_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		defaults []string
+		want     string
+	}{
+		{
+			name:     "missing value",
+			defaults: []string{"--manifest"},
+			want:     "option '--manifest' requires a value",
+		},
+		{
+			name:     "path",
+			defaults: []string{"-v", "src"},
+			want:     "'src' is not an option",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseArgsWithDefaults(tt.defaults, []string{"src", "dst"})
+			if err == nil || !strings.Contains(err.Error(), defaultFlagsEnv) ||
+				!strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected %s error %q, got: %v", defaultFlagsEnv, tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// copyDevice recreates the block or character device source at dest with the
+// same major and minor numbers. Without root privileges the device is skipped.
+func copyDevice(opts *options, source, dest string, info fs.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("reading device numbers of '%s'", source) //nolint:err113
+	}
+
+	if os.Geteuid() != 0 {
+		opts.warnf("skipping device '%s': root privileges required", source)
+
+		return nil
+	}
+
+	mode := uint32(info.Mode().Perm())
+	if info.Mode()&fs.ModeCharDevice != 0 {
+		mode |= syscall.S_IFCHR
+	} else {
+		mode |= syscall.S_IFBLK
+	}
+
+	if err := syscall.Mknod(dest, mode, int(stat.Rdev)); err != nil { //nolint:gosec
+		return fmt.Errorf("creating device node: %w", err)
+	}
+
+	return nil
+}
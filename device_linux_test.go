@@ -0,0 +1,59 @@
+//go:build linux
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestCopyTree_DeviceNode tests that device nodes are recreated, not read.
+func TestCopyTree_DeviceNode(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() != 0 {
+		t.Skip("creating device nodes requires root")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create a /dev/null-like character device (major 1, minor 3)
+	if err := os.Mkdir(sourceDir, 0o755); err != nil {
+		t.Fatalf("failed to create source directory: %v", err)
+	}
+
+	const nullDev = 1<<8 | 3
+
+	nullPath := filepath.Join(sourceDir, "null")
+	if err := syscall.Mknod(nullPath, syscall.S_IFCHR|0o666, nullDev); err != nil {
+		t.Skipf("mknod not permitted: %v", err)
+	}
+
+	// Test: Copy the tree recursively
+	opts := new(options)
+	opts.recursive = true
+
+	if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("copyTree() failed: %v", err)
+	}
+
+	// Verify: Destination is a character device with the same numbers
+	info, err := os.Lstat(filepath.Join(destDir, "null"))
+	if err != nil {
+		t.Fatalf("failed to stat destination device: %v", err)
+	}
+
+	if info.Mode()&fs.ModeCharDevice == 0 {
+		t.Fatalf("expected character device, got mode %v", info.Mode())
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Rdev != nullDev {
+		t.Errorf("device numbers mismatch: got %v, want %d", info.Sys(), nullDev)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import "io/fs"
+
+// copyDevice skips device nodes on platforms without mknod support.
+func copyDevice(opts *options, source, _ string, _ fs.FileInfo) error {
+	opts.warnf("skipping device '%s': not supported on this platform", source)
+
+	return nil
+}
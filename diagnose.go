@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// printErrorChain implements --verbose-errors, writing err and every error
+// it wraps to the error output, one per line with its type, along with the
+// details of path and system errors and the state of the paths involved.
+func (opts *options) printErrorChain(err error) {
+	out := opts.errorOutput()
+
+	fmt.Fprintf(out, "Error chain:\n")
+
+	for depth, cause := 0, err; cause != nil; depth, cause = depth+1, errors.Unwrap(cause) {
+		fmt.Fprintf(out, "  %d: %T: %v\n", depth, cause, cause)
+
+		if errno, ok := errnoNumber(cause); ok {
+			fmt.Fprintf(out, "     errno=%d\n", errno)
+		}
+	}
+
+	printErrorDetails(out, err)
+
+	fmt.Fprintf(out, "Paths:\n")
+
+	for _, path := range opts.paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			fmt.Fprintf(out, "  %s: %v\n", path, err)
+
+			continue
+		}
+
+		fmt.Fprintf(out, "  %s: %s, %d bytes\n", path, info.Mode(), info.Size())
+	}
+}
+
+// printErrorDetails writes the operation and paths of the first path, link
+// and system error err wraps, once each however deep they are.
+func printErrorDetails(out io.Writer, err error) {
+	fmt.Fprintf(out, "Details:\n")
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		fmt.Fprintf(out, "  op=%s path=%s\n", pathErr.Op, pathErr.Path)
+	}
+
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		fmt.Fprintf(out, "  op=%s old=%s new=%s\n", linkErr.Op, linkErr.Old, linkErr.New)
+	}
+
+	var syscallErr *os.SyscallError
+	if errors.As(err, &syscallErr) {
+		fmt.Fprintf(out, "  syscall=%s\n", syscallErr.Syscall)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPrintErrorChain tests that --verbose-errors unwraps a failure down to
+// its underlying cause.
+func TestPrintErrorChain(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "missing.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	opts, err := parseArgs([]string{"--verbose-errors", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("failed to parse args: %v", err)
+	}
+
+	var stderr bytes.Buffer
+
+	opts.stderr = &stderr
+
+	// Test: Copy a missing source and print why it failed
+	err = opts.execute("cp")
+	if err == nil {
+		t.Fatal("expected error for missing source, got nil")
+	}
+
+	opts.printErrorChain(err)
+
+	// Verify: The chain reaches the errno beneath the wrapping messages
+	output := stderr.String()
+	for _, want := range []string{
+		"Error chain:\n  0: ",
+		"*fs.PathError",
+		"Details:\n  op=open path=" + sourceFile,
+		"syscall.Errno",
+		"errno=",
+		sourceFile + ": lstat",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in verbose error output, got:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// previewCopy implements --dry-run, printing what copying source to dest
+// would do without writing anything, and reporting whether dest is already
+// identical to source. An existing dest is compared with source by
+// --checksum, so a preview tells a real overwrite from a no-op, and a
+// directory is refused as the copy would refuse it.
+func previewCopy(opts *options, source, dest string) (bool, error) {
+	if err := checkNotDirectory(dest); err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(dest); errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(opts.output(), "would copy '%s' to '%s'\n", source, dest)
+
+		return false, nil
+	}
+
+	sourceSum, err := hashFile(opts.checksum, opts.checksumSeed, source)
+	if err != nil {
+		return false, err
+	}
+
+	destSum, err := hashFile(opts.checksum, opts.checksumSeed, dest)
+	if err != nil {
+		return false, err
+	}
+
+	if sourceSum == destSum {
+		fmt.Fprintf(opts.output(), "would skip '%s' (identical)\n", dest)
+
+		return true, nil
+	}
+
+	fmt.Fprintf(opts.output(), "would overwrite '%s' (differs)\n", dest)
+
+	return false, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRun_DryRunPreview tests that --dry-run tells identical destinations
+// from differing ones and leaves both untouched.
+func TestRun_DryRunPreview(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	sameDest := filepath.Join(tmpDir, "same.txt")
+	otherDest := filepath.Join(tmpDir, "other.txt")
+	newDest := filepath.Join(tmpDir, "new.txt")
+
+	// Setup: One destination matches the source, the other doesn't
+	for path, content := range map[string]string{sourceFile: "content", sameDest: "content", otherDest: "stale"} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to create %s: %v", path, err)
+		}
+	}
+
+	var output bytes.Buffer
+
+	for _, dest := range []string{sameDest, otherDest, newDest} {
+		opts, err := parseArgs([]string{"--dry-run", sourceFile, dest})
+		if err != nil {
+			t.Fatalf("failed to parse args: %v", err)
+		}
+
+		opts.stdout = &output
+
+		// Test: Preview the copy
+		if err := opts.execute("cp"); err != nil {
+			t.Fatalf("failed to preview copy to %s: %v", dest, err)
+		}
+	}
+
+	// Verify: Each destination gets its preview line and nothing is written
+	want := "would skip '" + sameDest + "' (identical)\n" +
+		"would overwrite '" + otherDest + "' (differs)\n" +
+		"would copy '" + sourceFile + "' to '" + newDest + "'\n"
+	if output.String() != want {
+		t.Errorf("expected preview:\n%s\ngot:\n%s", want, output.String())
+	}
+
+	content, err := os.ReadFile(otherDest)
+	if err != nil {
+		t.Fatalf("failed to read destination: %v", err)
+	}
+
+	if string(content) != "stale" {
+		t.Errorf("expected --dry-run to leave the destination alone, got %q", content)
+	}
+
+	if _, err := os.Stat(newDest); !os.IsNotExist(err) {
+		t.Errorf("expected --dry-run not to create %s, got: %v", newDest, err)
+	}
+}
+
+// TestRun_DryRunDestinationIsDirectory tests that --dry-run refuses a
+// directory destination as a copy would, instead of failing to hash it.
+func TestRun_DryRunDestinationIsDirectory(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destDir := filepath.Join(tmpDir, "dest")
+
+	// Setup: Create a source file and a directory in the destination's place
+	if err := os.WriteFile(sourceFile, []byte("content"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Mkdir(destDir, 0o755); err != nil {
+		t.Fatalf("failed to create destination directory: %v", err)
+	}
+
+	opts, err := parseArgs([]string{"--dry-run", sourceFile, destDir})
+	if err != nil {
+		t.Fatalf("failed to parse args: %v", err)
+	}
+
+	var output bytes.Buffer
+
+	opts.stdout = &output
+
+	// Test: Preview the copy
+	err = opts.execute("cp")
+
+	// Verify: The preview fails as the copy would, printing nothing
+	want := "cannot overwrite directory '" + destDir + "' with non-directory"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got: %v", want, err)
+	}
+
+	if output.Len() != 0 {
+		t.Errorf("expected no preview, got %q", output.String())
+	}
+}
+
+// TestParseArgs_DryRunRecursive tests that --dry-run refuses recursive copies,
+// which would create directories.
+func TestParseArgs_DryRunRecursive(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseArgs([]string{"--dry-run", "-r", "src", "dst"})
+	if err == nil || !strings.Contains(err.Error(), "--dry-run") {
+		t.Errorf("expected --dry-run conflict with -r, got: %v", err)
+	}
+}
+
+// TestParseArgs_DryRunWriters tests that --dry-run refuses options that write
+// files of their own.
+func TestParseArgs_DryRunWriters(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "exchange", args: []string{"--exchange", "a", "b"}},
+		{name: "to-tar", args: []string{"--to-tar=out.tar", "src"}},
+		{name: "from-tar", args: []string{"--from-tar=-", "in.tar", "dst"}},
+		{name: "lock", args: []string{"--lock", "src", "dst"}},
+		{
+			name: "progress-to",
+			args: []string{"--progress=plain", "--progress-to=progress.log", "src", "dst"},
+		},
+		{
+			name: "checksum-cache",
+			args: []string{"--checksum=sha256", "--checksum-cache=sums.json", "src", "dst"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseArgs(append([]string{"--dry-run"}, tt.args...))
+			if err == nil || !strings.Contains(err.Error(), "--dry-run") {
+				t.Errorf("expected --dry-run conflict, got: %v", err)
+			}
+		})
+	}
+}
+
+// TestRun_DryRunLeavesTree tests that a dry run with options that would
+// write beside the copy leaves the filesystem exactly as it was.
+func TestRun_DryRunLeavesTree(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destDir := filepath.Join(tmpDir, "dest")
+	destFile := filepath.Join(destDir, "source.txt")
+	listFile := filepath.Join(tmpDir, "list.txt")
+
+	// Setup: A stale destination and a list naming the source
+	writeFiles(t, tmpDir, map[string]string{
+		"source.txt":      "content",
+		"dest/source.txt": "stale",
+		"list.txt":        sourceFile + "\n",
+	})
+
+	before := snapshotTree(t, tmpDir)
+
+	tests := [][]string{
+		{
+			"--manifest=" + filepath.Join(tmpDir, "sums.txt"),
+			"--trash=" + filepath.Join(tmpDir, "trash"),
+			sourceFile,
+			destFile,
+		},
+		{"--files-from=" + listFile, destDir},
+		{"--on-conflict=rename", sourceFile, destFile},
+	}
+
+	for _, args := range tests {
+		opts, err := parseArgs(append([]string{"--dry-run"}, args...))
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", args, err)
+		}
+
+		opts.stdout = &bytes.Buffer{}
+
+		// Test: Preview the copy
+		if err := opts.execute("cp"); err != nil {
+			t.Fatalf("failed to preview %q: %v", args, err)
+		}
+
+		// Verify: Nothing was created, removed or changed
+		if after := snapshotTree(t, tmpDir); !maps.Equal(before, after) {
+			t.Errorf("expected %q to leave the tree alone:\nbefore %q\nafter  %q",
+				args, before, after)
+		}
+	}
+}
+
+// snapshotTree returns the path, mode and content of everything under root.
+func snapshotTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+
+	snapshot := map[string]string{}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatalf("failed to get info of %s: %v", path, err)
+		}
+
+		snapshot[path] = info.Mode().String()
+
+		if info.Mode().IsRegular() {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", path, err)
+			}
+
+			snapshot[path] += " " + string(content)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to snapshot %s: %v", root, err)
+	}
+
+	return snapshot
+}
@@ -0,0 +1,12 @@
+//go:build !plan9
+
+package main
+
+import "syscall"
+
+// errnoNumber returns the number of err if it is a system error number.
+func errnoNumber(err error) (uintptr, bool) {
+	errno, ok := err.(syscall.Errno) //nolint:errorlint
+
+	return uintptr(errno), ok
+}
@@ -0,0 +1,7 @@
+package main
+
+// errnoNumber reports that Plan 9, whose system errors are strings, has no
+// error numbers.
+func errnoNumber(_ error) (uintptr, bool) {
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errExchangeUnsupported is returned where paths can't be swapped atomically.
+var errExchangeUnsupported = errors.New("atomic exchange not supported")
+
+// runExchange implements --exchange, swapping source and dest. The swap is
+// atomic where the platform and filesystem support it; otherwise it falls
+// back to three renames through a temporary name.
+func runExchange(opts *options, source, dest string) error {
+	for _, path := range []string{source, dest} {
+		if _, err := os.Lstat(path); err != nil {
+			return fmt.Errorf("exchanging files: %w", err)
+		}
+	}
+
+	err := exchangeAtomic(source, dest)
+	if errors.Is(err, errExchangeUnsupported) {
+		if opts.verbose {
+			fmt.Fprintf(
+				opts.output(),
+				"Note: atomic exchange unavailable, swapping %s and %s with renames\n",
+				source,
+				dest,
+			)
+		}
+
+		err = exchangeByRename(source, dest)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	opts.successf("Exchanged %s and %s successfully.\n", source, dest)
+
+	return nil
+}
+
+// exchangeByRename swaps two paths with three renames. It isn't atomic, but
+// a failed step is rolled back so that neither path is lost.
+func exchangeByRename(first, second string) error {
+	temp := tempPath(first)
+
+	if err := os.Rename(first, temp); err != nil {
+		return fmt.Errorf("exchanging files: %w", err)
+	}
+
+	if err := os.Rename(second, first); err != nil {
+		_ = os.Rename(temp, first)
+
+		return fmt.Errorf("exchanging files: %w", err)
+	}
+
+	if err := os.Rename(temp, second); err != nil {
+		_ = os.Rename(first, second)
+		_ = os.Rename(temp, first)
+
+		return fmt.Errorf("exchanging files: %w", err)
+	}
+
+	return nil
+}
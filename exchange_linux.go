@@ -0,0 +1,51 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// atFDCWD is AT_FDCWD, resolving relative paths against the working directory.
+	atFDCWD = -0x64
+	// renameExchange is the RENAME_EXCHANGE flag of renameat2(2).
+	renameExchange = 0x2
+)
+
+// exchangeAtomic swaps two paths with renameat2(RENAME_EXCHANGE).
+func exchangeAtomic(first, second string) error {
+	firstPtr, err := syscall.BytePtrFromString(first)
+	if err != nil {
+		return fmt.Errorf("encoding path: %w", err)
+	}
+
+	secondPtr, err := syscall.BytePtrFromString(second)
+	if err != nil {
+		return fmt.Errorf("encoding path: %w", err)
+	}
+
+	fdcwd := atFDCWD
+
+	_, _, errno := syscall.Syscall6(
+		sysRenameat2,
+		uintptr(fdcwd),
+		uintptr(unsafe.Pointer(firstPtr)),
+		uintptr(fdcwd),
+		uintptr(unsafe.Pointer(secondPtr)),
+		renameExchange,
+		0,
+	)
+
+	switch {
+	case errno == 0:
+		return nil
+	case errors.Is(errno, syscall.ENOSYS), errors.Is(errno, syscall.EINVAL):
+		return errExchangeUnsupported
+	default:
+		return fmt.Errorf("exchanging files: %w", errno)
+	}
+}
@@ -0,0 +1,4 @@
+package main
+
+// sysRenameat2 is the renameat2 system call number.
+const sysRenameat2 = 276
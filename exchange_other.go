@@ -0,0 +1,8 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+// exchangeAtomic reports that atomic exchange isn't available here.
+func exchangeAtomic(_, _ string) error {
+	return errExchangeUnsupported
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// setupExchange creates two files with distinct contents.
+func setupExchange(t *testing.T) (string, string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	first := filepath.Join(tmpDir, "a.conf")
+	second := filepath.Join(tmpDir, "b.conf")
+
+	if err := os.WriteFile(first, []byte("first"), 0o600); err != nil {
+		t.Fatalf("failed to create first file: %v", err)
+	}
+
+	if err := os.WriteFile(second, []byte("second"), 0o600); err != nil {
+		t.Fatalf("failed to create second file: %v", err)
+	}
+
+	return first, second
+}
+
+// assertExchanged checks that first and second hold each other's contents
+// and that no temporary file was left behind.
+func assertExchanged(t *testing.T, first, second string) {
+	t.Helper()
+
+	for path, want := range map[string]string{first: "second", second: "first"} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+
+		if string(got) != want {
+			t.Errorf("content of %s = %q, want %q", path, got, want)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(first))
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Errorf("expected 2 entries after exchange, got %d", len(entries))
+	}
+}
+
+// TestRunExchange tests that --exchange swaps two files on Linux.
+func TestRunExchange(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("atomic exchange is only implemented on Linux")
+	}
+
+	// Setup: Create two files
+	first, second := setupExchange(t)
+
+	// Test: Exchange them
+	opts := new(options)
+	opts.quiet = true
+
+	if err := runExchange(opts, first, second); err != nil {
+		t.Fatalf("runExchange() failed: %v", err)
+	}
+
+	// Verify: Contents are swapped
+	assertExchanged(t, first, second)
+}
+
+// TestExchangeByRename tests the rename fallback used where atomic exchange
+// is unsupported.
+func TestExchangeByRename(t *testing.T) {
+	t.Parallel()
+
+	// Setup: Create two files
+	first, second := setupExchange(t)
+
+	// Test: Exchange them with renames
+	if err := exchangeByRename(first, second); err != nil {
+		t.Fatalf("exchangeByRename() failed: %v", err)
+	}
+
+	// Verify: Contents are swapped
+	assertExchanged(t, first, second)
+}
+
+// TestExchangeByRename_MissingSecond tests that a failed fallback leaves the
+// first file in place.
+func TestExchangeByRename_MissingSecond(t *testing.T) {
+	t.Parallel()
+
+	// Setup: Create two files and remove the second
+	first, second := setupExchange(t)
+
+	if err := os.Remove(second); err != nil {
+		t.Fatalf("failed to remove second file: %v", err)
+	}
+
+	// Test: Exchange them with renames
+	if err := exchangeByRename(first, second); err == nil {
+		t.Fatal("expected error for missing file, got nil")
+	}
+
+	// Verify: The first file was restored
+	if got, err := os.ReadFile(first); err != nil || string(got) != "first" {
+		t.Errorf("expected first file to be restored, got %q (err: %v)", got, err)
+	}
+}
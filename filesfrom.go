@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runFilesFrom implements --files-from, copying every source named in the
+// list into the directory dest, or under --source-root rebuilding the listed
+// paths below it.
+func runFilesFrom(ctx context.Context, opts *options, dest string) error {
+	sources, err := readFilesFrom(opts.input(), opts.filesFrom, opts.from0)
+	if err != nil {
+		return err
+	}
+
+	if len(sources) == 0 {
+		return fmt.Errorf("no sources listed in '%s'", opts.filesFrom) //nolint:err113
+	}
+
+	if opts.sourceRoot != "" {
+		return copyRebased(ctx, opts, sources, dest)
+	}
+
+	return copyInto(ctx, opts, sources, dest)
+}
+
+// copyRebased implements --source-root, copying every entry, a path relative
+// to the root, to the same path below the directory dest. Missing parent
+// directories are created, so the listed part of the tree is rebuilt.
+func copyRebased(ctx context.Context, opts *options, entries []string, dest string) error {
+	if destInfo, err := os.Stat(dest); err != nil || !destInfo.IsDir() {
+		return fmt.Errorf("target '%s' is not a directory", dest) //nolint:err113
+	}
+
+	batch := newBatchCopy(opts)
+
+	for _, entry := range entries {
+		if err := batch.copyEntry(ctx, entry, dest); err != nil {
+			return err
+		}
+	}
+
+	return batch.err()
+}
+
+// copyEntry copies entry, a path relative to --source-root, to the same
+// path below dest, creating its missing parent directories.
+func (batch *batchCopy) copyEntry(ctx context.Context, entry, dest string) error {
+	opts := batch.opts
+
+	if !filepath.IsLocal(entry) {
+		return fmt.Errorf( //nolint:err113
+			"'%s' is not a relative path inside --source-root", entry,
+		)
+	}
+
+	source := filepath.Join(opts.sourceRoot, entry)
+	target := filepath.Join(dest, entry)
+
+	if !opts.dryRun {
+		if err := makeParentDirs(opts, target); err != nil {
+			return err
+		}
+	}
+
+	if opts.recursive || opts.dereference == derefNever {
+		return runRecursive(ctx, opts, source, target)
+	}
+
+	return batch.copyOne(ctx, source, target)
+}
+
+// readFilesFrom reads a --files-from list from path, or from stdin if path
+// is "-". Entries are lines, ignoring blank lines and lines starting with #,
+// or NUL-terminated names under -0.
+func readFilesFrom(stdin io.Reader, path string, nul bool) ([]string, error) {
+	reader := stdin
+
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening source list: %w", err)
+		}
+
+		defer file.Close()
+
+		reader = file
+	}
+
+	scanner := bufio.NewScanner(reader)
+	if nul {
+		scanner.Split(scanNUL)
+	}
+
+	var sources []string
+
+	for scanner.Scan() {
+		if entry := scanner.Text(); isListEntry(entry, nul) {
+			sources = append(sources, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading source list: %w", err)
+	}
+
+	return sources, nil
+}
+
+// isListEntry reports whether entry of a --files-from list names a source:
+// any non-empty name under -0, otherwise a line that is neither blank nor a
+// comment.
+func isListEntry(entry string, nul bool) bool {
+	if nul {
+		return entry != ""
+	}
+
+	return strings.TrimSpace(entry) != "" && !strings.HasPrefix(entry, "#")
+}
+
+// scanNUL is a bufio.SplitFunc yielding NUL-terminated tokens, so that
+// names containing newlines survive. A final unterminated token is kept.
+func scanNUL(data []byte, atEOF bool) (int, []byte, error) {
+	if idx := bytes.IndexByte(data, 0); idx >= 0 {
+		return idx + 1, data[:idx], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestRunFilesFrom tests copying the sources named in a list file into a
+// target directory, ignoring blank lines and comments.
+func TestRunFilesFrom(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+	listFile := filepath.Join(tmpDir, "list.txt")
+	files := map[string]string{"one.txt": "one.txt", "two.txt": "two.txt", "three.txt": "three.txt"}
+
+	// Setup: Create the sources, the target directory and the list
+	writeFiles(t, tmpDir, files)
+
+	paths := make([]string, 0, len(files))
+	for name := range files {
+		paths = append(paths, filepath.Join(tmpDir, name))
+	}
+
+	list := "# sources to copy\n\n" + strings.Join(paths, "\n") + "\n"
+	if err := os.WriteFile(listFile, []byte(list), 0o600); err != nil {
+		t.Fatalf("failed to create list file: %v", err)
+	}
+
+	if err := os.Mkdir(destDir, 0o755); err != nil {
+		t.Fatalf("failed to create destination directory: %v", err)
+	}
+
+	// Test: Copy the listed sources
+	opts, err := parseArgs([]string{"-q", "--files-from=" + listFile, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := runFilesFrom(t.Context(), opts, destDir); err != nil {
+		t.Fatalf("runFilesFrom() failed: %v", err)
+	}
+
+	// Verify: Exactly the listed files were copied
+	checkDirContents(t, destDir, files)
+}
+
+// TestRunFilesFrom_Null tests that -0 reads NUL-terminated entries, so a
+// name containing a newline is copied intact.
+func TestRunFilesFrom_Null(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("file names can't contain newlines on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+	listFile := filepath.Join(tmpDir, "list")
+	sourceFile := filepath.Join(tmpDir, "line\nbreak.txt")
+
+	// Setup: Create the source, the target directory and a NUL-delimited list
+	if err := os.WriteFile(sourceFile, []byte("newline"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(listFile, []byte(sourceFile+"\x00"), 0o600); err != nil {
+		t.Fatalf("failed to create list file: %v", err)
+	}
+
+	if err := os.Mkdir(destDir, 0o755); err != nil {
+		t.Fatalf("failed to create destination directory: %v", err)
+	}
+
+	// Test: Copy the listed source with -0
+	opts, err := parseArgs([]string{"-q", "-0", "--files-from=" + listFile, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := runFilesFrom(t.Context(), opts, destDir); err != nil {
+		t.Fatalf("runFilesFrom() failed: %v", err)
+	}
+
+	// Verify: The file was copied under its full name
+	got, err := os.ReadFile(filepath.Join(destDir, "line\nbreak.txt"))
+	if err != nil {
+		t.Fatalf("expected file with newline in its name to be copied: %v", err)
+	}
+
+	if string(got) != "newline" {
+		t.Errorf("content = %q, want %q", got, "newline")
+	}
+}
+
+// TestRunFilesFrom_Stdin tests that the source list and messages go through
+// the streams set on the options.
+func TestRunFilesFrom_Stdin(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destDir := filepath.Join(tmpDir, "dest")
+
+	// Setup: Create the source and the target directory
+	if err := os.WriteFile(sourceFile, []byte("piped"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Mkdir(destDir, 0o755); err != nil {
+		t.Fatalf("failed to create destination directory: %v", err)
+	}
+
+	// Test: Copy the sources read from the options' stdin
+	opts, err := parseArgs([]string{"--files-from=-", destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	var out bytes.Buffer
+
+	opts.stdin = strings.NewReader(sourceFile + "\n")
+	opts.stdout = &out
+
+	if err := runFilesFrom(t.Context(), opts, destDir); err != nil {
+		t.Fatalf("runFilesFrom() failed: %v", err)
+	}
+
+	// Verify: The file was copied and reported on the options' stdout
+	destFile := filepath.Join(destDir, "source.txt")
+	if got, _ := os.ReadFile(destFile); string(got) != "piped" {
+		t.Errorf("content = %q, want %q", got, "piped")
+	}
+
+	want := "File copied from " + sourceFile + " to " + destFile + " successfully.\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+// TestRunFilesFrom_SourceRoot tests that --source-root resolves listed paths
+// against the root and rebuilds their structure under the destination.
+func TestRunFilesFrom_SourceRoot(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	rootDir := filepath.Join(tmpDir, "root")
+	destDir := filepath.Join(tmpDir, "dest")
+	listFile := filepath.Join(tmpDir, "list.txt")
+	listed := []string{"top.txt", "docs/guide.md", "src/pkg/main.go"}
+
+	// Setup: Create a tree with an unlisted file, the list and the target
+	files := map[string]string{"list.txt": strings.Join(listed, "\n") + "\n"}
+	for _, name := range append([]string{"src/unlisted.go"}, listed...) {
+		files["root/"+name] = name
+	}
+
+	writeFiles(t, tmpDir, files)
+
+	if err := os.Mkdir(destDir, 0o755); err != nil {
+		t.Fatalf("failed to create destination directory: %v", err)
+	}
+
+	// Test: Copy the listed paths relative to the root
+	args := []string{"-q", "--files-from=" + listFile, "--source-root=" + rootDir, destDir}
+
+	opts, err := parseArgs(args)
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := runFilesFrom(t.Context(), opts, destDir); err != nil {
+		t.Fatalf("runFilesFrom() failed: %v", err)
+	}
+
+	// Verify: Each listed file sits at its relative path, and nothing else
+	for _, name := range listed {
+		content, err := os.ReadFile(filepath.Join(destDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("failed to read copy of %s: %v", name, err)
+		}
+
+		if string(content) != name {
+			t.Errorf("content of %s = %q, want %q", name, content, name)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "src", "unlisted.go")); !os.IsNotExist(err) {
+		t.Errorf("expected unlisted file not to be copied, got: %v", err)
+	}
+}
+
+// TestRunFilesFrom_SourceRootEscape tests that --source-root refuses listed
+// paths leading outside the root.
+func TestRunFilesFrom_SourceRootEscape(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	listFile := filepath.Join(tmpDir, "list.txt")
+
+	if err := os.WriteFile(listFile, []byte("../secret.txt\n"), 0o600); err != nil {
+		t.Fatalf("failed to create list file: %v", err)
+	}
+
+	opts, err := parseArgs([]string{"--files-from=" + listFile, "--source-root=" + tmpDir, tmpDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	err = runFilesFrom(t.Context(), opts, tmpDir)
+	if err == nil || !strings.Contains(err.Error(), "--source-root") {
+		t.Errorf("expected an error for a path outside the root, got: %v", err)
+	}
+}
+
+// TestRunFilesFrom_Skip tests that --files-from copies honour -n and the
+// size filters like other copies do.
+func TestRunFilesFrom_Skip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		flag string
+		want string
+	}{
+		{name: "no clobber", flag: "-n", want: "existing"},
+		{name: "min size", flag: "--min-size=1M", want: "existing"},
+		{name: "overwrite", flag: "-f", want: newContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "a.txt")
+			destDir := filepath.Join(tmpDir, "dest")
+			destFile := filepath.Join(destDir, "a.txt")
+			listFile := filepath.Join(tmpDir, "list.txt")
+
+			// Setup: A listed source whose destination already exists
+			writeFiles(t, tmpDir, map[string]string{
+				"a.txt":      newContent,
+				"dest/a.txt": "existing",
+				"list.txt":   sourceFile + "\n",
+			})
+
+			// Test: Copy the list with the flag
+			opts, err := parseArgs([]string{"-q", tt.flag, "--files-from=" + listFile, destDir})
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if err := runFilesFrom(t.Context(), opts, destDir); err != nil {
+				t.Fatalf("runFilesFrom() failed: %v", err)
+			}
+
+			// Verify: The destination was replaced only when allowed
+			if got, _ := os.ReadFile(destFile); string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunFilesFrom_IgnoreErrors tests that under --ignore-errors a listed
+// source that fails to copy is reported and the rest of the list copied.
+func TestRunFilesFrom_IgnoreErrors(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	missingFile := filepath.Join(tmpDir, "missing.txt")
+	sourceFile := filepath.Join(tmpDir, "a.txt")
+	destDir := filepath.Join(tmpDir, "dest")
+	listFile := filepath.Join(tmpDir, "list.txt")
+
+	// Setup: A list whose first source is missing
+	if err := os.Mkdir(destDir, 0o755); err != nil {
+		t.Fatalf("failed to create destination directory: %v", err)
+	}
+
+	writeFiles(t, tmpDir, map[string]string{
+		"a.txt":    "content",
+		"list.txt": missingFile + "\n" + sourceFile + "\n",
+	})
+
+	// Test: Copy the list, ignoring errors
+	opts, err := parseArgs([]string{"-q", "--ignore-errors", "--files-from=" + listFile, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	var stderr bytes.Buffer
+
+	opts.stderr = &stderr
+
+	err = runFilesFrom(t.Context(), opts, destDir)
+
+	// Verify: The failure is reported and summarized, and the rest copied
+	const summary = "1 files failed"
+	if err == nil || err.Error() != summary {
+		t.Errorf("expected %q, got: %v", summary, err)
+	}
+
+	if !strings.Contains(stderr.String(), "Error: "+missingFile+": ") {
+		t.Errorf("expected the failure on stderr, got %q", stderr.String())
+	}
+
+	if got, _ := os.ReadFile(filepath.Join(destDir, "a.txt")); string(got) != "content" {
+		t.Errorf("content = %q, want %q", got, "content")
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shifts of the binary size units, e.g. 1<<kibShift bytes in a KiB.
+const (
+	kibShift = 10
+	mibShift = 20
+	gibShift = 30
+	tibShift = 40
+)
+
+// sizeUnits maps the suffixes accepted by parseSize to their multipliers.
+func sizeUnits() map[string]int64 {
+	return map[string]int64{
+		"K": 1 << kibShift,
+		"M": 1 << mibShift,
+		"G": 1 << gibShift,
+		"T": 1 << tibShift,
+	}
+}
+
+// parseSize parses a byte count with an optional binary suffix, such as
+// "512", "1k" or "100M".
+func parseSize(value string) (int64, error) {
+	number := value
+	multiplier := int64(1)
+
+	if number != "" {
+		if unit, ok := sizeUnits()[strings.ToUpper(number[len(number)-1:])]; ok {
+			number = number[:len(number)-1]
+			multiplier = unit
+		}
+	}
+
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size < 0 || size > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid size '%s'", value) //nolint:err113
+	}
+
+	return size * multiplier, nil
+}
+
+// --dest-exists-policy values, which decide what happens to a destination
+// that already exists.
+const (
+	// policyOverwrite replaces the destination (-f).
+	policyOverwrite = "overwrite"
+	// policySkip keeps the destination (-n).
+	policySkip = "skip"
+	// policyPrompt asks whether to replace the destination (-i).
+	policyPrompt = "prompt"
+	// policyUpdate only replaces a destination older than the source (-u).
+	policyUpdate = "update"
+	// policyError fails the copy.
+	policyError = "error"
+	// policyRename copies to the first free name of the form "dest (N).ext"
+	// instead, as file managers do.
+	policyRename = "rename"
+)
+
+// updatePolicies maps the legacy --update modes to their policies.
+func updatePolicies() map[string]string {
+	return map[string]string{
+		"all":   policyOverwrite,
+		"none":  policySkip,
+		"older": policyUpdate,
+	}
+}
+
+// freeName implements the rename policy, returning dest if nothing exists
+// there and otherwise the first of "name (1).ext", "name (2).ext" and so on
+// that is free.
+func freeName(dest string) string {
+	if _, err := os.Lstat(dest); errors.Is(err, fs.ErrNotExist) {
+		return dest
+	}
+
+	dir, base := filepath.Split(dest)
+
+	ext := filepath.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+
+	stem := strings.TrimSuffix(base, ext)
+
+	for index := 1; ; index++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, index, ext))
+		if _, err := os.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+	}
+}
+
+// keepDest reports whether --dest-exists-policy keeps an existing dest
+// instead of replacing it with a source described by info. Under the error
+// policy an existing dest is an error.
+func (opts *options) keepDest(dest string, info fs.FileInfo) (bool, error) {
+	switch opts.destExists {
+	case "", policyOverwrite, policyRename:
+		return false, nil
+	}
+
+	destInfo, err := os.Stat(dest)
+	if err != nil {
+		return false, nil //nolint:nilerr
+	}
+
+	switch opts.destExists {
+	case policySkip:
+		return true, nil
+	case policyUpdate:
+		return !opts.newer(info.ModTime(), destInfo.ModTime()), nil
+	case policyPrompt:
+		return !opts.confirm(fmt.Sprintf("overwrite '%s'?", dest)), nil
+	default:
+		return false, fmt.Errorf( //nolint:err113
+			"destination '%s' already exists (--dest-exists-policy=error)",
+			dest,
+		)
+	}
+}
+
+// newer reports whether the modification time mtime is later than other
+// once both are truncated to --timestamp-resolution, so that a filesystem
+// storing coarser times than the other doesn't make files look changed.
+func (opts *options) newer(mtime, other time.Time) bool {
+	if opts.timestampResolution > 0 {
+		mtime = mtime.Truncate(opts.timestampResolution)
+		other = other.Truncate(opts.timestampResolution)
+	}
+
+	return mtime.After(other)
+}
+
+// skipSource runs skip for source if it is a regular file, which is all
+// that the filters and --dest-exists-policy apply to.
+func (opts *options) skipSource(source, dest string) (bool, error) {
+	info, err := os.Stat(source)
+	if err != nil || !info.Mode().IsRegular() {
+		return false, nil //nolint:nilerr
+	}
+
+	return opts.skip(source, dest, info)
+}
+
+// checkDestNotNewer implements --replace-newer-only, refusing to replace an
+// existing dest modified more recently than the source described by info,
+// such as a local edit the source predates.
+func checkDestNotNewer(opts *options, dest string, info fs.FileInfo) error {
+	destInfo, err := os.Stat(dest)
+	if err != nil {
+		return nil //nolint:nilerr
+	}
+
+	if opts.newer(destInfo.ModTime(), info.ModTime()) {
+		return fmt.Errorf("refusing to overwrite newer destination '%s'", dest) //nolint:err113
+	}
+
+	return nil
+}
+
+// confirm writes question to the error output and reads a line of input,
+// reporting whether it was an answer starting with 'y'. It reads one byte
+// at a time so that answers to later questions stay unread.
+func (opts *options) confirm(question string) bool {
+	fmt.Fprintf(opts.errorOutput(), "%s ", question)
+
+	var (
+		answer []byte
+		char   [1]byte
+	)
+
+	for !bytes.HasSuffix(answer, []byte("\n")) {
+		count, err := opts.input().Read(char[:])
+		answer = append(answer, char[:count]...)
+
+		if err != nil {
+			break
+		}
+	}
+
+	reply := strings.TrimSpace(string(answer))
+
+	return strings.HasPrefix(reply, "y") || strings.HasPrefix(reply, "Y")
+}
+
+// parseSince parses a point in time given either as an RFC 3339 timestamp,
+// a date such as "2024-01-01", or a duration before now such as "36h" or "7d".
+func parseSince(value string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if since, err := time.Parse(layout, value); err == nil {
+			return since, nil
+		}
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		count, err := strconv.Atoi(days)
+		if err == nil && count >= 0 {
+			return now.AddDate(0, 0, -count), nil
+		}
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time '%s'", value) //nolint:err113
+}
+
+// filteredOut returns why the regular file described by info is left out by
+// --min-size, --max-size or --newer-than, or "" if it isn't.
+func (opts *options) filteredOut(info fs.FileInfo) string {
+	switch {
+	case info.Size() < opts.minSize:
+		return "smaller than --min-size"
+	case opts.maxSize > 0 && info.Size() > opts.maxSize:
+		return "larger than --max-size"
+	case !opts.newerThan.IsZero() && !info.ModTime().After(opts.newerThan):
+		return "not modified since --newer-than"
+	default:
+		return ""
+	}
+}
+
+// skip reports whether the regular file source, described by info, is
+// filtered out by --min-size, --max-size, --newer-than or
+// --dest-exists-policy instead of being copied to dest, noting the reason
+// under -v. The policy is checked last, since it may prompt.
+func (opts *options) skip(source, dest string, info fs.FileInfo) (bool, error) {
+	reason := opts.filteredOut(info)
+	if reason == "" {
+		keep, err := opts.keepDest(dest, info)
+		if err != nil || !keep {
+			return false, err
+		}
+
+		reason = "destination exists (--dest-exists-policy=" + opts.destExists + ")"
+	}
+
+	opts.stats.skipped++
+
+	if opts.verbose {
+		fmt.Fprintf(opts.output(), "Skipping %s: %s\n", source, reason)
+	}
+
+	return true, nil
+}
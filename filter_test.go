@@ -0,0 +1,670 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSizedFiles creates files of the given sizes under dir.
+func writeSizedFiles(t *testing.T, dir string, sizes map[string]int) {
+	t.Helper()
+
+	for name, size := range sizes {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+}
+
+// assertCopied checks which of names exist under dir.
+func assertCopied(t *testing.T, dir string, want map[string]bool) {
+	t.Helper()
+
+	for name, copied := range want {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if copied && err != nil {
+			t.Errorf("expected %s to be copied: %v", name, err)
+		}
+
+		if !copied && !os.IsNotExist(err) {
+			t.Errorf("expected %s to be skipped, got err: %v", name, err)
+		}
+	}
+}
+
+// writeFileAt creates the file at path with content and mtime.
+func writeFileAt(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("failed to set times of %s: %v", path, err)
+	}
+}
+
+// TestParseSize tests parsing of sizes with binary suffixes.
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{input: "512", want: 512},
+		{input: "1k", want: 1024},
+		{input: "100M", want: 100 << 20},
+		{input: "2G", want: 2 << 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseSize(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"", "M", "-1", "10P", "9999999999T"} {
+		t.Run("invalid "+input, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := parseSize(input); err == nil {
+				t.Errorf("parseSize(%q) succeeded, want error", input)
+			}
+		})
+	}
+}
+
+// TestCopyTree_MaxSize tests that --max-size skips oversized files.
+func TestCopyTree_MaxSize(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create a tree with one file over the limit
+	writeSizedFiles(t, sourceDir, map[string]int{
+		"small.txt":   100,
+		"exact.txt":   1024,
+		"sub/big.bin": 4096,
+	})
+
+	// Test: Copy with a 1k limit
+	opts, err := parseArgs([]string{"-r", "--max-size=1k", sourceDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("copyTree() failed: %v", err)
+	}
+
+	// Verify: Only files within the limit were copied
+	assertCopied(t, destDir, map[string]bool{
+		"small.txt":   true,
+		"exact.txt":   true,
+		"sub/big.bin": false,
+	})
+}
+
+// TestCopyTree_SizeWindow tests that --min-size and --max-size together copy
+// only files within the window.
+func TestCopyTree_SizeWindow(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create files below, at the edges of, inside and above [1k, 10k]
+	writeSizedFiles(t, sourceDir, map[string]int{
+		"empty.txt":  0,
+		"below.txt":  1023,
+		"low.txt":    1024,
+		"middle.txt": 5000,
+		"high.txt":   10 * 1024,
+		"above.txt":  10*1024 + 1,
+	})
+
+	// Test: Copy with a [1k, 10k] window
+	opts, err := parseArgs([]string{"-r", "--min-size=1k", "--max-size=10k", sourceDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("copyTree() failed: %v", err)
+	}
+
+	// Verify: Only in-range files were copied
+	assertCopied(t, destDir, map[string]bool{
+		"empty.txt":  false,
+		"below.txt":  false,
+		"low.txt":    true,
+		"middle.txt": true,
+		"high.txt":   true,
+		"above.txt":  false,
+	})
+}
+
+// TestParseArgs_InvertedSizeWindow tests that --min-size above --max-size is rejected.
+func TestParseArgs_InvertedSizeWindow(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parseArgs([]string{"--min-size=10k", "--max-size=1k", "a", "b"}); err == nil {
+		t.Error("expected error for inverted size window, got nil")
+	}
+}
+
+// TestParseSince tests absolute and relative --newer-than values.
+func TestParseSince(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{input: "2024-01-01", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "2024-01-01T08:30:00Z", want: time.Date(2024, 1, 1, 8, 30, 0, 0, time.UTC)},
+		{input: "7d", want: time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{input: "90m", want: time.Date(2024, 3, 10, 10, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseSince(tt.input, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !got.Equal(tt.want) {
+				t.Errorf("parseSince(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"yesterday", "-1d"} {
+		t.Run("invalid "+input, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := parseSince(input, now); err == nil {
+				t.Errorf("parseSince(%q) succeeded, want error", input)
+			}
+		})
+	}
+}
+
+// TestCopyTree_NewerThan tests that --newer-than skips older files, given
+// either an absolute date or a relative duration.
+func TestCopyTree_NewerThan(t *testing.T) {
+	t.Parallel()
+
+	for _, since := range []string{"2024-01-01", "7d"} {
+		t.Run(since, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceDir := filepath.Join(tmpDir, "src")
+			destDir := filepath.Join(tmpDir, "dst")
+			oldTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			// Setup: Create a fresh file and an old one
+			writeSizedFiles(t, sourceDir, map[string]int{"new.txt": 10, "sub/old.txt": 10})
+
+			oldFile := filepath.Join(sourceDir, "sub", "old.txt")
+			if err := os.Chtimes(oldFile, oldTime, oldTime); err != nil {
+				t.Fatalf("failed to set times: %v", err)
+			}
+
+			// Test: Copy only files newer than the given point
+			opts, err := parseArgs([]string{"-r", "--newer-than=" + since, sourceDir, destDir})
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+				t.Fatalf("copyTree() failed: %v", err)
+			}
+
+			// Verify: The old file was skipped
+			assertCopied(t, destDir, map[string]bool{"new.txt": true, "sub/old.txt": false})
+		})
+	}
+}
+
+// TestCopyFile_UpdateModes tests each --update mode against a destination
+// that is newer and one that is older than the source.
+func TestCopyFile_UpdateModes(t *testing.T) {
+	t.Parallel()
+
+	sourceTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := sourceTime.Add(time.Hour)
+	older := sourceTime.Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		flag     string
+		destTime time.Time
+		copied   bool
+	}{
+		{name: "all over newer", flag: "--update=all", destTime: newer, copied: true},
+		{name: "all over older", flag: "--update=all", destTime: older, copied: true},
+		{name: "none over newer", flag: "--update=none", destTime: newer, copied: false},
+		{name: "none over older", flag: "--update=none", destTime: older, copied: false},
+		{name: "older over newer", flag: "--update=older", destTime: newer, copied: false},
+		{name: "older over older", flag: "--update=older", destTime: older, copied: true},
+		{name: "-u over newer", flag: "-u", destTime: newer, copied: false},
+		{name: "-u over older", flag: "-u", destTime: older, copied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceDir := filepath.Join(tmpDir, "src")
+			destDir := filepath.Join(tmpDir, "dst")
+			sourceFile := filepath.Join(sourceDir, "file.txt")
+			destFile := filepath.Join(destDir, "file.txt")
+
+			// Setup: Create a source and an existing destination with the given mtimes
+			writeSizedFiles(t, tmpDir, map[string]int{"src/file.txt": 10, "dst/file.txt": 5})
+
+			for path, mtime := range map[string]time.Time{sourceFile: sourceTime, destFile: tt.destTime} {
+				if err := os.Chtimes(path, mtime, mtime); err != nil {
+					t.Fatalf("failed to set times of %s: %v", path, err)
+				}
+			}
+
+			// Test: Copy the tree with the update mode
+			opts, err := parseArgs([]string{"-r", "-q", tt.flag, sourceDir, destDir})
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+				t.Fatalf("copyTree() failed: %v", err)
+			}
+
+			// Verify: The destination was replaced only when expected
+			info, err := os.Stat(destFile)
+			if err != nil {
+				t.Fatalf("failed to stat destination file: %v", err)
+			}
+
+			if copied := info.Size() == 10; copied != tt.copied {
+				t.Errorf("copied = %v, want %v", copied, tt.copied)
+			}
+		})
+	}
+}
+
+// TestCopyFile_TimestampResolution tests that -u treats a source and
+// destination whose mtimes differ by less than --timestamp-resolution as
+// equal, while an exact comparison sees the source as newer.
+func TestCopyFile_TimestampResolution(t *testing.T) {
+	t.Parallel()
+
+	destTime := time.Date(2022, 1, 1, 0, 0, 0, 200*int(time.Millisecond), time.UTC)
+	sourceTime := destTime.Add(1300 * time.Millisecond)
+
+	tests := []struct {
+		name   string
+		args   []string
+		copied bool
+	}{
+		{name: "exact", args: nil, copied: true},
+		{name: "2s", args: []string{"--timestamp-resolution=2s"}, copied: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceDir := filepath.Join(tmpDir, "src")
+			destDir := filepath.Join(tmpDir, "dst")
+			sourceFile := filepath.Join(sourceDir, "file.txt")
+			destFile := filepath.Join(destDir, "file.txt")
+
+			// Setup: Give the source an mtime 1.3s after the destination's
+			writeSizedFiles(t, sourceDir, map[string]int{"file.txt": 10})
+			writeSizedFiles(t, destDir, map[string]int{"file.txt": 5})
+
+			if err := os.Chtimes(sourceFile, sourceTime, sourceTime); err != nil {
+				t.Fatalf("failed to set source times: %v", err)
+			}
+
+			if err := os.Chtimes(destFile, destTime, destTime); err != nil {
+				t.Fatalf("failed to set destination times: %v", err)
+			}
+
+			// Test: Copy the tree with -u
+			args := append([]string{"-r", "-q", "-u"}, tt.args...)
+
+			opts, err := parseArgs(append(args, sourceDir, destDir))
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+				t.Fatalf("copyTree() failed: %v", err)
+			}
+
+			// Verify: The destination was replaced only under exact comparison
+			info, err := os.Stat(destFile)
+			if err != nil {
+				t.Fatalf("failed to stat destination file: %v", err)
+			}
+
+			if copied := info.Size() == 10; copied != tt.copied {
+				t.Errorf("copied = %v, want %v", copied, tt.copied)
+			}
+		})
+	}
+}
+
+// TestCopyFile_ReplaceNewerOnly tests that --replace-newer-only fails on a
+// destination newer than the source and leaves it intact, while an older one
+// is replaced.
+func TestCopyFile_ReplaceNewerOnly(t *testing.T) {
+	t.Parallel()
+
+	sourceTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		destTime time.Time
+		wantErr  bool
+	}{
+		{name: "newer destination", destTime: sourceTime.Add(time.Hour), wantErr: true},
+		{name: "older destination", destTime: sourceTime.Add(-time.Hour), wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "file.txt")
+			destFile := filepath.Join(tmpDir, "dst", "file.txt")
+
+			// Setup: Create a source and an existing destination with the given mtimes
+			writeSizedFiles(t, tmpDir, map[string]int{"file.txt": 10})
+			writeSizedFiles(t, filepath.Dir(destFile), map[string]int{"file.txt": 5})
+
+			if err := os.Chtimes(sourceFile, sourceTime, sourceTime); err != nil {
+				t.Fatalf("failed to set source times: %v", err)
+			}
+
+			if err := os.Chtimes(destFile, tt.destTime, tt.destTime); err != nil {
+				t.Fatalf("failed to set destination times: %v", err)
+			}
+
+			// Test: Copy refusing to replace newer destinations
+			err := run([]string{"cp", "-q", "--replace-newer-only", sourceFile, destFile})
+
+			// Verify: A newer destination is an error and stays as it was
+			want := "refusing to overwrite newer destination '" + destFile + "'"
+			if (err != nil) != tt.wantErr || err != nil && err.Error() != want {
+				t.Errorf("error = %v, want error %v (%q)", err, tt.wantErr, want)
+			}
+
+			info, err := os.Stat(destFile)
+			if err != nil {
+				t.Fatalf("failed to stat destination file: %v", err)
+			}
+
+			if copied := info.Size() == 10; copied == tt.wantErr {
+				t.Errorf("copied = %v, want %v", copied, !tt.wantErr)
+			}
+		})
+	}
+}
+
+// destExistsCase is a copy over an existing destination under a
+// --dest-exists-policy or the legacy flags mapped onto it.
+type destExistsCase struct {
+	name  string
+	args  []string
+	input string
+	// destOffset is the destination's mtime relative to the source's.
+	destOffset time.Duration
+	copied     bool
+	prompted   bool
+	wantErr    bool
+}
+
+// runDestExistsCases copies a source over an existing, shorter destination
+// for each of tests.
+func runDestExistsCases(t *testing.T, tests []destExistsCase) {
+	t.Helper()
+
+	sourceTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "source.txt")
+			destFile := filepath.Join(tmpDir, "dest.txt")
+
+			// Setup: Create a source and an existing, shorter destination
+			writeFileAt(t, sourceFile, newContent, sourceTime)
+			writeFileAt(t, destFile, "old", sourceTime.Add(tt.destOffset))
+
+			// Test: Copy over the destination under the policy
+			opts, err := parseArgs(append([]string{"-q"}, append(tt.args, sourceFile, destFile)...))
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			var prompts bytes.Buffer
+
+			opts.stdin = strings.NewReader(tt.input)
+			opts.stderr = &prompts
+
+			err = runRecursive(t.Context(), opts, sourceFile, destFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runRecursive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// Verify: The destination was replaced only when expected
+			got, err := os.ReadFile(destFile)
+			if err != nil {
+				t.Fatalf("failed to read destination file: %v", err)
+			}
+
+			if copied := string(got) == newContent; copied != tt.copied {
+				t.Errorf("copied = %v, want %v", copied, tt.copied)
+			}
+
+			prompted := strings.Contains(prompts.String(), "overwrite '"+destFile+"'?")
+			if prompted != tt.prompted {
+				t.Errorf("prompted = %v, want %v", prompted, tt.prompted)
+			}
+		})
+	}
+}
+
+// TestRunRecursive_DestExistsPolicy tests each --dest-exists-policy that
+// doesn't prompt against an existing destination.
+func TestRunRecursive_DestExistsPolicy(t *testing.T) {
+	t.Parallel()
+
+	runDestExistsCases(t, []destExistsCase{
+		{
+			name:       "overwrite",
+			args:       []string{"--dest-exists-policy=overwrite"},
+			input:      "",
+			destOffset: 0,
+			copied:     true,
+			prompted:   false,
+			wantErr:    false,
+		},
+		{
+			name:       "skip",
+			args:       []string{"--dest-exists-policy=skip"},
+			input:      "",
+			destOffset: 0,
+			copied:     false,
+			prompted:   false,
+			wantErr:    false,
+		},
+		{
+			name:       "update over newer",
+			args:       []string{"--dest-exists-policy=update"},
+			input:      "",
+			destOffset: time.Hour,
+			copied:     false,
+			prompted:   false,
+			wantErr:    false,
+		},
+		{
+			name:       "update over older",
+			args:       []string{"--dest-exists-policy=update"},
+			input:      "",
+			destOffset: -time.Hour,
+			copied:     true,
+			prompted:   false,
+			wantErr:    false,
+		},
+		{
+			name:       "error",
+			args:       []string{"--dest-exists-policy=error"},
+			input:      "",
+			destOffset: 0,
+			copied:     false,
+			prompted:   false,
+			wantErr:    true,
+		},
+	})
+}
+
+// TestRunRecursive_DestExistsPrompt tests that --dest-exists-policy=prompt
+// and -i copy over an existing destination only when the answer is yes.
+func TestRunRecursive_DestExistsPrompt(t *testing.T) {
+	t.Parallel()
+
+	runDestExistsCases(t, []destExistsCase{
+		{
+			name:       "prompt yes",
+			args:       []string{"--dest-exists-policy=prompt"},
+			input:      "y\n",
+			destOffset: 0,
+			copied:     true,
+			prompted:   true,
+			wantErr:    false,
+		},
+		{
+			name:       "prompt no",
+			args:       []string{"--dest-exists-policy=prompt"},
+			input:      "n\n",
+			destOffset: 0,
+			copied:     false,
+			prompted:   true,
+			wantErr:    false,
+		},
+		{
+			name:       "prompt no input",
+			args:       []string{"--dest-exists-policy=prompt"},
+			input:      "",
+			destOffset: 0,
+			copied:     false,
+			prompted:   true,
+			wantErr:    false,
+		},
+		{
+			name:       "-i",
+			args:       []string{"-i"},
+			input:      "yes\n",
+			destOffset: 0,
+			copied:     true,
+			prompted:   true,
+			wantErr:    false,
+		},
+	})
+}
+
+// TestRunRecursive_DestExistsLegacyFlags tests that -n and -f map onto
+// --dest-exists-policy, the last one given winning.
+func TestRunRecursive_DestExistsLegacyFlags(t *testing.T) {
+	t.Parallel()
+
+	runDestExistsCases(t, []destExistsCase{
+		{
+			name:       "-n",
+			args:       []string{"-n"},
+			input:      "",
+			destOffset: 0,
+			copied:     false,
+			prompted:   false,
+			wantErr:    false,
+		},
+		{
+			name:       "-f after -n",
+			args:       []string{"-n", "-f"},
+			input:      "",
+			destOffset: 0,
+			copied:     true,
+			prompted:   false,
+			wantErr:    false,
+		},
+	})
+}
+
+// TestParseArgs_UnknownDestExistsPolicy tests that unknown policies are
+// rejected.
+func TestParseArgs_UnknownDestExistsPolicy(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseArgs([]string{"--dest-exists-policy=merge", "a", "b"})
+	if err == nil || !strings.Contains(err.Error(), "unknown policy 'merge'") {
+		t.Fatalf("expected unknown policy error, got: %v", err)
+	}
+}
+
+// TestRun_OnConflictRename tests that --on-conflict=rename copies next to an
+// existing destination under the first free numbered name.
+func TestRun_OnConflictRename(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "report.txt")
+	targetDir := filepath.Join(tmpDir, "out")
+
+	// Setup: Create source file and a target directory already holding a copy
+	writeFiles(t, tmpDir, map[string]string{"report.txt": "report", "out/report.txt": "original"})
+
+	// Test: Copy the same source into the directory twice
+	args := []string{"cp", "-q", "--on-conflict=rename", "--into=" + targetDir, sourceFile}
+	for range 2 {
+		if err := run(args); err != nil {
+			t.Fatalf("run() failed: %v", err)
+		}
+	}
+
+	// Verify: The existing file is untouched and two numbered copies exist
+	checkDirContents(t, targetDir, map[string]string{
+		"report.txt":     "original",
+		"report (1).txt": "report",
+		"report (2).txt": "report",
+	})
+}
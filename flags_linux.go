@@ -0,0 +1,88 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// ioctlGetFlags and ioctlSetFlags are the FS_IOC_GETFLAGS and
+	// FS_IOC_SETFLAGS ioctl requests.
+	ioctlGetFlags = 0x80086601
+	ioctlSetFlags = 0x40086602
+
+	// preservedFileFlags are the chattr flags --preserve=flags carries over:
+	// immutable (i), append-only (a), no-dump (d) and no-atime (A).
+	preservedFileFlags = 0x10 | 0x20 | 0x40 | 0x80
+)
+
+// copyFileFlags copies the immutable, append-only, no-dump and no-atime
+// flags of source onto dest. Setting immutable or append-only requires
+// CAP_LINUX_IMMUTABLE.
+func copyFileFlags(source, dest string) error {
+	flags, err := fileFlags(source)
+	if err != nil {
+		return err
+	}
+
+	if flags&preservedFileFlags == 0 {
+		return nil
+	}
+
+	destFlags, err := fileFlags(dest)
+	if err != nil {
+		return err
+	}
+
+	return setFileFlags(dest, destFlags&^preservedFileFlags|flags&preservedFileFlags)
+}
+
+// fileFlags returns the chattr flags of the file at path.
+func fileFlags(path string) (int32, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening file for flags: %w", err)
+	}
+
+	defer file.Close()
+
+	var flags int32
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		file.Fd(),
+		ioctlGetFlags,
+		uintptr(unsafe.Pointer(&flags)),
+	)
+	if errno != 0 {
+		return 0, fmt.Errorf("FS_IOC_GETFLAGS: %w", errno)
+	}
+
+	return flags, nil
+}
+
+// setFileFlags replaces the chattr flags of the file at path.
+func setFileFlags(path string, flags int32) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening file for flags: %w", err)
+	}
+
+	defer file.Close()
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		file.Fd(),
+		ioctlSetFlags,
+		uintptr(unsafe.Pointer(&flags)),
+	)
+	if errno != 0 {
+		return fmt.Errorf("FS_IOC_SETFLAGS: %w", errno)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFile_PreserveFlags tests that --preserve=flags carries the
+// append-only flag over to the destination.
+func TestCopyFile_PreserveFlags(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() != 0 {
+		t.Skip("setting the append-only flag requires root")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.log")
+	destFile := filepath.Join(tmpDir, "dest.log")
+
+	// Setup: Create an append-only source file
+	if err := os.WriteFile(sourceFile, []byte("entry\n"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	flags, err := fileFlags(sourceFile)
+	if err != nil {
+		t.Skipf("file flags not supported: %v", err)
+	}
+
+	if err := setFileFlags(sourceFile, flags|0x20); err != nil {
+		t.Skipf("can't set the append-only flag: %v", err)
+	}
+
+	// The flag keeps t.TempDir from cleaning up, so clear it again
+	t.Cleanup(func() { clearAppendOnly(t, sourceFile, destFile) })
+
+	// Test: Copy preserving flags
+	opts, err := parseArgs([]string{"--preserve=flags", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The destination is append-only too
+	destFlags, err := fileFlags(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination flags: %v", err)
+	}
+
+	if destFlags&0x20 == 0 {
+		t.Errorf("destination flags = %#x, want append-only (0x20) set", destFlags)
+	}
+}
+
+// clearAppendOnly clears the append-only flag of those of paths that exist.
+func clearAppendOnly(t *testing.T, paths ...string) {
+	t.Helper()
+
+	for _, path := range paths {
+		current, err := fileFlags(path)
+		if err != nil {
+			continue
+		}
+
+		if err := setFileFlags(path, current&^0x20); err != nil {
+			t.Fatalf("failed to clear the append-only flag: %v", err)
+		}
+	}
+}
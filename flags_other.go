@@ -0,0 +1,10 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+import "errors"
+
+// copyFileFlags reports that chattr flags can't be preserved here.
+func copyFileFlags(_, _ string) error {
+	return errors.New("file flags can't be preserved on this platform") //nolint:err113
+}
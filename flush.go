@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// flushWriter writes to a destination file and syncs it to disk each time
+// another interval bytes have been written, bounding the data lost on a crash.
+type flushWriter struct {
+	file     *os.File
+	sync     func(file *os.File) error
+	interval int64
+	pending  int64
+}
+
+// newFlushWriter returns a writer implementing --flush-interval for file.
+func newFlushWriter(opts *options, file *os.File) *flushWriter {
+	sync := opts.syncFile
+	if sync == nil {
+		sync = (*os.File).Sync
+	}
+
+	return &flushWriter{file: file, sync: sync, interval: opts.flushInterval, pending: 0}
+}
+
+// Write implements io.Writer.
+func (fw *flushWriter) Write(data []byte) (int, error) {
+	written, err := fw.file.Write(data)
+	fw.pending += int64(written)
+
+	if err != nil {
+		return written, err //nolint:wrapcheck
+	}
+
+	if fw.pending >= fw.interval {
+		fw.pending = 0
+
+		if err := fw.sync(fw.file); err != nil {
+			return written, fmt.Errorf("syncing destination file: %w", err)
+		}
+	}
+
+	return written, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFile_FlushInterval tests that --flush-interval syncs the
+// destination periodically without corrupting it.
+func TestCopyFile_FlushInterval(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+	content := bytes.Repeat([]byte("flush me "), 1<<20)
+
+	// Setup: Create a 9 MiB source
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy syncing every MiB with a counting syncer
+	syncs := 0
+	opts := new(options)
+	opts.flushInterval = 1 << 20
+	opts.syncFile = func(*os.File) error {
+		syncs++
+
+		return nil
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Content matches and a sync happened for every MiB
+	got, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if !bytes.Equal(got, content) {
+		t.Error("content mismatch")
+	}
+
+	if syncs != 9 {
+		t.Errorf("syncs = %d, want 9", syncs)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// goosWindows is the value of runtime.GOOS on Windows.
+const goosWindows = "windows"
+
+// hasGlobMeta reports whether path contains a wildcard understood by filepath.Match.
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// isWindowsGlob reports whether source is a wildcard cp expands itself,
+// which it does on Windows, where the shell passes wildcards through.
+func isWindowsGlob(source string) bool {
+	return runtime.GOOS == goosWindows && hasGlobMeta(source)
+}
+
+// runGlob expands a wildcard source and copies every match into the
+// directory dest. It is used on Windows, where the shell passes wildcards
+// through unexpanded.
+func runGlob(ctx context.Context, opts *options, pattern, dest string) error {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+
+	if len(matches) == 0 {
+		return fmt.Errorf("no files match '%s'", pattern) //nolint:err113
+	}
+
+	return copyInto(ctx, opts, matches, dest)
+}
+
+// copyInto copies every source into the directory dest, named by --rename
+// if given.
+func copyInto(ctx context.Context, opts *options, sources []string, dest string) error {
+	if destInfo, err := os.Stat(dest); err != nil || !destInfo.IsDir() {
+		return fmt.Errorf("target '%s' is not a directory", dest) //nolint:err113
+	}
+
+	batch := newBatchCopy(opts)
+
+	for idx, source := range sources {
+		name, err := opts.destName(source, idx+1)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, name)
+
+		if opts.recursive || opts.dereference == derefNever {
+			into := dest
+			if opts.rename != nil {
+				into = target
+			}
+
+			if err := runRecursive(ctx, opts, source, into); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := batch.copyOne(ctx, source, target); err != nil {
+			return err
+		}
+	}
+
+	return batch.err()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestRunGlob_Windows tests that a literal *.txt source is expanded on Windows.
+func TestRunGlob_Windows(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != goosWindows {
+		t.Skip("wildcards are expanded by the shell outside Windows")
+	}
+
+	tmpDir := t.TempDir()
+	destDir := filepath.Join(tmpDir, "dest")
+
+	// Setup: Create matching and non-matching files and the target directory
+	for _, name := range []string{"a.txt", "b.txt", "c.log"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0o600); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	if err := os.Mkdir(destDir, 0o755); err != nil {
+		t.Fatalf("failed to create destination directory: %v", err)
+	}
+
+	// Test: Copy the wildcard source
+	opts := new(options)
+	opts.quiet = true
+
+	if err := runGlob(t.Context(), opts, filepath.Join(tmpDir, "*.txt"), destDir); err != nil {
+		t.Fatalf("runGlob() failed: %v", err)
+	}
+
+	// Verify: Only the .txt files were copied
+	for _, name := range []string{"a.txt", "b.txt"} {
+		got, err := os.ReadFile(filepath.Join(destDir, name))
+		if err != nil {
+			t.Fatalf("expected %s to be copied: %v", name, err)
+		}
+
+		if string(got) != name {
+			t.Errorf("content of %s = %q, want %q", name, got, name)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "c.log")); !os.IsNotExist(err) {
+		t.Errorf("expected c.log not to be copied, got err: %v", err)
+	}
+}
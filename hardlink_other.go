@@ -0,0 +1,26 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// fileKey identifies a file by device and inode number.
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// hardLinkKey reports that hard links can't be detected on this platform.
+func hardLinkKey(_ fs.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
+
+// fileID reports that file identities aren't available on this platform.
+func fileID(_ fs.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
+
+// fileDevice reports that device IDs aren't available on this platform.
+func fileDevice(_ fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
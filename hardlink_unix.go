@@ -0,0 +1,48 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileKey identifies a file by device and inode number.
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// hardLinkKey returns the identity of a file with more than one hard link.
+func hardLinkKey(info fs.FileInfo) (fileKey, bool) {
+	var none fileKey
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink < 2 {
+		return none, false
+	}
+
+	return fileKey{dev: uint64(stat.Dev), ino: stat.Ino}, true //nolint:unconvert
+}
+
+// fileID returns the identity of a file.
+func fileID(info fs.FileInfo) (fileKey, bool) {
+	var none fileKey
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return none, false
+	}
+
+	return fileKey{dev: uint64(stat.Dev), ino: stat.Ino}, true //nolint:unconvert
+}
+
+// fileDevice returns the ID of the device holding a file.
+func fileDevice(info fs.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	return uint64(stat.Dev), true //nolint:unconvert
+}
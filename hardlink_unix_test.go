@@ -0,0 +1,124 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// checkHardLinked checks that first and second name the same file.
+func checkHardLinked(t *testing.T, first, second string) {
+	t.Helper()
+
+	firstInfo, err := os.Stat(first)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", first, err)
+	}
+
+	secondInfo, err := os.Stat(second)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", second, err)
+	}
+
+	if !os.SameFile(firstInfo, secondInfo) {
+		t.Errorf("expected %s and %s to be hard links to the same inode", first, second)
+	}
+}
+
+// TestCopyTree_PreserveLinks tests that hard-linked sources stay linked.
+func TestCopyTree_PreserveLinks(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create two hard links to the same file
+	if err := os.MkdirAll(filepath.Join(sourceDir, "sub"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	first := filepath.Join(sourceDir, "first.txt")
+	if err := os.WriteFile(first, []byte("shared"), 0o600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	if err := os.Link(first, filepath.Join(sourceDir, "sub", "second.txt")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	// Test: Copy with --preserve=links
+	opts, err := parseArgs([]string{"-r", "--preserve=links", sourceDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("copyTree() failed: %v", err)
+	}
+
+	// Verify: The copies share an inode
+	secondCopy := filepath.Join(destDir, "sub", "second.txt")
+	checkHardLinked(t, filepath.Join(destDir, "first.txt"), secondCopy)
+}
+
+// TestCopyFile_VerifyRetryHardLinked tests that --verify --retry overwrites
+// a hard-linked destination in place once a copy verifies, so its other
+// links see the copy, and that a corrupted attempt never reaches it.
+func TestCopyFile_VerifyRetryHardLinked(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		corrupt int
+		want    string
+		wantErr bool
+	}{
+		{name: "corrupted once", corrupt: 1, want: "precious", wantErr: false},
+		{name: "always corrupted", corrupt: 2, want: "old", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "source.txt")
+			destFile := filepath.Join(tmpDir, "dest.txt")
+			otherLink := filepath.Join(tmpDir, "other.txt")
+
+			// Setup: Create source file, a hard-linked destination and a corrupting reader
+			writeFiles(t, tmpDir, map[string]string{"source.txt": "precious", "dest.txt": "old"})
+
+			if err := os.Link(destFile, otherLink); err != nil {
+				t.Skipf("hard links not supported: %v", err)
+			}
+
+			args := []string{"--verify", "--retry=1", "--retry-delay=1ms", sourceFile, destFile}
+
+			opts, err := parseArgs(args)
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			opts.stderr = io.Discard
+			corruptAttempts(opts, tt.corrupt)
+
+			// Test: Copy over the hard-linked destination through the flaky reader
+			_, err = copyFile(t.Context(), opts, sourceFile, destFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("copyFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// Verify: Both links still name the same file, which holds the good copy or the old file
+			checkHardLinked(t, destFile, otherLink)
+
+			if got, _ := os.ReadFile(otherLink); string(got) != tt.want {
+				t.Errorf("other link content = %q, want %q", got, tt.want)
+			}
+
+			checkNoTempFiles(t, tmpDir, 3)
+		})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+// acquireLock is a no-op where flock isn't available, which includes
+// solaris and aix.
+func acquireLock(opts *options, _ string) (func(), error) {
+	opts.warnf("--lock is not supported on this platform")
+
+	return func() {}, nil
+}
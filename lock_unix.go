@@ -0,0 +1,83 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockFileSuffix is appended to the destination's name to name its --lock
+// file.
+const lockFileSuffix = ".cp.lock"
+
+// lockFileMode is the permission given to newly created --lock files.
+const lockFileMode = 0o644
+
+// lockPath returns the --lock file guarding copies to dest: a hidden file
+// named after dest in the directory holding it. It doesn't depend on whether
+// dest exists yet, so every copy to dest agrees on it.
+func lockPath(dest string) string {
+	if abs, err := filepath.Abs(dest); err == nil {
+		dest = abs
+	}
+
+	return filepath.Join(filepath.Dir(dest), "."+filepath.Base(dest)+lockFileSuffix)
+}
+
+// acquireLock implements --lock, blocking until it holds an exclusive
+// advisory flock on the lock file for dest, which is created if needed. The
+// returned function removes the lock file and releases the lock. A copy that
+// locked a file removed meanwhile by the holder before it starts over, so
+// that copies never hold locks on different files.
+func acquireLock(_ *options, dest string) (func(), error) {
+	path := lockPath(dest)
+
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, lockFileMode)
+		if err != nil {
+			return nil, fmt.Errorf("opening lock file: %w", err)
+		}
+
+		for {
+			err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+			if !errors.Is(err, syscall.EINTR) {
+				break
+			}
+		}
+
+		if err != nil {
+			file.Close()
+
+			return nil, fmt.Errorf("locking destination: %w", err)
+		}
+
+		if !lockedCurrent(file, path) {
+			file.Close()
+
+			continue
+		}
+
+		return func() {
+			_ = os.Remove(path)
+			_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+			file.Close()
+		}, nil
+	}
+}
+
+// lockedCurrent reports whether the locked file is still the one at path,
+// rather than one its previous holder removed.
+func lockedCurrent(file *os.File, path string) bool {
+	locked, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	current, err := os.Stat(path)
+
+	return err == nil && os.SameFile(locked, current)
+}
@@ -0,0 +1,122 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAcquireLock_Serializes tests that a second --lock on the same
+// destination directory waits until the first is released.
+func TestAcquireLock_Serializes(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dest := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Take the lock
+	unlock, err := acquireLock(new(options), dest)
+	if err != nil {
+		t.Fatalf("acquireLock() failed: %v", err)
+	}
+
+	// Test: Take it again from another copy
+	acquired := make(chan struct{})
+
+	go func() {
+		unlockSecond, err := acquireLock(new(options), dest)
+		if err != nil {
+			t.Errorf("second acquireLock() failed: %v", err)
+		} else {
+			unlockSecond()
+		}
+
+		close(acquired)
+	}()
+
+	// Verify: The second copy waits for the first to finish
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while the first was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lock not acquired after the first was released")
+	}
+}
+
+// TestRun_LockConcurrentCopies tests that two copies to one destination
+// under --lock leave it holding exactly one of the sources.
+func TestRun_LockConcurrentCopies(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	dest := filepath.Join(tmpDir, "dest.bin")
+	contents := [][]byte{bytes.Repeat([]byte("a"), 8<<20), bytes.Repeat([]byte("b"), 4<<20)}
+
+	// Setup: Create two sources of different content and size
+	sources := make([]string, len(contents))
+
+	for idx, content := range contents {
+		sources[idx] = filepath.Join(tmpDir, string(rune('a'+idx))+".bin")
+		if err := os.WriteFile(sources[idx], content, 0o600); err != nil {
+			t.Fatalf("failed to create source file: %v", err)
+		}
+	}
+
+	// Test: Copy both sources to the destination at once
+	var wg sync.WaitGroup
+
+	for _, source := range sources {
+		wg.Go(func() {
+			if err := run([]string{"cp", "-q", "--lock", source, dest}); err != nil {
+				t.Errorf("run() failed: %v", err)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	// Verify: The destination is one whole source, not a mix of both
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if !bytes.Equal(got, contents[0]) && !bytes.Equal(got, contents[1]) {
+		t.Errorf("destination of %d bytes matches neither source", len(got))
+	}
+
+	if _, err := os.Stat(lockPath(dest)); !os.IsNotExist(err) {
+		t.Errorf("expected the lock file to be removed, got: %v", err)
+	}
+}
+
+// TestLockPath_MissingDirectory tests that copies to a directory agree on
+// the lock file whether or not the directory exists yet.
+func TestLockPath_MissingDirectory(t *testing.T) {
+	t.Parallel()
+	dest := filepath.Join(t.TempDir(), "out")
+
+	before := lockPath(dest)
+
+	if err := os.Mkdir(dest, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	if after := lockPath(dest); after != before {
+		t.Errorf("lock path changed from %s to %s once the directory existed", before, after)
+	}
+
+	if want := filepath.Join(filepath.Dir(dest), ".out.cp.lock"); before != want {
+		t.Errorf("lock path = %s, want %s", before, want)
+	}
+}
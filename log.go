@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+const (
+	// logFormatText is the default --log-format, which logs nothing extra.
+	logFormatText = "text"
+	// logFormatJSON selects one JSON object per line for every event.
+	logFormatJSON = "json"
+)
+
+// copyEvent is logged under --log-format=json for every copied file.
+type copyEvent struct {
+	Event    string `json:"event"`
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+	Bytes    int64  `json:"bytes"`
+	Method   string `json:"method"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// errorEvent is logged under --log-format=json for every failed entry.
+type errorEvent struct {
+	Event string `json:"event"`
+	Src   string `json:"src"`
+	Error string `json:"error"`
+}
+
+// logCopy records that source was copied to dest with the given result.
+func (opts *options) logCopy(source, dest string, result copyResult) {
+	opts.stats.files++
+	opts.stats.bytes += result.bytes
+
+	opts.logEvent(copyEvent{
+		Event:    "copy",
+		Src:      source,
+		Dst:      dest,
+		Bytes:    result.bytes,
+		Method:   string(result.method),
+		Checksum: result.checksum,
+	})
+}
+
+// logError records that copying source failed.
+func (opts *options) logError(source string, err error) {
+	opts.stats.errors++
+
+	opts.logEvent(errorEvent{Event: "error", Src: source, Error: err.Error()})
+}
+
+// logEvent writes event as a single JSON line when --log-format=json is set.
+func (opts *options) logEvent(event any) {
+	if opts.logFormat != logFormatJSON {
+		return
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	_, _ = opts.errorOutput().Write(append(line, '\n'))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyTree_LogFormatJSON tests that a recursive copy with
+// --log-format=json logs one valid JSON line per copied file.
+func TestCopyTree_LogFormatJSON(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	files := map[string]string{
+		"a.txt":        "alpha",
+		"sub/b.txt":    "bravo!",
+		"sub/c/c.txt":  "",
+		"sub/c/d.data": "delta echo",
+	}
+
+	// Setup: Create a source tree
+	writeFiles(t, sourceDir, files)
+
+	// Test: Copy the tree with JSON logging
+	var out bytes.Buffer
+
+	opts := new(options)
+	opts.recursive = true
+	opts.logFormat = logFormatJSON
+	opts.stderr = &out
+
+	if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("copyTree() failed: %v", err)
+	}
+
+	// Verify: Each file yields exactly one well-formed copy event
+	seen := make(map[string]bool)
+
+	for _, event := range copyEvents(t, &out) {
+		rel, err := filepath.Rel(sourceDir, event.Src)
+		if err != nil {
+			t.Fatalf("unexpected source path %q: %v", event.Src, err)
+		}
+
+		content, ok := files[filepath.ToSlash(rel)]
+		if event.Event != "copy" || !ok || seen[rel] {
+			t.Errorf("unexpected event: %+v", event)
+
+			continue
+		}
+
+		seen[rel] = true
+
+		if event.Dst != filepath.Join(destDir, rel) {
+			t.Errorf("dst = %q, want %q", event.Dst, filepath.Join(destDir, rel))
+		}
+
+		if event.Bytes != int64(len(content)) {
+			t.Errorf("bytes for %s = %d, want %d", rel, event.Bytes, len(content))
+		}
+	}
+
+	if len(seen) != len(files) {
+		t.Errorf("got events for %d files, want %d", len(seen), len(files))
+	}
+}
+
+// writeFiles creates the files under dir with the given contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+}
+
+// copyEvents returns every event of the JSON log in out, decoded as a copy
+// event.
+func copyEvents(t *testing.T, out *bytes.Buffer) []copyEvent {
+	t.Helper()
+
+	var events []copyEvent
+
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var event copyEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// TestCopyTree_LogFormatJSONFollowedFailure tests that a failure met under a
+// symlink followed by -L is logged once, at the link, and counted once.
+func TestCopyTree_LogFormatJSONFollowedFailure(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+	link := filepath.Join(sourceDir, "a", "to-b")
+
+	// Setup: Create directories a and b, each with a symlink to the other
+	for _, dir := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(sourceDir, dir), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+	}
+
+	if err := os.Symlink(filepath.Join("..", "b"), link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	loop := filepath.Join(sourceDir, "b", "to-a")
+	if err := os.Symlink(filepath.Join("..", "a"), loop); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	// Test: Copy the tree with -L and JSON logging
+	opts, err := parseArgs([]string{"-r", "-L", "-q", "--log-format=json", sourceDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	var out bytes.Buffer
+
+	opts.stderr = &out
+
+	if err := copyTree(t.Context(), opts, sourceDir, destDir); err == nil {
+		t.Fatal("expected symlink cycle error")
+	}
+
+	// Verify: The cycle yields a single error event, at the followed link
+	events := errorEvents(t, &out)
+	if len(events) != 1 || events[0].Src != link {
+		t.Errorf("got error events %+v, want one for %s", events, link)
+	}
+
+	if opts.stats.errors != 1 {
+		t.Errorf("stats.errors = %d, want 1", opts.stats.errors)
+	}
+}
+
+// errorEvents returns the error events of the JSON log in out.
+func errorEvents(t *testing.T, out *bytes.Buffer) []errorEvent {
+	t.Helper()
+
+	var events []errorEvent
+
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var event errorEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+
+		if event.Event == "error" {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Formats of --manifest entries (--checksum-output-format).
+const (
+	// manifestSHA256Sum writes "HASH  PATH" lines, as sha256sum does.
+	manifestSHA256Sum = "sha256sum"
+	// manifestBSD writes "SHA256 (PATH) = HASH" lines, as BSD sha256 does.
+	manifestBSD = "bsd"
+	// manifestJSON keeps the manifest a JSON array of manifestRecord.
+	manifestJSON = "json"
+)
+
+// jsonManifestEnd is how a JSON manifest ends, so records can be added by
+// rewriting it instead of the whole file.
+const jsonManifestEnd = "\n]\n"
+
+// manifestFileMode is the mode of a manifest file --manifest creates.
+const manifestFileMode = 0o644
+
+// manifestRecord is a --manifest entry under --checksum-output-format=json.
+type manifestRecord struct {
+	Path      string `json:"path"`
+	Algorithm string `json:"algorithm"`
+	Hash      string `json:"hash"`
+}
+
+// appendManifest adds the sha256 sum of dest to the manifest file, in the
+// given --checksum-output-format.
+func appendManifest(manifest, format, sum, dest string) error {
+	file, err := os.OpenFile(manifest, os.O_CREATE|os.O_RDWR, manifestFileMode)
+	if err != nil {
+		return fmt.Errorf("opening manifest: %w", err)
+	}
+
+	defer file.Close()
+
+	var entry string
+
+	switch format {
+	case manifestBSD:
+		entry = fmt.Sprintf("SHA256 (%s) = %s\n", dest, sum)
+	case manifestJSON:
+		entry, err = jsonManifestEntry(
+			file, manifestRecord{Path: dest, Algorithm: "sha256", Hash: sum},
+		)
+		if err != nil {
+			return err
+		}
+	default:
+		entry = fmt.Sprintf("%s  %s\n", sum, dest)
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+
+	if _, err := file.WriteString(entry); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("closing manifest: %w", err)
+	}
+
+	return nil
+}
+
+// jsonManifestEntry returns the text adding record to the JSON manifest
+// file: a new array if the file is empty, or else the record after the
+// existing ones, which the end of the array is truncated away for.
+func jsonManifestEntry(file *os.File, record manifestRecord) (string, error) {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("encoding manifest record: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("getting manifest info: %w", err)
+	}
+
+	if info.Size() == 0 {
+		return "[\n  " + string(encoded) + jsonManifestEnd, nil
+	}
+
+	end := make([]byte, len(jsonManifestEnd))
+	if _, err := file.ReadAt(end, info.Size()-int64(len(end))); err != nil ||
+		!bytes.Equal(end, []byte(jsonManifestEnd)) {
+		return "", fmt.Errorf("manifest '%s' isn't a JSON manifest", file.Name()) //nolint:err113
+	}
+
+	if err := file.Truncate(info.Size() - int64(len(end))); err != nil {
+		return "", fmt.Errorf("writing manifest: %w", err)
+	}
+
+	return ",\n  " + string(encoded) + jsonManifestEnd, nil
+}
+
+// parseManifest returns the records of a manifest in any
+// --checksum-output-format: a JSON array, or lines of sha256sum or BSD
+// entries.
+func parseManifest(manifest string, data []byte) ([]manifestRecord, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var records []manifestRecord
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("%s: malformed JSON manifest: %w", manifest, err)
+		}
+
+		return records, nil
+	}
+
+	var records []manifestRecord
+
+	for number, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+
+		record, ok := parseManifestLine(line)
+		if !ok {
+			return nil, fmt.Errorf( //nolint:err113
+				"%s:%d: malformed manifest line", manifest, number+1,
+			)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// parseManifestLine parses a "SHA256 (PATH) = HASH" or "HASH  PATH" line.
+func parseManifestLine(line string) (manifestRecord, bool) {
+	if algorithm, rest, ok := strings.Cut(line, " ("); ok && !strings.Contains(algorithm, " ") {
+		if end := strings.LastIndex(rest, ") = "); end >= 0 {
+			return manifestRecord{
+				Path:      rest[:end],
+				Algorithm: strings.ToLower(algorithm),
+				Hash:      rest[end+len(") = "):],
+			}, true
+		}
+	}
+
+	hash, path, ok := strings.Cut(line, "  ")
+
+	return manifestRecord{Path: path, Algorithm: "sha256", Hash: hash}, ok
+}
+
+// runVerifyManifest implements --verify-manifest, checking every file listed
+// in a manifest, in any --checksum-output-format, against its recorded hash.
+// Files that differ or are gone are reported, and make the run fail.
+func runVerifyManifest(opts *options, manifest string) error {
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		return fmt.Errorf("reading manifest: %w", err)
+	}
+
+	records, err := parseManifest(manifest, data)
+	if err != nil {
+		return err
+	}
+
+	var checked, failed int
+
+	for _, record := range records {
+		path, want := record.Path, record.Hash
+
+		checked++
+
+		got, err := hashFile(record.Algorithm, 0, path)
+
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			fmt.Fprintf(opts.output(), "%s: MISSING\n", path)
+		case err != nil:
+			fmt.Fprintf(opts.output(), "%s: FAILED (%v)\n", path, err)
+		case got != want:
+			fmt.Fprintf(opts.output(), "%s: FAILED\n", path)
+		default:
+			if opts.verbose {
+				fmt.Fprintf(opts.output(), "%s: OK\n", path)
+			}
+
+			continue
+		}
+
+		failed++
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed verification", failed, checked) //nolint:err113
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCopyFile_Manifest tests that --manifest writes sha256sum-compatible lines.
+func TestCopyFile_Manifest(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	manifest := filepath.Join(tmpDir, "SHA256SUMS")
+	opts := new(options)
+	opts.manifest = manifest
+
+	// Setup & Test: Copy two files with a manifest
+	for _, name := range []string{"a.txt", "b.txt"} {
+		sourceFile := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(sourceFile, []byte("content of "+name), 0o600); err != nil {
+			t.Fatalf("failed to create source file: %v", err)
+		}
+
+		if _, err := copyFile(t.Context(), opts, sourceFile, sourceFile+".copy"); err != nil {
+			t.Fatalf("copyFile() failed: %v", err)
+		}
+	}
+
+	// Verify: Each line matches the destination checksum
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 manifest lines, got %d: %q", len(lines), data)
+	}
+
+	for _, line := range lines {
+		checkManifestLine(t, line)
+	}
+
+	// Verify: sha256sum accepts the manifest when available
+	if _, err := exec.LookPath("sha256sum"); err != nil {
+		t.Skip("sha256sum not available")
+	}
+
+	cmd := exec.CommandContext(context.Background(), "sha256sum", "-c", manifest)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("sha256sum -c failed: %v\n%s", err, out)
+	}
+}
+
+// checkManifestLine checks that a sha256sum-style manifest line holds the
+// checksum of the file it names.
+func checkManifestLine(t *testing.T, line string) {
+	t.Helper()
+
+	sum, path, ok := strings.Cut(line, "  ")
+	if !ok {
+		t.Fatalf("malformed manifest line: %q", line)
+	}
+
+	want, err := hashFile("sha256", 0, path)
+	if err != nil {
+		t.Fatalf("failed to hash %s: %v", path, err)
+	}
+
+	if sum != want {
+		t.Errorf("checksum mismatch for %s: got %s, want %s", path, sum, want)
+	}
+}
+
+// manifestSums reads the records of a manifest in format back into a map
+// of checksums by path.
+func manifestSums(t *testing.T, format string, data []byte) map[string]string {
+	t.Helper()
+
+	got := make(map[string]string)
+
+	if format == manifestJSON {
+		var records []manifestRecord
+		if err := json.Unmarshal(data, &records); err != nil {
+			t.Fatalf("manifest isn't valid JSON: %v\n%s", err, data)
+		}
+
+		for _, record := range records {
+			if record.Algorithm != "sha256" {
+				t.Errorf("%s: algorithm = %q, want sha256", record.Path, record.Algorithm)
+			}
+
+			got[record.Path] = record.Hash
+		}
+
+		return got
+	}
+
+	lineFormats := map[string]*regexp.Regexp{
+		manifestSHA256Sum: regexp.MustCompile(`^([0-9a-f]{64})  (.+)$`),
+		manifestBSD:       regexp.MustCompile(`^SHA256 \((.+)\) = ([0-9a-f]{64})$`),
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		match := lineFormats[format].FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("malformed manifest line: %q", line)
+		}
+
+		if format == manifestBSD {
+			got[match[1]] = match[2]
+		} else {
+			got[match[2]] = match[1]
+		}
+	}
+
+	return got
+}
+
+// TestCopyFile_ManifestFormats tests that every --checksum-output-format
+// writes well-formed entries recording each copy's checksum.
+func TestCopyFile_ManifestFormats(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{manifestSHA256Sum, manifestBSD, manifestJSON} {
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			manifest := filepath.Join(tmpDir, "manifest")
+
+			opts := new(options)
+			opts.manifest = manifest
+			opts.manifestFormat = format
+
+			// Setup & Test: Copy two files with a manifest
+			dests := copyWithManifest(t, opts, tmpDir, "a.txt", "b.txt")
+
+			want := make(map[string]string)
+
+			for _, dest := range dests {
+				sum, err := hashFile("sha256", 0, dest)
+				if err != nil {
+					t.Fatalf("failed to hash %s: %v", dest, err)
+				}
+
+				want[dest] = sum
+			}
+
+			// Verify: The manifest parses back to one record per copy
+			data, err := os.ReadFile(manifest)
+			if err != nil {
+				t.Fatalf("failed to read manifest: %v", err)
+			}
+
+			got := manifestSums(t, format, data)
+			if len(got) != len(want) {
+				t.Errorf("expected %d records, got %d: %q", len(want), len(got), data)
+			}
+
+			for path, sum := range want {
+				if got[path] != sum {
+					t.Errorf("checksum of %s = %q, want %s", path, got[path], sum)
+				}
+			}
+		})
+	}
+}
+
+// copyWithManifest creates the named files under dir and copies each to a
+// ".copy" sibling with opts, returning the copies.
+func copyWithManifest(t *testing.T, opts *options, dir string, names ...string) []string {
+	t.Helper()
+
+	dests := make([]string, 0, len(names))
+
+	for _, name := range names {
+		sourceFile := filepath.Join(dir, name)
+		if err := os.WriteFile(sourceFile, []byte("content of "+name), 0o600); err != nil {
+			t.Fatalf("failed to create source file: %v", err)
+		}
+
+		dest := sourceFile + ".copy"
+		if _, err := copyFile(t.Context(), opts, sourceFile, dest); err != nil {
+			t.Fatalf("copyFile() failed: %v", err)
+		}
+
+		dests = append(dests, dest)
+	}
+
+	return dests
+}
+
+// TestRunVerifyManifest tests that --verify-manifest accepts the files a
+// manifest in each --checksum-output-format was written for, and reports a
+// tampered and a missing file.
+func TestRunVerifyManifest(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{manifestSHA256Sum, manifestBSD, manifestJSON} {
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			manifest := filepath.Join(tmpDir, "SHA256SUMS")
+			opts := new(options)
+			opts.manifest = manifest
+			opts.manifestFormat = format
+
+			// Setup: Copy three files with a manifest
+			dests := copyWithManifest(t, opts, tmpDir, "a.txt", "b.txt", "c.txt")
+
+			// Test & Verify: The untouched copies verify
+			var out bytes.Buffer
+
+			verifyOpts := new(options)
+			verifyOpts.stdout = &out
+			verifyOpts.verbose = true
+
+			if err := runVerifyManifest(verifyOpts, manifest); err != nil {
+				t.Fatalf("runVerifyManifest() failed: %v\n%s", err, out.String())
+			}
+
+			if want := dests[0] + ": OK\n" + dests[1] + ": OK\n" + dests[2] + ": OK\n"; out.String() != want {
+				t.Errorf("output = %q, want %q", out.String(), want)
+			}
+
+			// Test: Tamper with one copy and remove another
+			if err := os.WriteFile(dests[0], []byte("tampered"), 0o600); err != nil {
+				t.Fatalf("failed to tamper with %s: %v", dests[0], err)
+			}
+
+			if err := os.Remove(dests[2]); err != nil {
+				t.Fatalf("failed to remove %s: %v", dests[2], err)
+			}
+
+			out.Reset()
+
+			verifyOpts.verbose = false
+			err := runVerifyManifest(verifyOpts, manifest)
+
+			// Verify: Both problems are reported and the run fails
+			if err == nil || err.Error() != "2 of 3 files failed verification" {
+				t.Errorf("expected 2 of 3 failures, got: %v", err)
+			}
+
+			want := dests[0] + ": FAILED\n" + dests[2] + ": MISSING\n"
+			if out.String() != want {
+				t.Errorf("output = %q, want %q", out.String(), want)
+			}
+		})
+	}
+}
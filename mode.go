@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Permissions given to copies by --normalize-permissions: executables
+// (files with any execute bit set) and directories get normalizedExecMode,
+// other files normalizedFileMode.
+const (
+	normalizedFileMode fs.FileMode = 0o644
+	normalizedExecMode fs.FileMode = 0o755
+)
+
+// modeSpec is a parsed --mode value: either an absolute octal mode or a list
+// of symbolic clauses applied to the current mode.
+type modeSpec struct {
+	absolute bool
+	mode     fs.FileMode
+	clauses  []modeClause
+}
+
+// modeClause is a single symbolic change such as "u+x" or "go-w".
+type modeClause struct {
+	who  fs.FileMode
+	op   byte
+	perm fs.FileMode
+}
+
+// parseModeSpec parses an octal mode such as "0644" or a comma-separated list
+// of symbolic clauses such as "u+x,go-w" or "a=r".
+func parseModeSpec(value string) (*modeSpec, error) {
+	invalid := fmt.Errorf("invalid mode '%s'", value) //nolint:err113
+
+	if mode, err := strconv.ParseUint(value, 8, 32); err == nil {
+		if mode > uint64(fs.ModePerm) {
+			return nil, invalid
+		}
+
+		return &modeSpec{absolute: true, mode: fs.FileMode(mode), clauses: nil}, nil
+	}
+
+	spec := &modeSpec{absolute: false, mode: 0, clauses: nil}
+
+	for text := range strings.SplitSeq(value, ",") {
+		clause, ok := parseModeClause(text)
+		if !ok {
+			return nil, invalid
+		}
+
+		spec.clauses = append(spec.clauses, clause)
+	}
+
+	return spec, nil
+}
+
+// parseModeClause parses one [ugoa]*[+-=][rwx]* clause.
+func parseModeClause(text string) (modeClause, bool) {
+	clause := modeClause{who: 0, op: 0, perm: 0}
+
+	who, idx := parseModeWho(text)
+	if idx == len(text) || !strings.ContainsRune("+-=", rune(text[idx])) {
+		return clause, false
+	}
+
+	clause.who = who
+	if clause.who == 0 {
+		clause.who = fs.ModePerm
+	}
+
+	clause.op = text[idx]
+
+	perm, ok := parseModePerm(text[idx+1:])
+	if !ok {
+		return clause, false
+	}
+
+	clause.perm = perm & clause.who
+
+	return clause, true
+}
+
+// parseModeWho parses the leading [ugoa]* of a clause, returning the
+// permission bits it selects and the length of the prefix.
+func parseModeWho(text string) (fs.FileMode, int) {
+	var who fs.FileMode
+
+	idx := 0
+	for ; idx < len(text) && strings.IndexByte("ugoa", text[idx]) >= 0; idx++ {
+		switch text[idx] {
+		case 'u':
+			who |= 0o700
+		case 'g':
+			who |= 0o070
+		case 'o':
+			who |= 0o007
+		default:
+			who |= 0o777
+		}
+	}
+
+	return who, idx
+}
+
+// parseModePerm parses the trailing [rwx]* of a clause into permission bits
+// for everyone.
+func parseModePerm(text string) (fs.FileMode, bool) {
+	var perm fs.FileMode
+
+	for _, char := range text {
+		switch char {
+		case 'r':
+			perm |= 0o444
+		case 'w':
+			perm |= 0o222
+		case 'x':
+			perm |= 0o111
+		default:
+			return 0, false
+		}
+	}
+
+	return perm, true
+}
+
+// apply returns the permission bits resulting from applying spec to current.
+func (spec *modeSpec) apply(current fs.FileMode) fs.FileMode {
+	if spec.absolute {
+		return spec.mode
+	}
+
+	mode := current.Perm()
+
+	for _, clause := range spec.clauses {
+		switch clause.op {
+		case '+':
+			mode |= clause.perm
+		case '-':
+			mode &^= clause.perm
+		default:
+			mode = mode&^clause.who | clause.perm
+		}
+	}
+
+	return mode
+}
+
+// normalizedMode returns the --normalize-permissions mode of a copy of the
+// file described by info.
+func normalizedMode(info fs.FileInfo) fs.FileMode {
+	if info.IsDir() || info.Mode()&0o111 != 0 {
+		return normalizedExecMode
+	}
+
+	return normalizedFileMode
+}
+
+// normalizePermissions implements --normalize-permissions, replacing the mode
+// of dest, a copy of the file described by info, with its canonical one.
+func normalizePermissions(opts *options, dest string, info fs.FileInfo) error {
+	if !opts.normalizePerms {
+		return nil
+	}
+
+	if err := os.Chmod(dest, normalizedMode(info)); err != nil {
+		return fmt.Errorf("setting mode of '%s': %w", dest, err)
+	}
+
+	return nil
+}
+
+// overrideMode applies --mode to dest.
+func overrideMode(opts *options, dest string) error {
+	if opts.mode == nil {
+		return nil
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		return fmt.Errorf("getting destination file info: %w", err)
+	}
+
+	if err := os.Chmod(dest, opts.mode.apply(info.Mode())); err != nil {
+		return fmt.Errorf("setting mode of '%s': %w", dest, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestParseModeSpec tests octal and symbolic --mode values against a
+// starting mode of 0640.
+func TestParseModeSpec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  fs.FileMode
+	}{
+		{input: "0644", want: 0o644},
+		{input: "755", want: 0o755},
+		{input: "u+x", want: 0o740},
+		{input: "g-r", want: 0o600},
+		{input: "a=r", want: 0o444},
+		{input: "+x", want: 0o751},
+		{input: "go+w,u-w", want: 0o462},
+		{input: "o=rwx", want: 0o647},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			spec, err := parseModeSpec(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := spec.apply(0o640); got != tt.want {
+				t.Errorf("mode = %o, want %o", got, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"0888", "10000", "u+z", "ux", ""} {
+		t.Run("invalid "+input, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := parseModeSpec(input); err == nil {
+				t.Errorf("parseModeSpec(%q) succeeded, want error", input)
+			}
+		})
+	}
+}
+
+// TestCopyFile_ModeOverride tests that --mode sets the destination
+// permissions regardless of the source.
+func TestCopyFile_ModeOverride(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("permission bits are not supported on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.sh")
+	destFile := filepath.Join(tmpDir, "dest.sh")
+
+	// Setup: Create a private source
+	if err := os.WriteFile(sourceFile, []byte("#!/bin/sh"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy making it executable and readable by everyone
+	opts, err := parseArgs([]string{"--mode=a+rx", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The mode was changed
+	info, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatalf("failed to stat destination file: %v", err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o755 {
+		t.Errorf("mode = %o, want 755", perm)
+	}
+}
+
+// TestParseArgs_InvalidMode tests the error for an invalid --mode.
+func TestParseArgs_InvalidMode(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseArgs([]string{"--mode=rwx", "a", "b"})
+	if err == nil || err.Error() != "invalid value for '--mode': invalid mode 'rwx'" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestRunRecursive_NormalizePermissions tests that --normalize-permissions
+// gives a copied tree canonical modes whatever the source's were.
+func TestRunRecursive_NormalizePermissions(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("permission bits are not supported on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+
+	// Setup: A tree with private, group-writable and executable entries
+	sourceModes := map[string]fs.FileMode{
+		".":             0o700,
+		"private.txt":   0o600,
+		"shared.txt":    0o664,
+		"run.sh":        0o700,
+		"sub/tool":      0o750,
+		"sub/notes.txt": 0o640,
+		"sub":           0o750,
+	}
+
+	writeSizedFiles(t, sourceDir, map[string]int{
+		"private.txt":   1,
+		"shared.txt":    1,
+		"run.sh":        1,
+		"sub/tool":      1,
+		"sub/notes.txt": 1,
+	})
+
+	for name, mode := range sourceModes {
+		if err := os.Chmod(filepath.Join(sourceDir, name), mode); err != nil {
+			t.Fatalf("failed to chmod %s: %v", name, err)
+		}
+	}
+
+	// Test: Copy the tree normalizing permissions
+	args := []string{"cp", "-r", "-q", "--normalize-permissions", sourceDir, destDir}
+	if err := run(args); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+
+	// Verify: Files are 0644, executables and directories 0755
+	want := map[string]fs.FileMode{
+		".":             0o755,
+		"sub":           0o755,
+		"private.txt":   0o644,
+		"shared.txt":    0o644,
+		"run.sh":        0o755,
+		"sub/tool":      0o755,
+		"sub/notes.txt": 0o644,
+	}
+
+	for name, mode := range want {
+		checkPerm(t, filepath.Join(destDir, name), mode)
+	}
+}
@@ -0,0 +1,2014 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// options holds the settings parsed from the command line.
+type options struct {
+	paths  []string
+	resume bool
+	verify bool
+	// verifyStrict makes --verify read dest back instead of hashing the
+	// bytes as they're written (--verify=strict).
+	verifyStrict bool
+	checksum     string
+	// checksumSeed seeds --checksum=xxhash (--checksum-seed).
+	checksumSeed uint64
+	manifest     string
+	// manifestFormat is the --manifest line format
+	// (--checksum-output-format).
+	manifestFormat string
+	compare        bool
+	// dryRun previews copies instead of making them (--dry-run).
+	dryRun bool
+	// countOnly totals the files and bytes under the source instead of
+	// copying (--count-only).
+	countOnly     bool
+	exchange      bool
+	checksumOnly  bool
+	listChecksums bool
+	// verifyManifest names a --manifest file whose entries are checked
+	// instead of copying.
+	verifyManifest string
+	// expectDestSum is the checksum an existing destination must have to be
+	// overwritten (--verify-before-overwrite).
+	expectDestSum string
+	// checksumCacheFile names the --checksum-cache file, loaded into
+	// checksumCache when the copy starts.
+	checksumCacheFile string
+	checksumCache     *checksumCache
+	// openFile opens files for hashing; nil means os.Open.
+	openFile          func(name string) (io.ReadCloser, error)
+	verbose           bool
+	force             bool
+	removeDestination bool
+	appendDest        bool
+	// text converts line endings to eol while copying.
+	text bool
+	eol  string
+	// destExists is the --dest-exists-policy; empty means policyOverwrite.
+	destExists string
+	quiet      bool
+	recursive  bool
+	archive    bool
+	preserve   preserveAttrs
+	// preserveExplicit holds the attributes named via --preserve, whose
+	// failures are errors rather than warnings.
+	preserveExplicit preserveAttrs
+	noPreserve       preserveAttrs
+	dereference      derefMode
+	// chown holds the --owner and --group overrides, if any.
+	chown *ownership
+	// mode is the --mode override, if any.
+	mode         *modeSpec
+	ignoreErrors bool
+	// verboseErrors prints the whole chain of a failure's causes
+	// (--verbose-errors).
+	verboseErrors bool
+	// lock serializes copies to the same destination with an
+	// advisory lock (--lock).
+	lock bool
+	// strict turns a source that changed size during the copy into an
+	// error instead of a warning.
+	strict         bool
+	exclude        []string
+	pruneEmptyDirs bool
+	// noPreserveRoot allows recursive copies from or to the filesystem root.
+	noPreserveRoot bool
+	// isRoot reports whether a path is the filesystem root; nil means
+	// isFilesystemRoot.
+	isRoot func(path string) bool
+	// noFollowMounts makes a recursive copy fail at a mount point
+	// (--follow-mounts=false).
+	noFollowMounts bool
+	// device returns the device ID of a file; nil means fileDevice.
+	device func(info fs.FileInfo) (uint64, bool)
+	// minSize and maxSize skip regular files outside this size window; a
+	// zero maxSize means no upper limit.
+	minSize int64
+	maxSize int64
+	// newerThan skips regular files not modified after this time.
+	newerThan time.Time
+	// skipBytes and countBytes select the byte range of the source to copy;
+	// a zero countBytes means up to the end.
+	skipBytes   int64
+	countBytes  int64
+	checkSpace  bool
+	preallocate bool
+	// flushInterval syncs the destination every this many bytes; zero means
+	// only the operating system decides when to write back.
+	flushInterval int64
+	// syncFile syncs a destination file; nil means (*os.File).Sync.
+	syncFile func(file *os.File) error
+	// reflink is the --reflink mode; empty means reflinkAuto.
+	reflink string
+	// freeSpace returns the bytes available in a directory; nil means the
+	// filesystem is queried.
+	freeSpace            func(dir string) (uint64, error)
+	stripTrailingSlashes bool
+	// makeDirs creates missing parent directories of the destination (-D),
+	// using dirMode, or the default directory mode when it is zero.
+	makeDirs bool
+	dirMode  fs.FileMode
+	// into is the directory to copy the single source into, under the name
+	// as if given (--into, --as).
+	into string
+	as   string
+	// filesFrom names a list of sources to copy into the destination
+	// directory; from0 makes its entries NUL-terminated (-0, --from0).
+	filesFrom string
+	from0     bool
+	// sourceRoot is the directory --files-from entries are relative to
+	// (--source-root).
+	sourceRoot string
+	// inheritDirMode gives directories created by a recursive copy the mode
+	// of their source, while destDirMode gives them all the same mode
+	// (--destination-mode).
+	inheritDirMode bool
+	destDirMode    *fs.FileMode
+	// syncDirModes gives directories that already exist in the destination
+	// the mode of their source (--sync-dir-modes).
+	syncDirModes bool
+	// normalizePerms gives copies canonical permissions derived from their
+	// type (--normalize-permissions).
+	normalizePerms bool
+	// umask replaces the process umask for the copy when set.
+	umask *fs.FileMode
+	// timestampResolution is the precision modification times are compared
+	// at (--timestamp-resolution); zero compares them exactly.
+	timestampResolution time.Duration
+	// replaceNewerOnly fails instead of replacing a destination newer than
+	// its source (--replace-newer-only).
+	replaceNewerOnly bool
+	// tempDir is where atomic replacements stage the new file instead of
+	// next to the destination (--temp-dir).
+	tempDir string
+	// trash names the directory existing destinations are moved to before
+	// being overwritten (--trash).
+	trash string
+	// maxDepth limits how deep a recursive copy descends, counting the
+	// source's direct children as depth 0 (--max-depth); nil means no limit.
+	maxDepth *int
+	// noDereferenceDest replaces a destination symlink instead of writing
+	// through it.
+	noDereferenceDest bool
+	timeout           time.Duration
+	// partialSuffix names the file a copy is written to before being renamed
+	// to the destination; it is kept if the copy fails.
+	partialSuffix string
+	// bufferSize is the size of copy buffers, which are shared across the
+	// files of a copy through buffers.
+	bufferSize int
+	buffers    *bufferPool
+	// retry is how many times a failed copy is attempted again, waiting
+	// retryDelay before the first retry and twice as long each time after.
+	retry      int
+	retryDelay time.Duration
+	// readSource wraps the reader of a copied file; nil leaves it as is.
+	readSource func(io.Reader) io.Reader
+	compress   string
+	decompress bool
+	auto       bool
+	// transforms lists the --transform stages applied to the copied bytes.
+	transforms []string
+	// rename names the files of a batch copy into a directory.
+	rename   *template.Template
+	toTar    string
+	fromTar  string
+	progress string
+	// progressTo names the file or file descriptor progress goes to
+	// (--progress-to); progressOut is its writer once opened.
+	progressTo  string
+	progressOut io.Writer
+	// overallProgress sizes a recursive copy before it starts so progress
+	// covers the whole tree (--overall-progress); overall tracks it.
+	overallProgress bool
+	overall         *overallProgress
+	logFormat       string
+	// onProgress is called with the bytes copied so far and the total as a
+	// file is copied, for programs embedding the copy; see progressReader
+	// for the cadence.
+	onProgress func(copied, total int64)
+	// chunkSize makes copies read and write in blocks of exactly this many
+	// bytes (--chunk-size); onChunk, set by an embedding program, sees each
+	// block before it is written and can stop the copy with an error.
+	chunkSize int
+	onChunk   func(chunk []byte) error
+	// stdin, stdout and stderr replace the standard streams; nil means the
+	// os ones.
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// summary prints the totals in stats once the copy is done.
+	summary bool
+	stats   copyStats
+}
+
+// derefMode controls which symlinks are followed.
+type derefMode int
+
+const (
+	// derefDefault follows command-line symlinks unless copying recursively.
+	derefDefault derefMode = iota
+	// derefNever copies symlinks as symlinks (-P).
+	derefNever
+	// derefCommandLine follows symlinks named on the command line but copies
+	// those found while walking a tree as symlinks (-H).
+	derefCommandLine
+	// derefAlways follows every symlink, copying what it points to (-L).
+	derefAlways
+)
+
+// flagSpec describes a single command-line flag and how it updates options.
+type flagSpec struct {
+	short    string
+	long     string
+	hasValue bool
+	// optionalValue makes the value of a hasValue flag optional; it can
+	// then only be given inline, as in --name=value.
+	optionalValue bool
+	apply         func(opts *options, value string) error
+}
+
+// applyDestExistsPolicy sets the --dest-exists-policy, also given as
+// --on-conflict.
+func applyDestExistsPolicy(opts *options, value string) error {
+	switch value {
+	case policyOverwrite, policySkip, policyPrompt, policyUpdate, policyError, policyRename:
+		opts.destExists = value
+	default:
+		return fmt.Errorf("unknown policy '%s'", value) //nolint:err113
+	}
+
+	return nil
+}
+
+// flagSpecs returns the table of supported flags.
+func flagSpecs() []flagSpec {
+	return slices.Concat(
+		messageFlags(),
+		progressFlags(),
+		conflictFlags(),
+		replaceFlags(),
+		contentFlags(),
+		transferFlags(),
+		tuningFlags(),
+		recursiveFlags(),
+		symlinkFlags(),
+		filterFlags(),
+		attributeFlags(),
+		permissionFlags(),
+		targetFlags(),
+		listFlags(),
+		operationFlags(),
+		checksumFlags(),
+		verifyFlags(),
+	)
+}
+
+// set returns the apply function of a flag that calls update, ignoring any
+// value.
+func set(update func(opts *options)) func(*options, string) error {
+	return func(opts *options, _ string) error {
+		update(opts)
+
+		return nil
+	}
+}
+
+// setValue returns the apply function of a flag that passes its value to
+// update.
+func setValue(update func(opts *options, value string)) func(*options, string) error {
+	return func(opts *options, value string) error {
+		update(opts, value)
+
+		return nil
+	}
+}
+
+// messageFlags returns the flags controlling messages.
+func messageFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "v",
+			long:          "verbose",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.verbose = true }),
+		},
+		{
+			short:         "q",
+			long:          "quiet",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.quiet = true }),
+		},
+		{
+			short:         "",
+			long:          "verbose-errors",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.verboseErrors = true }),
+		},
+		{
+			short:         "",
+			long:          "summary",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.summary = true }),
+		},
+		{
+			short:         "",
+			long:          "log-format",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyLogFormat,
+		},
+	}
+}
+
+// applyLogFormat implements --log-format.
+func applyLogFormat(opts *options, value string) error {
+	if value != logFormatText && value != logFormatJSON {
+		return fmt.Errorf("unsupported log format '%s'", value) //nolint:err113
+	}
+
+	opts.logFormat = value
+
+	return nil
+}
+
+// progressFlags returns the flags reporting progress.
+func progressFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "progress",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyProgress,
+		},
+		{
+			short:         "",
+			long:          "overall-progress",
+			hasValue:      true,
+			optionalValue: true,
+			apply:         applyOverallProgress,
+		},
+		{
+			short:         "",
+			long:          "progress-to",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         setValue(func(opts *options, value string) { opts.progressTo = value }),
+		},
+	}
+}
+
+// applyProgress implements --progress.
+func applyProgress(opts *options, value string) error {
+	if value != progressPlain {
+		return fmt.Errorf("unsupported progress style '%s'", value) //nolint:err113
+	}
+
+	opts.progress = value
+
+	return nil
+}
+
+// applyOverallProgress implements --overall-progress.
+func applyOverallProgress(opts *options, value string) error {
+	if value == "" {
+		opts.overallProgress = true
+
+		return nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid boolean '%s'", value) //nolint:err113
+	}
+
+	opts.overallProgress = enabled
+
+	return nil
+}
+
+// conflictFlags returns the flags deciding whether an existing destination
+// is overwritten.
+func conflictFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "f",
+			long:          "force",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         applyForce,
+		},
+		{
+			short:         "i",
+			long:          "interactive",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.destExists = policyPrompt }),
+		},
+		{
+			short:         "n",
+			long:          "no-clobber",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.destExists = policySkip }),
+		},
+		{
+			short:         "u",
+			long:          "",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.destExists = policyUpdate }),
+		},
+		{
+			short:         "",
+			long:          "update",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyUpdate,
+		},
+		{
+			short:         "",
+			long:          "dest-exists-policy",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyDestExistsPolicy,
+		},
+		{
+			short:         "",
+			long:          "on-conflict",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyDestExistsPolicy,
+		},
+		{
+			short:         "",
+			long:          "timestamp-resolution",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyTimestampResolution,
+		},
+	}
+}
+
+// applyForce implements --force.
+func applyForce(opts *options, _ string) error {
+	opts.force = true
+	opts.destExists = policyOverwrite
+
+	return nil
+}
+
+// applyUpdate implements --update.
+func applyUpdate(opts *options, value string) error {
+	policy, ok := updatePolicies()[value]
+	if !ok {
+		return fmt.Errorf("unsupported update mode '%s'", value) //nolint:err113
+	}
+
+	opts.destExists = policy
+
+	return nil
+}
+
+// applyTimestampResolution implements --timestamp-resolution.
+func applyTimestampResolution(opts *options, value string) error {
+	resolution, err := time.ParseDuration(value)
+	if err != nil || resolution <= 0 {
+		return fmt.Errorf("invalid timestamp resolution '%s'", value) //nolint:err113
+	}
+
+	opts.timestampResolution = resolution
+
+	return nil
+}
+
+// replaceFlags returns the flags deciding how an existing destination is
+// replaced.
+func replaceFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "replace-newer-only",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.replaceNewerOnly = true }),
+		},
+		{
+			short:         "",
+			long:          "remove-destination",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.removeDestination = true }),
+		},
+		{
+			short:         "",
+			long:          "trash",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         setValue(func(opts *options, value string) { opts.trash = value }),
+		},
+		{
+			short:         "",
+			long:          "temp-dir",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         setValue(func(opts *options, value string) { opts.tempDir = value }),
+		},
+		{
+			short:         "",
+			long:          "dereference-dest",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.noDereferenceDest = false }),
+		},
+		{
+			short:         "",
+			long:          "no-dereference-dest",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.noDereferenceDest = true }),
+		},
+		{
+			short:         "",
+			long:          "lock",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.lock = true }),
+		},
+	}
+}
+
+// contentFlags returns the flags changing what is written to the
+// destination.
+func contentFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "append",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.appendDest = true }),
+		},
+		{
+			short:         "",
+			long:          "text",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.text = true }),
+		},
+		{
+			short:         "",
+			long:          "eol",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyEOL,
+		},
+		{
+			short:         "",
+			long:          "compress",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyCompress,
+		},
+		{
+			short:         "",
+			long:          "decompress",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.decompress = true }),
+		},
+		{
+			short:         "",
+			long:          "auto",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.auto = true }),
+		},
+		{
+			short:         "",
+			long:          "transform",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyTransform,
+		},
+	}
+}
+
+// applyEOL implements --eol.
+func applyEOL(opts *options, value string) error {
+	if value != eolLF && value != eolCRLF {
+		return fmt.Errorf("unsupported line ending '%s'", value) //nolint:err113
+	}
+
+	opts.text = true
+	opts.eol = value
+
+	return nil
+}
+
+// applyCompress implements --compress.
+func applyCompress(opts *options, value string) error {
+	if value != compressGzip {
+		return fmt.Errorf("unsupported compression '%s'", value) //nolint:err113
+	}
+
+	opts.compress = value
+
+	return nil
+}
+
+// applyTransform implements --transform.
+func applyTransform(opts *options, value string) error {
+	names, err := parseTransforms(value)
+	if err != nil {
+		return err
+	}
+
+	opts.transforms = names
+
+	return nil
+}
+
+// transferFlags returns the flags changing how a file's bytes are
+// transferred.
+func transferFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "skip",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applySkip,
+		},
+		{
+			short:         "",
+			long:          "count",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyCount,
+		},
+		{
+			short:         "",
+			long:          "resume",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.resume = true }),
+		},
+		{
+			short:         "",
+			long:          "partial-suffix",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyPartialSuffix,
+		},
+		{
+			short:         "",
+			long:          "strict",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.strict = true }),
+		},
+		{
+			short:         "",
+			long:          "reflink",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyReflink,
+		},
+		{
+			short:         "",
+			long:          "preallocate",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.preallocate = true }),
+		},
+		{
+			short:         "",
+			long:          "check-space",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.checkSpace = true }),
+		},
+	}
+}
+
+// applySkip implements --skip.
+func applySkip(opts *options, value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+
+	opts.skipBytes = size
+
+	return nil
+}
+
+// applyCount implements --count.
+func applyCount(opts *options, value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+
+	opts.countBytes = size
+
+	return nil
+}
+
+// applyPartialSuffix implements --partial-suffix.
+func applyPartialSuffix(opts *options, value string) error {
+	if value == "" || strings.ContainsAny(value, `/\`) {
+		return fmt.Errorf("invalid suffix '%s'", value) //nolint:err113
+	}
+
+	opts.partialSuffix = value
+
+	return nil
+}
+
+// applyReflink implements --reflink.
+func applyReflink(opts *options, value string) error {
+	if value != reflinkAuto && value != reflinkAlways && value != reflinkNever {
+		return fmt.Errorf("unsupported reflink mode '%s'", value) //nolint:err113
+	}
+
+	opts.reflink = value
+
+	return nil
+}
+
+// tuningFlags returns the flags tuning buffering, timeouts and retries.
+func tuningFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "buffer-size",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyBufferSize,
+		},
+		{
+			short:         "",
+			long:          "chunk-size",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyChunkSize,
+		},
+		{
+			short:         "",
+			long:          "flush-interval",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyFlushInterval,
+		},
+		{
+			short:         "",
+			long:          "timeout",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyTimeout,
+		},
+		{
+			short:         "",
+			long:          "retry",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyRetry,
+		},
+		{
+			short:         "",
+			long:          "retry-delay",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyRetryDelay,
+		},
+	}
+}
+
+// applyBufferSize implements --buffer-size.
+func applyBufferSize(opts *options, value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+
+	if size <= 0 || size > maxBufferSize {
+		return fmt.Errorf( //nolint:err113
+			"buffer size must be between 1 and %d bytes", maxBufferSize,
+		)
+	}
+
+	opts.bufferSize = int(size)
+
+	return nil
+}
+
+// applyChunkSize implements --chunk-size.
+func applyChunkSize(opts *options, value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+
+	if size <= 0 || size > maxBufferSize {
+		return fmt.Errorf( //nolint:err113
+			"chunk size must be between 1 and %d bytes", maxBufferSize,
+		)
+	}
+
+	opts.chunkSize = int(size)
+
+	return nil
+}
+
+// applyFlushInterval implements --flush-interval.
+func applyFlushInterval(opts *options, value string) error {
+	size, err := parseSize(value)
+	if err != nil || size == 0 {
+		return fmt.Errorf("invalid flush interval '%s'", value) //nolint:err113
+	}
+
+	opts.flushInterval = size
+
+	return nil
+}
+
+// applyTimeout implements --timeout.
+func applyTimeout(opts *options, value string) error {
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if timeout <= 0 {
+		return errors.New("must be positive") //nolint:err113
+	}
+
+	opts.timeout = timeout
+
+	return nil
+}
+
+// applyRetry implements --retry.
+func applyRetry(opts *options, value string) error {
+	retry, err := strconv.Atoi(value)
+	if err != nil || retry < 0 {
+		return fmt.Errorf("invalid retry count '%s'", value) //nolint:err113
+	}
+
+	opts.retry = retry
+
+	return nil
+}
+
+// applyRetryDelay implements --retry-delay.
+func applyRetryDelay(opts *options, value string) error {
+	delay, err := time.ParseDuration(value)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if delay <= 0 {
+		return errors.New("must be positive") //nolint:err113
+	}
+
+	opts.retryDelay = delay
+
+	return nil
+}
+
+// recursiveFlags returns the flags for recursive copies.
+func recursiveFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "r",
+			long:          "recursive",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.recursive = true }),
+		},
+		{
+			short:         "a",
+			long:          "archive",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         applyArchive,
+		},
+		{
+			short:         "",
+			long:          "max-depth",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyMaxDepth,
+		},
+		{
+			short:         "",
+			long:          "follow-mounts",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyFollowMounts,
+		},
+		{
+			short:         "",
+			long:          "prune-empty-dirs",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.pruneEmptyDirs = true }),
+		},
+		{
+			short:         "",
+			long:          "ignore-errors",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.ignoreErrors = true }),
+		},
+	}
+}
+
+// applyArchive implements --archive.
+func applyArchive(opts *options, _ string) error {
+	opts.archive = true
+	opts.recursive = true
+	opts.preserve |= preserveAll
+
+	return nil
+}
+
+// applyMaxDepth implements --max-depth.
+func applyMaxDepth(opts *options, value string) error {
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 0 {
+		return fmt.Errorf("invalid depth '%s'", value) //nolint:err113
+	}
+
+	opts.maxDepth = &depth
+
+	return nil
+}
+
+// applyFollowMounts implements --follow-mounts.
+func applyFollowMounts(opts *options, value string) error {
+	follow, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid boolean '%s'", value) //nolint:err113
+	}
+
+	opts.noFollowMounts = !follow
+
+	return nil
+}
+
+// symlinkFlags returns the flags deciding which symlinks are followed.
+func symlinkFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "H",
+			long:          "dereference-command-line-only",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.dereference = derefCommandLine }),
+		},
+		{
+			short:         "L",
+			long:          "dereference",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.dereference = derefAlways }),
+		},
+		{
+			short:         "P",
+			long:          "no-dereference",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.dereference = derefNever }),
+		},
+		{
+			short:         "",
+			long:          "preserve-root",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.noPreserveRoot = false }),
+		},
+		{
+			short:         "",
+			long:          "no-preserve-root",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.noPreserveRoot = true }),
+		},
+	}
+}
+
+// filterFlags returns the flags leaving files out of a copy.
+func filterFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "exclude",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyExclude,
+		},
+		{
+			short:         "",
+			long:          "min-size",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyMinSize,
+		},
+		{
+			short:         "",
+			long:          "max-size",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyMaxSize,
+		},
+		{
+			short:         "",
+			long:          "newer-than",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyNewerThan,
+		},
+	}
+}
+
+// applyExclude implements --exclude.
+func applyExclude(opts *options, value string) error {
+	if _, err := filepath.Match(value, ""); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	opts.exclude = append(opts.exclude, value)
+
+	return nil
+}
+
+// applyMinSize implements --min-size.
+func applyMinSize(opts *options, value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+
+	opts.minSize = size
+
+	return nil
+}
+
+// applyMaxSize implements --max-size.
+func applyMaxSize(opts *options, value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+
+	opts.maxSize = size
+
+	return nil
+}
+
+// applyNewerThan implements --newer-than.
+func applyNewerThan(opts *options, value string) error {
+	since, err := parseSince(value, time.Now())
+	if err != nil {
+		return err
+	}
+
+	opts.newerThan = since
+
+	return nil
+}
+
+// attributeFlags returns the flags preserving and setting file attributes.
+func attributeFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "p",
+			long:          "",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.preserve |= preserveDefault }),
+		},
+		{
+			short:         "",
+			long:          "preserve",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyPreserve,
+		},
+		{
+			short:         "",
+			long:          "no-preserve",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyNoPreserve,
+		},
+		{
+			short:         "",
+			long:          "mode",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyMode,
+		},
+		{
+			short:         "",
+			long:          "owner",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyOwner,
+		},
+		{
+			short:         "",
+			long:          "group",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyGroup,
+		},
+	}
+}
+
+// applyPreserve implements --preserve.
+func applyPreserve(opts *options, value string) error {
+	attrs, err := parsePreserveList(value)
+	if err != nil {
+		return err
+	}
+
+	opts.preserve |= attrs
+	opts.preserveExplicit |= attrs
+
+	return nil
+}
+
+// applyNoPreserve implements --no-preserve.
+func applyNoPreserve(opts *options, value string) error {
+	attrs, err := parsePreserveList(value)
+	if err != nil {
+		return err
+	}
+
+	opts.noPreserve |= attrs
+
+	return nil
+}
+
+// applyMode implements --mode.
+func applyMode(opts *options, value string) error {
+	spec, err := parseModeSpec(value)
+	if err != nil {
+		return err
+	}
+
+	opts.mode = spec
+
+	return nil
+}
+
+// applyOwner implements --owner.
+func applyOwner(opts *options, value string) error {
+	uid, err := lookupUser(value)
+	if err != nil {
+		return err
+	}
+
+	opts.ownershipOverride().uid = uid
+
+	return nil
+}
+
+// applyGroup implements --group.
+func applyGroup(opts *options, value string) error {
+	gid, err := lookupGroup(value)
+	if err != nil {
+		return err
+	}
+
+	opts.ownershipOverride().gid = gid
+
+	return nil
+}
+
+// permissionFlags returns the flags setting the permissions of created
+// files and directories.
+func permissionFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "dir-mode",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyDirMode,
+		},
+		{
+			short:         "",
+			long:          "destination-mode",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyDestinationMode,
+		},
+		{
+			short:         "",
+			long:          "sync-dir-modes",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.syncDirModes = true }),
+		},
+		{
+			short:         "",
+			long:          "normalize-permissions",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.normalizePerms = true }),
+		},
+		{
+			short:         "",
+			long:          "umask",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyUmaskFlag,
+		},
+	}
+}
+
+// applyDirMode implements --dir-mode.
+func applyDirMode(opts *options, value string) error {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > uint64(fs.ModePerm) {
+		return fmt.Errorf("invalid directory mode '%s'", value) //nolint:err113
+	}
+
+	opts.dirMode = fs.FileMode(mode)
+
+	return nil
+}
+
+// applyDestinationMode implements --destination-mode.
+func applyDestinationMode(opts *options, value string) error {
+	if value == "inherit" {
+		opts.inheritDirMode, opts.destDirMode = true, nil
+
+		return nil
+	}
+
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > uint64(fs.ModePerm) {
+		return fmt.Errorf("invalid directory mode '%s'", value) //nolint:err113
+	}
+
+	dirMode := fs.FileMode(mode)
+	opts.inheritDirMode, opts.destDirMode = false, &dirMode
+
+	return nil
+}
+
+// applyUmaskFlag implements --umask.
+func applyUmaskFlag(opts *options, value string) error {
+	mask, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mask > uint64(fs.ModePerm) {
+		return fmt.Errorf("invalid umask '%s'", value) //nolint:err113
+	}
+
+	umask := fs.FileMode(mask)
+	opts.umask = &umask
+
+	return nil
+}
+
+// targetFlags returns the flags naming the destination.
+func targetFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "D",
+			long:          "make-dirs",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.makeDirs = true }),
+		},
+		{
+			short:         "",
+			long:          "into",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         setValue(func(opts *options, value string) { opts.into = value }),
+		},
+		{
+			short:         "",
+			long:          "as",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyAs,
+		},
+		{
+			short:         "",
+			long:          "strip-trailing-slashes",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.stripTrailingSlashes = true }),
+		},
+		{
+			short:         "",
+			long:          "rename",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyRename,
+		},
+	}
+}
+
+// applyAs implements --as.
+func applyAs(opts *options, value string) error {
+	if value == "" || value == "." || value == ".." || strings.ContainsAny(value, `/\`) {
+		return fmt.Errorf("invalid file name '%s'", value) //nolint:err113
+	}
+
+	opts.as = value
+
+	return nil
+}
+
+// applyRename implements --rename.
+func applyRename(opts *options, value string) error {
+	tmpl, err := parseRenameTemplate(value)
+	if err != nil {
+		return err
+	}
+
+	opts.rename = tmpl
+
+	return nil
+}
+
+// listFlags returns the flags reading the sources from a list.
+func listFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "files-from",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         setValue(func(opts *options, value string) { opts.filesFrom = value }),
+		},
+		{
+			short:         "",
+			long:          "source-root",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         setValue(func(opts *options, value string) { opts.sourceRoot = value }),
+		},
+		{
+			short:         "",
+			long:          "from0",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.from0 = true }),
+		},
+		{
+			short:         "0",
+			long:          "null",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.from0 = true }),
+		},
+	}
+}
+
+// operationFlags returns the flags running an operation other than a
+// plain copy.
+func operationFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "to-tar",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         setValue(func(opts *options, value string) { opts.toTar = value }),
+		},
+		{
+			short:         "",
+			long:          "from-tar",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         setValue(func(opts *options, value string) { opts.fromTar = value }),
+		},
+		{
+			short:         "",
+			long:          "compare",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.compare = true }),
+		},
+		{
+			short:         "",
+			long:          "count-only",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.countOnly = true }),
+		},
+		{
+			short:         "",
+			long:          "dry-run",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.dryRun = true }),
+		},
+		{
+			short:         "",
+			long:          "checksum-only",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.checksumOnly = true }),
+		},
+		{
+			short:         "",
+			long:          "exchange",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.exchange = true }),
+		},
+		{
+			short:         "",
+			long:          "list-checksums",
+			hasValue:      false,
+			optionalValue: false,
+			apply:         set(func(opts *options) { opts.listChecksums = true }),
+		},
+	}
+}
+
+// checksumFlags returns the flags choosing and caching the checksum.
+func checksumFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "checksum",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyChecksum,
+		},
+		{
+			short:         "",
+			long:          "checksum-seed",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyChecksumSeed,
+		},
+		{
+			short:         "",
+			long:          "checksum-cache",
+			hasValue:      true,
+			optionalValue: false,
+			apply: setValue(
+				func(opts *options, value string) { opts.checksumCacheFile = value },
+			),
+		},
+	}
+}
+
+// applyChecksum implements --checksum.
+func applyChecksum(opts *options, value string) error {
+	if _, err := newHash(value, 0); err != nil {
+		return err
+	}
+
+	opts.checksum = value
+
+	return nil
+}
+
+// applyChecksumSeed implements --checksum-seed.
+func applyChecksumSeed(opts *options, value string) error {
+	seed, err := strconv.ParseUint(value, 0, 64)
+	if err != nil {
+		return fmt.Errorf("invalid seed '%s'", value) //nolint:err113
+	}
+
+	opts.checksumSeed = seed
+
+	return nil
+}
+
+// verifyFlags returns the flags for verification and manifests.
+func verifyFlags() []flagSpec {
+	return []flagSpec{
+		{
+			short:         "",
+			long:          "verify",
+			hasValue:      true,
+			optionalValue: true,
+			apply:         applyVerify,
+		},
+		{
+			short:         "",
+			long:          "verify-manifest",
+			hasValue:      true,
+			optionalValue: false,
+			apply: setValue(
+				func(opts *options, value string) { opts.verifyManifest = value },
+			),
+		},
+		{
+			short:         "",
+			long:          "verify-before-overwrite",
+			hasValue:      true,
+			optionalValue: false,
+			apply: setValue(
+				func(opts *options, value string) { opts.expectDestSum = value },
+			),
+		},
+		{
+			short:         "",
+			long:          "manifest",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         setValue(func(opts *options, value string) { opts.manifest = value }),
+		},
+		{
+			short:         "",
+			long:          "checksum-output-format",
+			hasValue:      true,
+			optionalValue: false,
+			apply:         applyChecksumOutputFormat,
+		},
+	}
+}
+
+// applyVerify implements --verify.
+func applyVerify(opts *options, value string) error {
+	switch value {
+	case "":
+		opts.verify, opts.verifyStrict = true, false
+	case "strict":
+		opts.verify, opts.verifyStrict = true, true
+	default:
+		return fmt.Errorf("unknown verify mode '%s'", value) //nolint:err113
+	}
+
+	return nil
+}
+
+// applyChecksumOutputFormat implements --checksum-output-format.
+func applyChecksumOutputFormat(opts *options, value string) error {
+	if value != manifestSHA256Sum && value != manifestBSD && value != manifestJSON {
+		return fmt.Errorf("unknown checksum output format '%s'", value) //nolint:err113
+	}
+
+	opts.manifestFormat = value
+
+	return nil
+}
+
+// lookupFlag finds the flag spec matching a short or long name.
+func lookupFlag(name string, long bool) (flagSpec, bool) {
+	for _, spec := range flagSpecs() {
+		if long && spec.long == name || !long && spec.short != "" && spec.short == name {
+			return spec, true
+		}
+	}
+
+	var none flagSpec
+
+	return none, false
+}
+
+// parseArgs splits the command-line arguments into flags and positional paths.
+// Short flags can be combined, as in -rfv, and "--" ends the flags so that
+// the arguments after it are paths even if they start with a dash.
+func parseArgs(args []string) (*options, error) {
+	return parseArgsWithDefaults(nil, args)
+}
+
+// parseArgsWithDefaults parses args after the default flags from
+// CP_DEFAULT_FLAGS, which are parsed as an argument list of their own: a
+// flag there can't take its value from args, and paths aren't allowed.
+func parseArgsWithDefaults(defaults, args []string) (*options, error) {
+	opts := new(options)
+	opts.checksum = defaultChecksum
+
+	if err := opts.applyArgs(defaults); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", defaultFlagsEnv, err)
+	}
+
+	if len(opts.paths) != 0 {
+		return nil, fmt.Errorf( //nolint:err113
+			"parsing %s: '%s' is not an option", defaultFlagsEnv, opts.paths[0],
+		)
+	}
+
+	if err := opts.applyArgs(args); err != nil {
+		return nil, err
+	}
+
+	opts.finalize()
+
+	opts.buffers = newBufferPool(opts.copyBufferSize())
+
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// applyArgs applies the flags in args and collects the paths among them.
+func (opts *options) applyArgs(args []string) error {
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+
+		if arg == "--" {
+			opts.paths = append(opts.paths, args[idx+1:]...)
+
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			opts.paths = append(opts.paths, arg)
+
+			continue
+		}
+
+		var (
+			consumed int
+			err      error
+		)
+
+		if strings.HasPrefix(arg, "--") {
+			consumed, err = opts.applyLongFlag(arg[2:], args[idx+1:])
+		} else {
+			consumed, err = opts.applyShortFlags(arg[1:], args[idx+1:])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		idx += consumed
+	}
+
+	return nil
+}
+
+// applyLongFlag applies a long flag, such as "name=value" from --name=value.
+// A flag requiring a value not given inline takes the next argument, in which
+// case one argument of rest is reported consumed.
+func (opts *options) applyLongFlag(arg string, rest []string) (int, error) {
+	name, value, hasInline := strings.Cut(arg, "=")
+	flag := "--" + name
+
+	spec, ok := lookupFlag(name, true)
+	if !ok {
+		return 0, fmt.Errorf("unknown option '%s'", flag) //nolint:err113
+	}
+
+	consumed := 0
+
+	switch {
+	case spec.hasValue && !hasInline && !spec.optionalValue:
+		if len(rest) == 0 {
+			return 0, fmt.Errorf("option '%s' requires a value", flag) //nolint:err113
+		}
+
+		value, consumed = rest[0], 1
+	case !spec.hasValue && hasInline:
+		return 0, fmt.Errorf("option '%s' doesn't allow a value", flag) //nolint:err113
+	}
+
+	if err := spec.apply(opts, value); err != nil {
+		return 0, fmt.Errorf("invalid value for '%s': %w", flag, err)
+	}
+
+	return consumed, nil
+}
+
+// applyShortFlags applies a cluster of short flags, such as "rfv" from -rfv.
+// A flag taking a value takes the rest of the cluster as its value, or else
+// the next argument, in which case one argument of rest is reported consumed.
+func (opts *options) applyShortFlags(cluster string, rest []string) (int, error) {
+	for pos, char := range cluster {
+		name := string(char)
+		flag := "-" + name
+
+		spec, ok := lookupFlag(name, false)
+		if !ok {
+			return 0, fmt.Errorf("unknown option '%s'", flag) //nolint:err113
+		}
+
+		if !spec.hasValue {
+			if err := spec.apply(opts, ""); err != nil {
+				return 0, fmt.Errorf("invalid value for '%s': %w", flag, err)
+			}
+
+			continue
+		}
+
+		value, consumed := cluster[pos+len(name):], 0
+		if value == "" && !spec.optionalValue {
+			if len(rest) == 0 {
+				return 0, fmt.Errorf("option '%s' requires a value", flag) //nolint:err113
+			}
+
+			value, consumed = rest[0], 1
+		}
+
+		if err := spec.apply(opts, value); err != nil {
+			return 0, fmt.Errorf("invalid value for '%s': %w", flag, err)
+		}
+
+		return consumed, nil
+	}
+
+	return 0, nil
+}
+
+// finalize resolves flags that imply others. Flags given explicitly take
+// precedence over those implied by -a, regardless of their order.
+func (opts *options) finalize() {
+	if opts.quiet {
+		opts.verbose = false
+	}
+
+	opts.preserve &^= opts.noPreserve
+	opts.preserveExplicit &^= opts.noPreserve
+
+	if opts.archive && opts.dereference == derefDefault {
+		opts.dereference = derefNever
+	}
+
+	if opts.stripTrailingSlashes {
+		sources := opts.sourcePaths()
+		for idx, source := range sources {
+			sources[idx] = stripTrailingSlashes(source)
+		}
+	}
+}
+
+// sourcePaths returns the paths naming sources: all of them, unless the last
+// one is the destination of a copy.
+func (opts *options) sourcePaths() []string {
+	noDest := opts.toTar != "" || opts.into != "" || opts.checksumOnly || opts.countOnly
+	if noDest || len(opts.paths) == 0 {
+		return opts.paths
+	}
+
+	return opts.paths[:len(opts.paths)-1]
+}
+
+// stripTrailingSlashes removes trailing separators from path, keeping a
+// root such as "/" or "C:\" intact.
+func stripTrailingSlashes(path string) string {
+	root := len(filepath.VolumeName(path)) + 1
+
+	end := len(path)
+	for end > root && os.IsPathSeparator(path[end-1]) {
+		end--
+	}
+
+	return path[:end]
+}
+
+// flagConflict is a combination of flags validate rejects: flag, given
+// together with any of with. A flag another one requires counts as given
+// when it's missing.
+type flagConflict struct {
+	flag    bool
+	with    []bool
+	message string
+}
+
+// validate rejects combinations of flags that can't work together.
+func (opts *options) validate() error {
+	conflicts := slices.Concat(
+		opts.targetConflicts(),
+		opts.contentConflicts(),
+		opts.replaceConflicts(),
+		opts.modeConflicts(),
+	)
+
+	for _, conflict := range conflicts {
+		if conflict.flag && slices.Contains(conflict.with, true) {
+			return errors.New(conflict.message) //nolint:err113
+		}
+	}
+
+	return nil
+}
+
+// targetConflicts returns the --files-from, --rename, --source-root, --as and
+// --into combinations that leave no well-defined sources and destinations.
+func (opts *options) targetConflicts() []flagConflict {
+	return []flagConflict{
+		{
+			flag: opts.filesFrom != "",
+			with: []bool{
+				opts.compare, opts.exchange, opts.fromTar != "", opts.toTar != "", opts.checksumOnly,
+			},
+			message: "--files-from can't be combined with --compare, --exchange, --from-tar, " +
+				"--to-tar or --checksum-only",
+		},
+		// Only batch copies into a directory have files to rename.
+		{
+			flag:    opts.rename != nil,
+			with:    []bool{!opts.batchSource()},
+			message: "--rename requires --files-from or a wildcard source (Windows)",
+		},
+		{
+			flag:    opts.sourceRoot != "",
+			with:    []bool{opts.filesFrom == "", opts.rename != nil},
+			message: "--source-root requires --files-from and can't be combined with --rename",
+		},
+		{
+			flag:    opts.as != "",
+			with:    []bool{opts.into == ""},
+			message: "--as requires --into",
+		},
+		{
+			flag:    opts.into != "",
+			with:    []bool{opts.filesFrom != ""},
+			message: "--into can't be combined with --files-from",
+		},
+	}
+}
+
+// batchSource reports whether the sources are a batch, read by --files-from
+// or matched by a Windows wildcard.
+func (opts *options) batchSource() bool {
+	return opts.filesFrom != "" || len(opts.paths) > 0 && isWindowsGlob(opts.paths[0])
+}
+
+// contentConflicts returns the conversions of the copied bytes combined with
+// flags that need them unchanged, such as --resume and --verify.
+func (opts *options) contentConflicts() []flagConflict {
+	return []flagConflict{
+		{
+			flag:    opts.compress != "",
+			with:    []bool{opts.verify},
+			message: "--compress can't be combined with --verify",
+		},
+		{
+			flag:    opts.compress != "",
+			with:    []bool{opts.resume},
+			message: "--compress can't be combined with --resume",
+		},
+		{
+			flag:    len(opts.transforms) > 0,
+			with:    []bool{opts.verify, opts.resume},
+			message: "--transform can't be combined with --verify or --resume",
+		},
+		{
+			flag:    opts.reflink == reflinkAlways,
+			with:    []bool{!opts.canClone(), opts.resume},
+			message: reflinkAlwaysConflict(),
+		},
+		{
+			flag:    opts.hasRange(),
+			with:    []bool{opts.resume, opts.verify, opts.decompress, opts.auto},
+			message: "--skip and --count can't be combined with --resume, --verify, --decompress or --auto",
+		},
+		{
+			flag:    opts.text,
+			with:    []bool{opts.resume, opts.verify},
+			message: "--text can't be combined with --resume or --verify",
+		},
+		{
+			flag:    opts.decompress || opts.auto,
+			with:    []bool{opts.verify, opts.resume},
+			message: "--decompress and --auto can't be combined with --verify or --resume",
+		},
+	}
+}
+
+// replaceConflicts returns the ways of replacing the destination that
+// contradict each other.
+func (opts *options) replaceConflicts() []flagConflict {
+	return []flagConflict{
+		// A resumed or appended destination is newer than its source by nature.
+		{
+			flag:    opts.replaceNewerOnly,
+			with:    []bool{opts.resume, opts.appendDest},
+			message: "--replace-newer-only can't be combined with --resume or --append",
+		},
+		// A retried attempt would trash the previous attempt's partial copy.
+		{
+			flag:    opts.trash != "",
+			with:    []bool{opts.resume, opts.appendDest, opts.retry > 0},
+			message: "--trash can't be combined with --resume, --append or --retry",
+		},
+		{
+			flag: opts.appendDest,
+			with: []bool{
+				opts.resume, opts.verify, opts.removeDestination, opts.noDereferenceDest, opts.retry > 0,
+			},
+			message: "--append can't be combined with --resume, --verify, --remove-destination, " +
+				"--no-dereference-dest or --retry",
+		},
+		{
+			flag:    opts.partialSuffix != "",
+			with:    []bool{opts.resume, opts.appendDest},
+			message: "--partial-suffix can't be combined with --resume or --append",
+		},
+		{
+			flag:    opts.removeDestination,
+			with:    []bool{opts.resume},
+			message: "--remove-destination can't be combined with --resume",
+		},
+	}
+}
+
+// modeConflicts returns the --dry-run, checksum, progress and size flags
+// given with flags they don't apply to.
+func (opts *options) modeConflicts() []flagConflict {
+	return []flagConflict{
+		// Copying a tree or making parent directories would create directories.
+		{
+			flag:    opts.dryRun,
+			with:    []bool{opts.recursive, opts.dereference == derefNever, opts.makeDirs},
+			message: "--dry-run can't be combined with -r, -P or -D",
+		},
+		// These write files of their own before or instead of copying.
+		{
+			flag: opts.dryRun,
+			with: []bool{
+				opts.exchange, opts.toTar != "", opts.fromTar != "", opts.lock,
+				opts.progressTo != "", opts.checksumCacheFile != "",
+			},
+			message: "--dry-run can't be combined with --exchange, --to-tar, --from-tar, --lock, " +
+				"--progress-to or --checksum-cache",
+		},
+		{
+			flag:    opts.checksumSeed != 0,
+			with:    []bool{opts.checksum != "xxhash"},
+			message: "--checksum-seed requires --checksum=xxhash",
+		},
+		{
+			flag:    opts.checksumCacheFile != "",
+			with:    []bool{opts.checksum != "sha256"},
+			message: "--checksum-cache only supports --checksum=sha256",
+		},
+		{
+			flag:    opts.chunkSize > 0,
+			with:    []bool{opts.bufferSize > 0},
+			message: "--chunk-size can't be combined with --buffer-size",
+		},
+		{
+			flag:    opts.overallProgress,
+			with:    []bool{opts.progress == "", !opts.recursive},
+			message: "--overall-progress requires --progress and -r",
+		},
+		{
+			flag:    opts.progressTo != "",
+			with:    []bool{opts.progress == ""},
+			message: "--progress-to requires --progress",
+		},
+		{
+			flag:    opts.maxSize > 0,
+			with:    []bool{opts.minSize > opts.maxSize},
+			message: "--min-size can't be larger than --max-size",
+		},
+	}
+}
+
+// ownershipOverride returns the --owner and --group overrides, creating
+// them on first use.
+func (opts *options) ownershipOverride() *ownership {
+	if opts.chown == nil {
+		opts.chown = &ownership{uid: -1, gid: -1}
+	}
+
+	return opts.chown
+}
+
+// excluded reports whether a walked entry's base name matches an --exclude pattern.
+func (opts *options) excluded(name string) bool {
+	for _, pattern := range opts.exclude {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shouldDecompress reports whether the source at path is to be gunzipped,
+// either because --decompress was given or --auto recognizes a .gz name.
+func (opts *options) shouldDecompress(path string) bool {
+	return opts.decompress || opts.auto && strings.EqualFold(filepath.Ext(path), ".gz")
+}
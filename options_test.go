@@ -0,0 +1,178 @@
+package main
+
+import (
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestStripTrailingSlashes tests trimming of trailing separators.
+func TestStripTrailingSlashes(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("test uses slash-separated paths")
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "plain", input: "dir", want: "dir"},
+		{name: "single", input: "dir/", want: "dir"},
+		{name: "repeated", input: "a/b///", want: "a/b"},
+		{name: "root", input: "/", want: "/"},
+		{name: "double root", input: "//", want: "/"},
+		{name: "empty", input: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := stripTrailingSlashes(tt.input); got != tt.want {
+				t.Errorf("stripTrailingSlashes(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseArgs_StripTrailingSlashes tests that only source arguments are
+// trimmed, including the last one in modes that take no destination path.
+func TestParseArgs_StripTrailingSlashes(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("test uses slash-separated paths")
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "copy",
+			args: []string{"src/", "dest/"},
+			want: []string{"src", "dest/"},
+		},
+		{
+			name: "--into",
+			args: []string{"--into=dir/", "src/"},
+			want: []string{"src"},
+		},
+		{
+			name: "--to-tar",
+			args: []string{"--to-tar=out.tar", "a/", "b/"},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "--checksum-only",
+			args: []string{"--checksum-only", "src/"},
+			want: []string{"src"},
+		},
+		{
+			name: "--count-only",
+			args: []string{"--count-only", "src/"},
+			want: []string{"src"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := parseArgs(append([]string{"--strip-trailing-slashes"}, tt.args...))
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if !slices.Equal(opts.paths, tt.want) {
+				t.Errorf("paths = %q, want %q", opts.paths, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseArgs_CombinedShortFlags tests that a cluster of short flags sets
+// the same options as giving them separately.
+func TestParseArgs_CombinedShortFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		combined []string
+		separate []string
+	}{
+		{name: "-rp", combined: []string{"-rp"}, separate: []string{"-r", "-p"}},
+		{name: "-rfv", combined: []string{"-rfv"}, separate: []string{"-r", "-f", "-v"}},
+		{name: "-HL last wins", combined: []string{"-HL"}, separate: []string{"-H", "-L"}},
+		{name: "-aq0", combined: []string{"-aq0"}, separate: []string{"-a", "-q", "-0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			combined, err := parseArgs(append(tt.combined, "src", "dst"))
+			if err != nil {
+				t.Fatalf("parseArgs(%v) failed: %v", tt.combined, err)
+			}
+
+			separate, err := parseArgs(append(tt.separate, "src", "dst"))
+			if err != nil {
+				t.Fatalf("parseArgs(%v) failed: %v", tt.separate, err)
+			}
+
+			if !sameShortFlags(combined, separate) {
+				t.Errorf("%v parsed as %+v, want %+v", tt.combined, combined, separate)
+			}
+		})
+	}
+}
+
+// sameShortFlags reports whether first and second agree on the options set
+// by the short flags of TestParseArgs_CombinedShortFlags and on the paths.
+func sameShortFlags(first, second *options) bool {
+	return first.recursive == second.recursive && first.preserve == second.preserve &&
+		first.force == second.force && first.verbose == second.verbose &&
+		first.quiet == second.quiet && first.dereference == second.dereference &&
+		first.from0 == second.from0 && slices.Equal(first.paths, second.paths)
+}
+
+// TestParseArgs_UnknownShortFlagInCluster tests that an unknown flag in a
+// cluster is named on its own.
+func TestParseArgs_UnknownShortFlagInCluster(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseArgs([]string{"-rZv", "src", "dst"})
+	if err == nil || !strings.Contains(err.Error(), "unknown option '-Z'") {
+		t.Fatalf("expected unknown option '-Z', got: %v", err)
+	}
+}
+
+// TestParseArgs_EndOfOptions tests that "--" makes every later argument a
+// path, even one that looks like a flag.
+func TestParseArgs_EndOfOptions(t *testing.T) {
+	t.Parallel()
+
+	opts, err := parseArgs([]string{"-r", "--", "-v", "--quiet"})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if !opts.recursive || opts.verbose || opts.quiet {
+		t.Errorf(
+			"flags after -- were applied: recursive=%v verbose=%v quiet=%v",
+			opts.recursive,
+			opts.verbose,
+			opts.quiet,
+		)
+	}
+
+	if want := []string{"-v", "--quiet"}; !slices.Equal(opts.paths, want) {
+		t.Errorf("paths = %q, want %q", opts.paths, want)
+	}
+}
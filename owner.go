@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// ownership holds the --owner and --group overrides; -1 leaves an id unchanged.
+type ownership struct {
+	uid int
+	gid int
+}
+
+// lookupUser resolves a user name or numeric uid.
+func lookupUser(name string) (int, error) {
+	if uid, err := strconv.Atoi(name); err == nil {
+		return uid, nil
+	}
+
+	account, err := user.Lookup(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown user '%s': %w", name, err)
+	}
+
+	uid, err := strconv.Atoi(account.Uid)
+	if err != nil {
+		return 0, fmt.Errorf("user '%s' has no numeric uid", name) //nolint:err113
+	}
+
+	return uid, nil
+}
+
+// lookupGroup resolves a group name or numeric gid.
+func lookupGroup(name string) (int, error) {
+	if gid, err := strconv.Atoi(name); err == nil {
+		return gid, nil
+	}
+
+	group, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown group '%s': %w", name, err)
+	}
+
+	gid, err := strconv.Atoi(group.Gid)
+	if err != nil {
+		return 0, fmt.Errorf("group '%s' has no numeric gid", name) //nolint:err113
+	}
+
+	return gid, nil
+}
+
+// overrideOwnership applies --owner and --group to dest.
+func overrideOwnership(opts *options, dest string) error {
+	if opts.chown == nil {
+		return nil
+	}
+
+	if err := os.Lchown(dest, opts.chown.uid, opts.chown.gid); err != nil {
+		return fmt.Errorf("setting ownership of '%s': %w", dest, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// copyOwnership reports that ownership can't be preserved on this platform.
+func copyOwnership(_ string, _ fs.FileInfo) error {
+	return errors.New("not supported on this platform") //nolint:err113
+}
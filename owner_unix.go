@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// copyOwnership sets the owner and group of dest to those recorded in info.
+func copyOwnership(dest string, info fs.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.New("ownership information unavailable") //nolint:err113
+	}
+
+	return os.Lchown(dest, int(stat.Uid), int(stat.Gid)) //nolint:wrapcheck
+}
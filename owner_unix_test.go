@@ -0,0 +1,122 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// checkOwner checks that path is owned by the given numeric uid and gid.
+func checkOwner(t *testing.T, path, uid, gid string) {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", path, err)
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Fatalf("no ownership information for %s", path)
+	}
+
+	gotUID := strconv.FormatUint(uint64(stat.Uid), 10)
+	gotGID := strconv.FormatUint(uint64(stat.Gid), 10)
+
+	if gotUID != uid || gotGID != gid {
+		t.Errorf("ownership of %s = %s:%s, want %s:%s", path, gotUID, gotGID, uid, gid)
+	}
+}
+
+// TestCopyFile_PreserveOwnership tests that -p carries over uid and gid.
+func TestCopyFile_PreserveOwnership(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() != 0 {
+		t.Skip("changing ownership requires root")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create a source owned by another user
+	if err := os.WriteFile(sourceFile, []byte("owned"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Chown(sourceFile, 1234, 5678); err != nil {
+		t.Fatalf("failed to chown source file: %v", err)
+	}
+
+	// Test: Copy with -p
+	opts, err := parseArgs([]string{"-p", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Ownership matches
+	checkOwner(t, destFile, "1234", "5678")
+}
+
+// TestCopyFile_OwnerGroupOverride tests that --owner and --group set the
+// destination ownership by name.
+func TestCopyFile_OwnerGroupOverride(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() != 0 {
+		t.Skip("changing ownership requires root")
+	}
+
+	account, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skipf("user nobody not found: %v", err)
+	}
+
+	group, err := user.LookupGroupId(account.Gid)
+	if err != nil {
+		t.Skipf("group of nobody not found: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, []byte("service file"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy setting the owner and group by name
+	args := []string{"--owner=nobody", "--group=" + group.Name, sourceFile, destFile}
+
+	opts, err := parseArgs(args)
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Ownership matches the named user and group
+	checkOwner(t, destFile, account.Uid, group.Gid)
+}
+
+// TestParseArgs_UnknownOwner tests that an unknown user name is rejected.
+func TestParseArgs_UnknownOwner(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parseArgs([]string{"--owner=no-such-user-here", "a", "b"}); err == nil {
+		t.Error("expected error for unknown user, got nil")
+	}
+}
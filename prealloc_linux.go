@@ -0,0 +1,27 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fallocKeepSize is FALLOC_FL_KEEP_SIZE, reserving blocks without changing
+// the file size.
+const fallocKeepSize = 0x01
+
+// preallocate reserves size bytes of disk space for file with fallocate(2).
+func preallocate(file *os.File, size int64) error {
+	if err := syscall.Fallocate(int(file.Fd()), fallocKeepSize, 0, size); err != nil {
+		return fmt.Errorf("fallocate: %w", err)
+	}
+
+	return nil
+}
+
+// trimPreallocated is a no-op, as preallocate leaves the size of file alone.
+func trimPreallocated(*os.File) error {
+	return nil
+}
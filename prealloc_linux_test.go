@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFile_Preallocate tests that --preallocate copies a large file intact.
+func TestCopyFile_Preallocate(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+	content := bytes.Repeat([]byte("preallocate "), 512*1024)
+
+	// Setup: Create a large source file
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with preallocation
+	opts := new(options)
+	opts.preallocate = true
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Size and content match
+	got, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if len(got) != len(content) {
+		t.Fatalf("size mismatch: got %d, want %d", len(got), len(content))
+	}
+
+	if !bytes.Equal(got, content) {
+		t.Error("content mismatch")
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// preallocate extends file to size bytes, which is the closest portable
+// equivalent of reserving the space.
+func preallocate(file *os.File, size int64) error {
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("truncate: %w", err)
+	}
+
+	return nil
+}
+
+// trimPreallocated cuts file, extended by preallocate, back to the bytes
+// written to it, which are fewer if the source shrank or the copy failed.
+func trimPreallocated(file *os.File) error {
+	written, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("seek: %w", err)
+	}
+
+	if err := file.Truncate(written); err != nil {
+		return fmt.Errorf("truncate: %w", err)
+	}
+
+	return nil
+}
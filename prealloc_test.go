@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCopyFile_PreallocateTrimmed tests that --preallocate leaves the
+// destination holding only the bytes written, when the source shrinks while
+// it's copied or the copy fails.
+func TestCopyFile_PreallocateTrimmed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		// tail is read after the first 4 bytes of the source.
+		tail    io.Reader
+		written string
+		wantErr error
+	}{
+		{
+			name:    "source shrinks",
+			args:    nil,
+			tail:    strings.NewReader(""),
+			written: "dest.txt",
+			wantErr: nil,
+		},
+		{
+			name:    "failed copy keeps partial file",
+			args:    []string{"--partial-suffix=.part"},
+			tail:    failingReader{err: io.ErrUnexpectedEOF},
+			written: "dest.txt.part",
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "source.txt")
+			destFile := filepath.Join(tmpDir, "dest.txt")
+
+			// Setup: Create a source file whose reads stop early
+			if err := os.WriteFile(sourceFile, []byte("contents"), 0o600); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			args := append([]string{"-q", "--preallocate"}, tt.args...)
+
+			opts, err := parseArgs(append(args, sourceFile, destFile))
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			opts.readSource = func(reader io.Reader) io.Reader {
+				return io.MultiReader(io.LimitReader(reader, 4), tt.tail)
+			}
+
+			// Test: Copy with preallocation
+			_, err = copyFile(t.Context(), opts, sourceFile, destFile)
+
+			// Verify: The file written holds only the bytes read
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+
+			got, err := os.ReadFile(filepath.Join(tmpDir, tt.written))
+			if err != nil || string(got) != "cont" {
+				t.Errorf("%s = %q (%v), want %q", tt.written, got, err, "cont")
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// errBirthTimeUnsupported is returned where birth times can't be preserved.
+var errBirthTimeUnsupported = errors.New("birth time can't be set on this platform")
+
+// errSELinuxDisabled is returned where SELinux contexts can't be preserved.
+var errSELinuxDisabled = errors.New("SELinux is not enabled")
+
+// preserveAttrs is a set of file attributes to carry over to the destination.
+type preserveAttrs uint
+
+const (
+	preserveMode preserveAttrs = 1 << iota
+	preserveOwnership
+	preserveTimestamps
+	preserveXattr
+	preserveLinks
+	// preserveBirthtime is only preserved when requested by name, since most
+	// platforms can't set it.
+	preserveBirthtime
+	// preserveFlags carries over chattr flags such as immutable; it is only
+	// preserved when requested by name, since it can make dest unchangeable.
+	preserveFlags
+	// preserveContext carries over the SELinux security context; it is only
+	// preserved when requested by name, as in GNU cp.
+	preserveContext
+
+	// preserveDefault is the set preserved by -p.
+	preserveDefault = preserveMode | preserveOwnership | preserveTimestamps
+	// preserveAll is the set preserved by -a and --preserve=all.
+	preserveAll = preserveDefault | preserveXattr | preserveLinks
+)
+
+// preserveNames maps --preserve attribute names to their flags.
+func preserveNames() map[string]preserveAttrs {
+	return map[string]preserveAttrs{
+		"mode":       preserveMode,
+		"ownership":  preserveOwnership,
+		"timestamps": preserveTimestamps,
+		"xattr":      preserveXattr,
+		"links":      preserveLinks,
+		"birthtime":  preserveBirthtime,
+		"flags":      preserveFlags,
+		"context":    preserveContext,
+		"all":        preserveAll,
+	}
+}
+
+// parsePreserveList parses a comma-separated list of attribute names.
+func parsePreserveList(value string) (preserveAttrs, error) {
+	var attrs preserveAttrs
+
+	names := preserveNames()
+
+	for name := range strings.SplitSeq(value, ",") {
+		attr, ok := names[strings.TrimSpace(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown attribute '%s'", name) //nolint:err113
+		}
+
+		attrs |= attr
+	}
+
+	return attrs, nil
+}
+
+// applyAttributes copies the attributes requested in opts from source,
+// described by info, onto dest. Only ownership is applied to symlinks.
+func applyAttributes(opts *options, source, dest string, info fs.FileInfo) error {
+	attrs := opts.preserve
+
+	if err := applyOwnership(opts, dest, info); err != nil {
+		return err
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+
+	if err := applyLabels(opts, source, dest); err != nil {
+		return err
+	}
+
+	if attrs&preserveMode != 0 {
+		mode := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+		if err := os.Chmod(dest, mode); err != nil {
+			return fmt.Errorf("preserving mode: %w", err)
+		}
+	}
+
+	if attrs&preserveTimestamps != 0 {
+		if err := os.Chtimes(dest, accessTime(info), info.ModTime()); err != nil {
+			return fmt.Errorf("preserving timestamps: %w", err)
+		}
+	}
+
+	return applyBirthTime(opts, dest, info)
+}
+
+// applyOwnership implements --preserve=ownership. A failure is only a
+// warning unless ownership was asked for explicitly.
+func applyOwnership(opts *options, dest string, info fs.FileInfo) error {
+	if opts.preserve&preserveOwnership == 0 {
+		return nil
+	}
+
+	err := copyOwnership(dest, info)
+	if err != nil && opts.preserveExplicit&preserveOwnership != 0 {
+		return fmt.Errorf("preserving ownership: %w", err)
+	}
+
+	if err != nil {
+		opts.warnf("preserving ownership of '%s': %v", dest, err)
+	}
+
+	return nil
+}
+
+// applyLabels implements --preserve=xattr and --preserve=context. Only a
+// security context that can't be set while SELinux is enabled is an error.
+func applyLabels(opts *options, source, dest string) error {
+	if opts.preserve&preserveXattr != 0 {
+		if err := copyXattrs(source, dest); err != nil {
+			opts.warnf("preserving extended attributes of '%s': %v", dest, err)
+		}
+	}
+
+	if opts.preserve&preserveContext == 0 {
+		return nil
+	}
+
+	err := copySecurityContext(source, dest)
+	if err != nil && !errors.Is(err, errSELinuxDisabled) {
+		return fmt.Errorf("preserving security context: %w", err)
+	}
+
+	if err != nil {
+		opts.warnf("preserving security context of '%s': %v", dest, err)
+	}
+
+	return nil
+}
+
+// applyBirthTime implements --preserve=birthtime, where a platform that
+// can't set it only warrants a warning.
+func applyBirthTime(opts *options, dest string, info fs.FileInfo) error {
+	if opts.preserve&preserveBirthtime == 0 {
+		return nil
+	}
+
+	err := copyBirthTime(dest, info)
+	if err != nil && !errors.Is(err, errBirthTimeUnsupported) {
+		return fmt.Errorf("preserving birth time: %w", err)
+	}
+
+	if err != nil {
+		opts.warnf("preserving birth time of '%s': %v", dest, err)
+	}
+
+	return nil
+}
+
+// applyFileFlags implements --preserve=flags. It must run after every other
+// change to dest, which an immutable or append-only flag would refuse.
+// Failures, typically for lack of privilege, are only warnings.
+func applyFileFlags(opts *options, source, dest string, info fs.FileInfo) {
+	if opts.preserve&preserveFlags == 0 || info.Mode()&fs.ModeSymlink != 0 {
+		return
+	}
+
+	if err := copyFileFlags(source, dest); err != nil {
+		opts.warnf("preserving file flags of '%s': %v", dest, err)
+	}
+}
+
+// copyBirthTime sets the birth time of dest to the one recorded in info.
+func copyBirthTime(dest string, info fs.FileInfo) error {
+	birth, ok := birthTime(info)
+	if !ok {
+		return errBirthTimeUnsupported
+	}
+
+	return setBirthTime(dest, birth)
+}
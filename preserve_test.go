@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestCopyFile_NoPreserve tests that --no-preserve drops attributes implied by -a.
+func TestCopyFile_NoPreserve(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("permission bits are not supported on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.sh")
+	destFile := filepath.Join(tmpDir, "dest.sh")
+	oldTime := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Setup: Create a source with an unusual mode and an old mtime
+	if err := os.WriteFile(sourceFile, []byte("#!/bin/sh"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Chmod(sourceFile, 0o741); err != nil {
+		t.Fatalf("failed to chmod source file: %v", err)
+	}
+
+	if err := os.Chtimes(sourceFile, oldTime, oldTime); err != nil {
+		t.Fatalf("failed to set times: %v", err)
+	}
+
+	// Test: Copy with -a but without timestamps
+	opts, err := parseArgs([]string{"--no-preserve=timestamps", "-a", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Mode is preserved but mtime is fresh
+	info, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatalf("failed to stat destination file: %v", err)
+	}
+
+	if info.Mode().Perm() != 0o741 {
+		t.Errorf("mode mismatch: got %v, want %v", info.Mode().Perm(), os.FileMode(0o741))
+	}
+
+	if info.ModTime().Equal(oldTime) {
+		t.Error("expected a fresh mtime, got the source mtime")
+	}
+}
+
+// TestCopyFile_PreserveBirthtime tests that --preserve=birthtime carries the
+// source creation time over on macOS and Windows.
+func TestCopyFile_PreserveBirthtime(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "darwin" && runtime.GOOS != goosWindows {
+		t.Skip("birth times can only be set on macOS and Windows")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+	oldTime := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// Setup: Create a source with an old birth time
+	if err := os.WriteFile(sourceFile, []byte("born long ago"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := setBirthTime(sourceFile, oldTime); err != nil {
+		t.Fatalf("failed to set birth time: %v", err)
+	}
+
+	// Test: Copy preserving the birth time
+	opts, err := parseArgs([]string{"--preserve=birthtime", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The destination has the source birth time
+	info, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatalf("failed to stat destination file: %v", err)
+	}
+
+	got, ok := birthTime(info)
+	if !ok {
+		t.Fatal("birth time not available")
+	}
+
+	if !got.Equal(oldTime) {
+		t.Errorf("birth time mismatch: got %v, want %v", got, oldTime)
+	}
+}
+
+// TestCopyFile_PreserveBirthtimeUnsupported tests that --preserve=birthtime
+// only warns where birth times can't be set.
+func TestCopyFile_PreserveBirthtimeUnsupported(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "darwin" || runtime.GOOS == goosWindows {
+		t.Skip("birth times are supported on this platform")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, []byte("content"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy preserving the birth time
+	opts, err := parseArgs([]string{"--preserve=birthtime", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	// Verify: The copy succeeds
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// progressPlain is the --progress value selecting line-oriented output.
+	progressPlain = "plain"
+	// progressInterval is the minimum time between two progress lines.
+	progressInterval = 500 * time.Millisecond
+	// progressStep is the percentage after which a line is printed regardless
+	// of the interval.
+	progressStep = 10
+	// percentScale is the percentage of a complete copy.
+	percentScale = 100
+	// progressFileMode is the mode of a file --progress-to creates.
+	progressFileMode = 0o644
+	// lastStdFd is the highest file descriptor of the standard streams.
+	lastStdFd = 2
+)
+
+// percentOf returns how many percent of total bytes copied is, counting an
+// empty total as complete.
+func percentOf(copied, total int64) int64 {
+	if total <= 0 {
+		return percentScale
+	}
+
+	return min(copied*percentScale/total, percentScale)
+}
+
+// progressFunc returns the function the progress of a file's copy is
+// reported to, or nil when no progress is wanted. Under --overall-progress
+// the file's progress is added to that of the whole copy before reporting.
+func (opts *options) progressFunc() func(copied, total int64) {
+	report := opts.progressReporter()
+	if report == nil || opts.overall == nil {
+		return report
+	}
+
+	return opts.overall.track(report)
+}
+
+// progressReporter returns the function progress is finally reported to, or
+// nil when no progress is wanted. A callback set by an embedding program
+// takes precedence; --progress=plain prints "progress: N% (COPIED/TOTAL
+// bytes)" lines on top of the same hook.
+func (opts *options) progressReporter() func(copied, total int64) {
+	if opts.onProgress != nil {
+		return opts.onProgress
+	}
+
+	if opts.progress != progressPlain {
+		return nil
+	}
+
+	out := opts.progressOut
+	if out == nil {
+		out = opts.errorOutput()
+	}
+
+	return func(copied, total int64) {
+		fmt.Fprintf(out, "progress: %d%% (%d/%d bytes)\n", percentOf(copied, total), copied, total)
+	}
+}
+
+// openProgressOutput implements --progress-to, opening the file descriptor
+// or path progress is written to. A number is taken as an inherited file
+// descriptor and anything else as a path, such as a named pipe. The returned
+// function closes it.
+func (opts *options) openProgressOutput() (func(), error) {
+	if opts.progressTo == "" {
+		return func() {}, nil
+	}
+
+	if fd, err := strconv.Atoi(opts.progressTo); err == nil && fd >= 0 {
+		out := os.NewFile(uintptr(fd), "fd "+opts.progressTo)
+		if out == nil {
+			return nil, fmt.Errorf("invalid progress file descriptor %d", fd) //nolint:err113
+		}
+
+		opts.progressOut = out
+
+		// The standard streams stay open for the rest of the program.
+		if fd <= lastStdFd {
+			return func() {}, nil
+		}
+
+		return func() { _ = out.Close() }, nil
+	}
+
+	out, err := os.OpenFile(opts.progressTo, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, progressFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("opening progress output: %w", err)
+	}
+
+	opts.progressOut = out
+
+	return func() { _ = out.Close() }, nil
+}
+
+// overallProgress is the progress of a whole recursive copy under
+// --overall-progress, sized by a countTree pass before copying. Reports are
+// rate-limited like those of progressReader, across files.
+type overallProgress struct {
+	total       int64
+	copied      int64
+	lastTime    time.Time
+	lastPercent int64
+	done        bool
+}
+
+// newOverallProgress starts tracking a copy of total bytes.
+func newOverallProgress(total int64) *overallProgress {
+	return &overallProgress{
+		total:       total,
+		copied:      0,
+		lastTime:    time.Now(),
+		lastPercent: 0,
+		done:        false,
+	}
+}
+
+// track returns the progress function for the next file copied, which adds
+// the file's progress to the bytes copied by earlier files and passes the
+// overall figures to report.
+func (op *overallProgress) track(report func(copied, total int64)) func(copied, total int64) {
+	base := op.copied
+
+	return func(copied, _ int64) {
+		op.copied = base + copied
+
+		percent := percentOf(op.copied, op.total)
+
+		now := time.Now()
+		due := percent == percentScale || now.Sub(op.lastTime) >= progressInterval ||
+			percent-op.lastPercent >= progressStep
+
+		if op.done || !due {
+			return
+		}
+
+		report(op.copied, op.total)
+
+		op.lastTime = now
+		op.lastPercent = percent
+		op.done = percent == percentScale
+	}
+}
+
+// finish reports the copy as complete, unless that has been reported
+// already. Files skipped by the copy count as done.
+func (op *overallProgress) finish(report func(copied, total int64)) {
+	if op.done {
+		return
+	}
+
+	op.copied = op.total
+	report(op.copied, op.total)
+	op.done = true
+}
+
+// progressReader counts bytes read from a source and passes the count to
+// report. Calls are rate-limited: report runs at least once every
+// progressInterval or progressStep percent of total while data flows, and
+// exactly once more when the whole source has been read, with copied equal
+// to total.
+type progressReader struct {
+	reader      io.Reader
+	report      func(copied, total int64)
+	total       int64
+	read        int64
+	lastTime    time.Time
+	lastPercent int64
+	done        bool
+}
+
+// newProgressReader wraps reader, reporting progress towards total bytes.
+func newProgressReader(
+	reader io.Reader,
+	total int64,
+	report func(copied, total int64),
+) *progressReader {
+	return &progressReader{
+		reader:      reader,
+		report:      report,
+		total:       total,
+		read:        0,
+		lastTime:    time.Now(),
+		lastPercent: 0,
+		done:        false,
+	}
+}
+
+// Read implements io.Reader.
+func (pr *progressReader) Read(p []byte) (int, error) {
+	count, err := pr.reader.Read(p)
+	pr.read += int64(count)
+
+	if pr.done {
+		return count, err //nolint:wrapcheck
+	}
+
+	percent := percentOf(pr.read, pr.total)
+
+	finished := pr.read >= pr.total || errors.Is(err, io.EOF)
+
+	now := time.Now()
+	if finished || now.Sub(pr.lastTime) >= progressInterval ||
+		percent-pr.lastPercent >= progressStep {
+		pr.report(pr.read, pr.total)
+
+		pr.lastTime = now
+		pr.lastPercent = percent
+		pr.done = finished
+	}
+
+	return count, err //nolint:wrapcheck
+}
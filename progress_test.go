@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// TestCopyFile_ProgressPlain tests that --progress=plain reports well-formed
+// progress lines ending at 100%.
+func TestCopyFile_ProgressPlain(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+	content := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	// Setup: Create a source spanning several read buffers
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with plain progress output
+	var out bytes.Buffer
+
+	opts := new(options)
+	opts.progress = progressPlain
+	opts.stderr = &out
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Every line is well-formed and the last one reports completion
+	pattern := regexp.MustCompile(`^progress: (\d{1,3})% \((\d+)/(\d+) bytes\)$`)
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) == 0 || len(lines[0]) == 0 {
+		t.Fatal("expected at least one progress line")
+	}
+
+	if len(lines) > 11 {
+		t.Errorf("expected at most 11 rate-limited lines, got %d", len(lines))
+	}
+
+	for _, line := range lines {
+		if !pattern.Match(line) {
+			t.Errorf("malformed progress line: %q", line)
+		}
+	}
+
+	want := "progress: 100% (1024000/1024000 bytes)"
+	if last := string(lines[len(lines)-1]); last != want {
+		t.Errorf("last progress line = %q, want %q", last, want)
+	}
+}
+
+// TestCopyFile_ProgressCallback tests that the progress hook sees strictly
+// increasing byte counts ending at the source size.
+func TestCopyFile_ProgressCallback(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+	content := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	// Setup: Create a source spanning several read buffers
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with a progress callback
+	var calls [][2]int64
+
+	opts := new(options)
+	opts.onProgress = func(copied, total int64) {
+		calls = append(calls, [2]int64{copied, total})
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Counts only grow, the total is fixed and the last call is complete
+	if len(calls) == 0 {
+		t.Fatal("expected the callback to be invoked")
+	}
+
+	size := int64(len(content))
+	for idx, call := range calls {
+		if call[1] != size {
+			t.Errorf("call %d: total = %d, want %d", idx, call[1], size)
+		}
+
+		if idx > 0 && call[0] <= calls[idx-1][0] {
+			t.Errorf("call %d: copied %d doesn't exceed previous %d", idx, call[0], calls[idx-1][0])
+		}
+	}
+
+	if last := calls[len(calls)-1]; last[0] != size {
+		t.Errorf("last call copied = %d, want %d", last[0], size)
+	}
+}
+
+// TestCopyFile_ProgressTo tests that --progress-to writes progress lines to
+// the named file and none to stderr.
+func TestCopyFile_ProgressTo(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+	progressFile := filepath.Join(tmpDir, "progress.log")
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, bytes.Repeat([]byte("x"), 4096), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with progress sent to a file
+	args := []string{"--progress=plain", "--progress-to=" + progressFile, sourceFile, destFile}
+
+	opts, err := parseArgs(args)
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	var stderr bytes.Buffer
+
+	opts.stderr = &stderr
+
+	closeProgress, err := opts.openProgressOutput()
+	if err != nil {
+		t.Fatalf("openProgressOutput() failed: %v", err)
+	}
+
+	_, err = copyFile(t.Context(), opts, sourceFile, destFile)
+
+	closeProgress()
+
+	if err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The progress lines landed in the file, not on stderr
+	got, err := os.ReadFile(progressFile)
+	if err != nil {
+		t.Fatalf("failed to read progress file: %v", err)
+	}
+
+	if !bytes.Contains(got, []byte("progress: 100% (4096/4096 bytes)")) {
+		t.Errorf("progress file = %q, want a completion line", got)
+	}
+
+	if stderr.Len() != 0 {
+		t.Errorf("expected nothing on stderr, got %q", stderr.String())
+	}
+}
+
+// TestParseArgs_ProgressToWithoutProgress tests that --progress-to is
+// rejected without --progress.
+func TestParseArgs_ProgressToWithoutProgress(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parseArgs([]string{"--progress-to=3", "a", "b"}); err == nil {
+		t.Error("expected error for --progress-to without --progress, got nil")
+	}
+}
+
+// TestRunRecursive_OverallProgress tests that --overall-progress reports
+// progress against the size of the whole tree, ending at 100%.
+func TestRunRecursive_OverallProgress(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	destDir := filepath.Join(tmpDir, "dest")
+	sizes := map[string]int{"a.bin": 300 * 1024, "b.bin": 10, "sub/c.bin": 200 * 1024}
+
+	// Setup: Create a tree of files of different sizes
+	writeSizedFiles(t, sourceDir, sizes)
+
+	var size int64
+	for _, length := range sizes {
+		size += int64(length)
+	}
+
+	// Test: Copy the tree reporting overall progress
+	args := []string{"-r", "-q", "--progress=plain", "--overall-progress", sourceDir, destDir}
+
+	opts, err := parseArgs(args)
+	if err != nil {
+		t.Fatalf("failed to parse args: %v", err)
+	}
+
+	var calls [][2]int64
+
+	opts.onProgress = func(copied, total int64) {
+		calls = append(calls, [2]int64{copied, total})
+	}
+
+	if err := opts.execute("cp"); err != nil {
+		t.Fatalf("failed to copy tree: %v", err)
+	}
+
+	// Verify: Every call is against the tree's size, and the last is complete
+	if len(calls) == 0 {
+		t.Fatal("expected the callback to be invoked")
+	}
+
+	for idx, call := range calls {
+		if call[1] != size {
+			t.Errorf("call %d: total = %d, want %d", idx, call[1], size)
+		}
+
+		if idx > 0 && call[0] < calls[idx-1][0] {
+			t.Errorf("call %d: copied %d is less than previous %d", idx, call[0], calls[idx-1][0])
+		}
+	}
+
+	if last := calls[len(calls)-1]; last[0] != size {
+		t.Errorf("last call copied = %d, want %d (100%%)", last[0], size)
+	}
+}
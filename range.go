@@ -0,0 +1,30 @@
+package main
+
+import "errors"
+
+// errRangeExceedsFile is returned when --skip and --count reach past the
+// end of the source.
+var errRangeExceedsFile = errors.New("range exceeds file size")
+
+// hasRange reports whether --skip or --count select part of the source.
+func (opts *options) hasRange() bool {
+	return opts.skipBytes > 0 || opts.countBytes > 0
+}
+
+// rangeLength returns how many bytes of a source of the given size are
+// copied, checking that the selected range lies within it.
+func (opts *options) rangeLength(size int64) (int64, error) {
+	if opts.skipBytes > size {
+		return 0, errRangeExceedsFile
+	}
+
+	if opts.countBytes == 0 {
+		return size - opts.skipBytes, nil
+	}
+
+	if opts.countBytes > size-opts.skipBytes {
+		return 0, errRangeExceedsFile
+	}
+
+	return opts.countBytes, nil
+}
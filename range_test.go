@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFile_ByteRange tests that --skip and --count copy a mid-file slice.
+func TestCopyFile_ByteRange(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "slice.bin")
+	content := make([]byte, 8<<10)
+
+	for idx := range content {
+		content[idx] = byte(idx % 251)
+	}
+
+	// Setup: Create an 8k source
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy 4k starting at 1k
+	opts, err := parseArgs([]string{"--skip=1k", "--count=4k", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The destination holds exactly the slice
+	got, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if !bytes.Equal(got, content[1<<10:5<<10]) {
+		t.Errorf("slice mismatch: got %d bytes", len(got))
+	}
+}
+
+// TestCopyFile_ByteRangeExceedsFile tests that a range past the end of the
+// source is rejected without creating the destination.
+func TestCopyFile_ByteRangeExceedsFile(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "slice.bin")
+
+	// Setup: Create a 2k source
+	if err := os.WriteFile(sourceFile, make([]byte, 2<<10), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Request 2k starting at 1k
+	opts := new(options)
+	opts.skipBytes = 1 << 10
+	opts.countBytes = 2 << 10
+
+	_, err := copyFile(t.Context(), opts, sourceFile, destFile)
+
+	// Verify: The range is rejected
+	if !errors.Is(err, errRangeExceedsFile) {
+		t.Fatalf("expected %v, got: %v", errRangeExceedsFile, err)
+	}
+
+	if _, err := os.Stat(destFile); !os.IsNotExist(err) {
+		t.Errorf("expected no destination file, got err: %v", err)
+	}
+}
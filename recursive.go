@@ -0,0 +1,731 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// dirMode is the permission used for directories created by a recursive copy.
+const dirMode = 0o755
+
+// runRecursive implements -r, copying a directory tree or a single entry.
+// It is also used for -P without -r, so a symlink source is copied as a link.
+func runRecursive(ctx context.Context, opts *options, source, dest string) error {
+	stat := os.Lstat
+	if followsCommandLine(opts) {
+		stat = os.Stat
+	}
+
+	info, err := stat(source)
+	if err != nil {
+		return fmt.Errorf("getting source file info: %w", err)
+	}
+
+	if info.IsDir() && !opts.recursive {
+		return fmt.Errorf("omitting directory '%s' (use -r)", source) //nolint:err113
+	}
+
+	if !info.IsDir() {
+		return copySingleEntry(ctx, opts, source, dest, info)
+	}
+
+	root, dest, err := setUpTree(opts, source, dest)
+	if err != nil {
+		return err
+	}
+
+	return copyDirectory(ctx, opts, source, root, dest)
+}
+
+// followsCommandLine reports whether a symlink named on the command line is
+// followed, as it is under -H and -L.
+func followsCommandLine(opts *options) bool {
+	return opts.dereference == derefCommandLine || opts.dereference == derefAlways
+}
+
+// copySingleEntry copies a source that isn't a directory, into dest if dest
+// is an existing directory.
+func copySingleEntry(
+	ctx context.Context,
+	opts *options,
+	source, dest string,
+	info fs.FileInfo,
+) error {
+	if destInfo, err := os.Stat(dest); err == nil && destInfo.IsDir() {
+		dest = filepath.Join(dest, filepath.Base(source))
+	}
+
+	if info.Mode().IsRegular() {
+		if skipped, err := opts.skip(source, dest, info); err != nil || skipped {
+			return err
+		}
+	}
+
+	if err := copyEntry(ctx, opts, source, dest, info); err != nil {
+		return err
+	}
+
+	opts.successf("File copied from %s to %s successfully.\n", source, dest)
+
+	return nil
+}
+
+// setUpTree resolves where the directory source is copied from and to,
+// returning the root to walk and the destination of the copy, and refuses
+// copies that would never end or touch the filesystem root.
+func setUpTree(opts *options, source, dest string) (string, string, error) {
+	if destInfo, err := os.Stat(dest); err == nil && destInfo.IsDir() && !copiesContents(source) {
+		dest = filepath.Join(dest, filepath.Base(source))
+	}
+
+	root := source
+	if followsCommandLine(opts) {
+		resolved, err := filepath.EvalSymlinks(source)
+		if err != nil {
+			return "", "", fmt.Errorf("resolving source symlink: %w", err)
+		}
+
+		root = resolved
+	}
+
+	if err := checkNotRoot(opts, source, dest); err != nil {
+		return "", "", err
+	}
+
+	if err := checkNotInside(root, dest); err != nil {
+		return "", "", err
+	}
+
+	return root, dest, nil
+}
+
+// copyDirectory copies the tree rooted at root, which the directory source
+// resolved to, to dest, reporting its progress under --overall-progress.
+func copyDirectory(ctx context.Context, opts *options, source, root, dest string) error {
+	if opts.overallProgress {
+		count, err := countTree(opts, root)
+		if err != nil {
+			return err
+		}
+
+		opts.overall = newOverallProgress(count.bytes)
+	}
+
+	if err := copyTree(ctx, opts, root, dest); err != nil {
+		return err
+	}
+
+	if opts.overall != nil {
+		opts.overall.finish(opts.progressReporter())
+	}
+
+	opts.successf("Directory copied from %s to %s successfully.\n", source, dest)
+
+	return nil
+}
+
+// copiesContents reports whether the directory source is named as "dir/.",
+// which copies its contents rather than the directory itself, so they land
+// directly in an existing destination directory.
+func copiesContents(source string) bool {
+	return filepath.Base(source) == "."
+}
+
+// checkNotRoot refuses a recursive copy from or to the filesystem root
+// unless --no-preserve-root was given.
+func checkNotRoot(opts *options, source, dest string) error {
+	if opts.noPreserveRoot {
+		return nil
+	}
+
+	isRoot := opts.isRoot
+	if isRoot == nil {
+		isRoot = isFilesystemRoot
+	}
+
+	for _, path := range []string{source, dest} {
+		if isRoot(path) {
+			return fmt.Errorf( //nolint:err113
+				"refusing to copy '%s' recursively (use --no-preserve-root)",
+				path,
+			)
+		}
+	}
+
+	return nil
+}
+
+// isFilesystemRoot reports whether path resolves to the root of a filesystem
+// tree, such as "/" or "C:\".
+func isFilesystemRoot(path string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return false
+	}
+
+	return filepath.Dir(resolved) == resolved
+}
+
+// checkNotInside refuses to copy the directory source to dest when dest lies
+// within it, which would copy the destination into itself without end.
+func checkNotInside(source, dest string) error {
+	sourceAbs, err := filepath.Abs(source)
+	if err != nil {
+		return fmt.Errorf("getting absolute path of source: %w", err)
+	}
+
+	destAbs, err := filepath.Abs(dest)
+	if err != nil {
+		return fmt.Errorf("getting absolute path of destination: %w", err)
+	}
+
+	rel, err := filepath.Rel(sourceAbs, destAbs)
+	if err != nil {
+		return nil //nolint:nilerr
+	}
+
+	if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("cannot copy '%s' into itself", source) //nolint:err113
+	}
+
+	return nil
+}
+
+// copyTree walks the directory tree rooted at source and recreates it at dest.
+// Directory attributes are applied after the walk so that copying their
+// contents doesn't disturb the preserved timestamps.
+//
+// With --ignore-errors, entries that fail to copy are reported on stderr and
+// the walk continues; the failures are summarized in the returned error.
+func copyTree(ctx context.Context, opts *options, source, dest string) error {
+	tree := &treeCopy{
+		opts:      opts,
+		dirs:      nil,
+		links:     make(map[fileKey]string),
+		ancestors: nil,
+		followed:  0,
+		failures:  0,
+		device:    0,
+		hasDevice: false,
+	}
+
+	if opts.noFollowMounts {
+		if info, err := os.Stat(source); err == nil {
+			tree.device, tree.hasDevice = opts.fileDevice(info)
+		}
+	}
+
+	// The source is one level above depth 0, that of its direct children.
+	if err := tree.walk(ctx, source, dest, -1); err != nil {
+		return fmt.Errorf("copying directory: %w", err)
+	}
+
+	if opts.pruneEmptyDirs {
+		tree.pruneEmptyDirs()
+	}
+
+	if err := tree.finishDirs(); err != nil {
+		return err
+	}
+
+	if tree.failures > 0 {
+		return fmt.Errorf("%d files failed", tree.failures) //nolint:err113
+	}
+
+	return nil
+}
+
+// treeCopy holds the state of a single recursive copy.
+type treeCopy struct {
+	opts *options
+	// dirs lists the directories created, in walk order.
+	dirs []treeDir
+	// links maps already copied hard-linked sources to their destination.
+	links map[fileKey]string
+	// ancestors lists the directories enclosing the entry being walked,
+	// outermost first and including those reached through followed
+	// symlinks, so that following a symlink back to one of them is reported
+	// as a cycle.
+	ancestors []fileKey
+	// followed counts the symlinks being followed into the entry being
+	// walked, whose failures are logged where the outermost one is walked.
+	followed int
+	failures int
+	// device is the device of the source root, checked against every
+	// directory under --follow-mounts=false.
+	device    uint64
+	hasDevice bool
+}
+
+// treeDir records a directory visited during a walk.
+type treeDir struct {
+	source string
+	dest   string
+	info   fs.FileInfo
+	// created is set when the destination directory didn't exist before.
+	created bool
+	pruned  bool
+}
+
+// treeWalk describes a walk of the tree rooted at source, copied to dest.
+type treeWalk struct {
+	source string
+	dest   string
+	// depth is how many levels below the root of the copy source lies, as
+	// it does when reached through a followed symlink.
+	depth int
+	// base is the number of tree.ancestors enclosing source.
+	base int
+}
+
+// walk copies the tree rooted at source to dest. The source lies depth
+// levels below the root of the copy, as it does when reached through a
+// followed symlink.
+func (tree *treeCopy) walk(ctx context.Context, source, dest string, depth int) error {
+	walk := treeWalk{source: source, dest: dest, depth: depth, base: len(tree.ancestors)}
+
+	defer func() { tree.ancestors = tree.ancestors[:walk.base] }()
+
+	visit := func(path string, entry fs.DirEntry, err error) error {
+		return tree.visit(ctx, walk, path, entry, err)
+	}
+
+	return filepath.WalkDir(source, visit) //nolint:wrapcheck
+}
+
+// visit copies each entry of the tree rooted at walk.source as it is walked.
+func (tree *treeCopy) visit(
+	ctx context.Context,
+	walk treeWalk,
+	path string,
+	entry fs.DirEntry,
+	err error,
+) error {
+	if err != nil {
+		return tree.walkFailed(path, entry, err)
+	}
+
+	rel, err := filepath.Rel(walk.source, path)
+	if err != nil {
+		return fmt.Errorf("resolving relative path: %w", err)
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return fmt.Errorf("getting file info: %w", err)
+	}
+
+	depth := walk.depth
+	if rel != "." {
+		depth += strings.Count(rel, string(filepath.Separator)) + 1
+	}
+
+	// Directories walked before path that don't enclose it are left.
+	tree.ancestors = tree.ancestors[:walk.base+depth-walk.depth]
+
+	if tree.leftOut(walk, path, entry, depth) {
+		if entry.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	}
+
+	return tree.visitEntry(ctx, path, filepath.Join(walk.dest, rel), info, depth)
+}
+
+// walkFailed handles the error the walk met at path. A directory that
+// can't be read is a failure of its own, left out of the walk.
+func (tree *treeCopy) walkFailed(path string, entry fs.DirEntry, err error) error {
+	if entry == nil || !entry.IsDir() {
+		return err
+	}
+
+	if err := tree.fail(path, fmt.Errorf("reading directory: %w", err)); err != nil {
+		return err
+	}
+
+	return filepath.SkipDir
+}
+
+// leftOut reports whether the entry at path is left out of the copy by
+// --max-depth or --exclude.
+func (tree *treeCopy) leftOut(walk treeWalk, path string, entry fs.DirEntry, depth int) bool {
+	if tree.opts.maxDepth != nil && depth > *tree.opts.maxDepth {
+		return true
+	}
+
+	return path != walk.source && tree.opts.excluded(entry.Name())
+}
+
+// visitEntry copies the walked entry at path, depth levels below the root of
+// the copy, to target.
+func (tree *treeCopy) visitEntry(
+	ctx context.Context,
+	path, target string,
+	info fs.FileInfo,
+	depth int,
+) error {
+	if info.Mode()&fs.ModeSymlink != 0 && tree.opts.dereference == derefAlways {
+		linked, err := os.Stat(path)
+		if err != nil {
+			return tree.fail(path, fmt.Errorf("following symlink: %w", err))
+		}
+
+		info = linked
+
+		if info.IsDir() {
+			return tree.fail(path, tree.follow(ctx, path, target, depth))
+		}
+	}
+
+	if info.Mode().IsRegular() {
+		skipped, err := tree.opts.skip(path, target, info)
+		if err != nil {
+			return tree.fail(path, err)
+		}
+
+		if skipped {
+			return nil
+		}
+	}
+
+	if info.IsDir() {
+		return tree.visitDir(ctx, path, target, info)
+	}
+
+	return tree.fail(path, tree.copy(ctx, path, target, info))
+}
+
+// visitDir copies the walked directory at path to target, leaving it out of
+// the walk under --follow-mounts=false if it's a mount point.
+func (tree *treeCopy) visitDir(ctx context.Context, path, target string, info fs.FileInfo) error {
+	if tree.crossesMount(info) {
+		err := fmt.Errorf("'%s' is a mount point (--follow-mounts=false)", path) //nolint:err113
+		if err := tree.fail(path, err); err != nil {
+			return err
+		}
+
+		return filepath.SkipDir
+	}
+
+	tree.enter(path, target, info)
+
+	return tree.fail(path, tree.copy(ctx, path, target, info))
+}
+
+// enter records the directory path, copied to target, as walked.
+func (tree *treeCopy) enter(path, target string, info fs.FileInfo) {
+	if key, ok := fileID(info); ok {
+		tree.ancestors = append(tree.ancestors, key)
+	}
+
+	_, statErr := os.Lstat(target)
+	tree.dirs = append(tree.dirs, treeDir{
+		source:  path,
+		dest:    target,
+		info:    info,
+		created: errors.Is(statErr, fs.ErrNotExist),
+		pruned:  false,
+	})
+}
+
+// fail handles the outcome of copying path. With --ignore-errors a failure
+// is reported and counted and the walk goes on; otherwise it stops the walk,
+// and is logged once the walk of the outermost followed symlink returns it.
+func (tree *treeCopy) fail(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if !tree.opts.ignoreErrors {
+		if tree.followed == 0 {
+			tree.opts.logError(path, err)
+		}
+
+		return err
+	}
+
+	tree.opts.logError(path, err)
+
+	tree.failures++
+
+	fmt.Fprintf(tree.opts.errorOutput(), "Error: %s: %v\n", path, err)
+
+	return nil
+}
+
+// follow implements -L for a symlink to a directory, copying the directory
+// it points to. A link to one of its own ancestors, including those reached
+// through other followed links, would be followed without end, so it is
+// reported as a cycle instead. Other directories may be linked to any number
+// of times.
+func (tree *treeCopy) follow(ctx context.Context, link, dest string, depth int) error {
+	target, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		return fmt.Errorf("following symlink: %w", err)
+	}
+
+	cycle, err := tree.isAncestor(link, target)
+	if err != nil {
+		return err
+	}
+
+	if cycle {
+		return fmt.Errorf("symlink cycle detected at '%s'", link) //nolint:err113
+	}
+
+	tree.followed++
+
+	defer func() { tree.followed-- }()
+
+	return tree.walk(ctx, target, dest, depth)
+}
+
+// isAncestor reports whether target, which link resolves to, encloses link
+// in the walk. Where file identities aren't available it falls back to
+// checking whether target is an ancestor of link's path.
+func (tree *treeCopy) isAncestor(link, target string) (bool, error) {
+	info, err := os.Stat(target)
+	if err != nil {
+		return false, fmt.Errorf("following symlink: %w", err)
+	}
+
+	if key, ok := fileID(info); ok {
+		return slices.Contains(tree.ancestors, key), nil
+	}
+
+	parent, err := filepath.EvalSymlinks(filepath.Dir(link))
+	if err != nil {
+		return false, fmt.Errorf("following symlink: %w", err)
+	}
+
+	rel, err := filepath.Rel(target, parent)
+	up := ".." + string(filepath.Separator)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, up), nil
+}
+
+// setDirMode implements --destination-mode and --normalize-permissions for
+// a directory created by the copy. It runs after the walk so a read-only mode can't get in the way of
+// copying the directory's contents.
+func setDirMode(opts *options, dir treeDir) error {
+	if !dir.created {
+		if opts.syncDirModes {
+			syncDirMode(opts, dir)
+		}
+
+		return nil
+	}
+
+	var mode fs.FileMode
+
+	switch {
+	case opts.inheritDirMode:
+		mode = dir.info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	case opts.destDirMode != nil:
+		mode = *opts.destDirMode
+	case opts.normalizePerms:
+		mode = normalizedMode(dir.info)
+	default:
+		return nil
+	}
+
+	if err := os.Chmod(dir.dest, mode); err != nil {
+		return fmt.Errorf("setting directory mode: %w", err)
+	}
+
+	return nil
+}
+
+// syncDirMode implements --sync-dir-modes for a directory that existed
+// before the copy, giving it the permissions of its source. The setgid and
+// sticky bits of the existing directory are kept, since clearing them would
+// open up a shared directory, and a directory that can't be changed, such as
+// one owned by someone else, is only warned about.
+func syncDirMode(opts *options, dir treeDir) {
+	info, err := os.Stat(dir.dest)
+	if err != nil {
+		opts.warnf("%s: %v", dir.dest, err)
+
+		return
+	}
+
+	mode := dir.info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky) |
+		info.Mode()&(fs.ModeSetgid|fs.ModeSticky)
+	if mode == info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky) {
+		return
+	}
+
+	if err := os.Chmod(dir.dest, mode); err != nil {
+		opts.warnf("can't sync mode of '%s': %v", dir.dest, err)
+	}
+}
+
+// crossesMount reports whether the directory described by info lies on
+// another device than the source root under --follow-mounts=false.
+func (tree *treeCopy) crossesMount(info fs.FileInfo) bool {
+	if !tree.hasDevice {
+		return false
+	}
+
+	device, ok := tree.opts.fileDevice(info)
+
+	return ok && device != tree.device
+}
+
+// fileDevice returns the device of the file described by info.
+func (opts *options) fileDevice(info fs.FileInfo) (uint64, bool) {
+	if opts.device != nil {
+		return opts.device(info)
+	}
+
+	return fileDevice(info)
+}
+
+// pruneEmptyDirs removes the directories created by this copy that ended up
+// empty, deepest first, leaving pre-existing directories and the root alone.
+func (tree *treeCopy) pruneEmptyDirs() {
+	for idx := len(tree.dirs) - 1; idx > 0; idx-- {
+		dir := &tree.dirs[idx]
+		if !dir.created {
+			continue
+		}
+
+		entries, err := os.ReadDir(dir.dest)
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+
+		if err := os.Remove(dir.dest); err == nil {
+			dir.pruned = true
+		}
+	}
+}
+
+// finishDirs applies the attributes and modes of the directories created,
+// innermost first, skipping those pruned.
+func (tree *treeCopy) finishDirs() error {
+	opts := tree.opts
+
+	for idx := len(tree.dirs) - 1; idx >= 0; idx-- {
+		dir := tree.dirs[idx]
+		if dir.pruned {
+			continue
+		}
+
+		if opts.preserve != 0 {
+			if err := applyAttributes(opts, dir.source, dir.dest, dir.info); err != nil {
+				return err
+			}
+
+			if err := overrideOwnership(opts, dir.dest); err != nil {
+				return err
+			}
+		}
+
+		if err := setDirMode(opts, dir); err != nil {
+			return err
+		}
+
+		applyFileFlags(opts, dir.source, dir.dest, dir.info)
+	}
+
+	return nil
+}
+
+// copy copies a single entry. With --preserve=links, a source sharing an
+// inode with an entry copied earlier becomes a hard link to that copy.
+func (tree *treeCopy) copy(ctx context.Context, source, dest string, info fs.FileInfo) error {
+	if tree.opts.preserve&preserveLinks == 0 || !info.Mode().IsRegular() {
+		return copyEntry(ctx, tree.opts, source, dest, info)
+	}
+
+	key, ok := hardLinkKey(info)
+	if !ok {
+		return copyEntry(ctx, tree.opts, source, dest, info)
+	}
+
+	if first, seen := tree.links[key]; seen {
+		if err := os.Link(first, dest); err != nil {
+			return fmt.Errorf("creating hard link: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := copyEntry(ctx, tree.opts, source, dest, info); err != nil {
+		return err
+	}
+
+	tree.links[key] = dest
+
+	return nil
+}
+
+// copyEntry copies a single walked entry, dispatching on its file type.
+func copyEntry(ctx context.Context, opts *options, source, dest string, info fs.FileInfo) error {
+	mode := info.Mode()
+
+	switch {
+	case mode.IsDir():
+		if err := os.Mkdir(dest, dirMode); err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("creating directory: %w", err)
+		}
+
+		return overrideOwnership(opts, dest)
+	case mode&fs.ModeSymlink != 0:
+		return copyLink(opts, source, dest, info)
+	case mode&fs.ModeDevice != 0:
+		return copyDevice(opts, source, dest, info)
+	case !mode.IsRegular():
+		opts.warnf("skipping special file '%s'", source)
+
+		return nil
+	default:
+		_, err := copyFile(ctx, opts, source, dest)
+
+		return err
+	}
+}
+
+// copyLink copies the walked symlink source to dest, along with its
+// ownership under --preserve=ownership or --chown.
+func copyLink(opts *options, source, dest string, info fs.FileInfo) error {
+	if err := copySymlink(source, dest); err != nil {
+		return err
+	}
+
+	if opts.preserve&preserveOwnership != 0 {
+		if err := applyAttributes(opts, source, dest, info); err != nil {
+			return err
+		}
+	}
+
+	return overrideOwnership(opts, dest)
+}
+
+// copySymlink recreates the symlink source at dest, pointing at the same target.
+func copySymlink(source, dest string) error {
+	target, err := os.Readlink(source)
+	if err != nil {
+		return fmt.Errorf("reading symlink: %w", err)
+	}
+
+	if err := os.Symlink(target, dest); err != nil {
+		return fmt.Errorf("creating symlink: %w", err)
+	}
+
+	return nil
+}
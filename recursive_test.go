@@ -0,0 +1,904 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestCopyTree_Success tests copying a nested directory tree.
+func TestCopyTree_Success(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+	files := map[string]string{
+		"top.txt":             "top",
+		"sub/nested.txt":      "nested",
+		"sub/deeper/deep.txt": "deep",
+	}
+
+	// Setup: Create source tree
+	for name, content := range files {
+		path := filepath.Join(sourceDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	// Test: Copy the tree recursively
+	opts := new(options)
+	opts.recursive = true
+
+	if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("copyTree() failed: %v", err)
+	}
+
+	// Verify: Every file exists with the same content
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(destDir, name))
+		if err != nil {
+			t.Errorf("failed to read %s: %v", name, err)
+
+			continue
+		}
+
+		if string(got) != want {
+			t.Errorf("content mismatch for %s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestRunRecursive_CommandLineSymlinks tests that -H follows only the
+// symlink named on the command line.
+func TestRunRecursive_CommandLineSymlinks(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	realDir := filepath.Join(tmpDir, "real")
+	linkDir := filepath.Join(tmpDir, "link")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create a directory with an in-tree symlink and a link to it
+	if err := os.Mkdir(realDir, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(realDir, "file.txt"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	if err := os.Symlink("file.txt", filepath.Join(realDir, "alias.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := os.Symlink(realDir, linkDir); err != nil {
+		t.Fatalf("failed to create directory symlink: %v", err)
+	}
+
+	// Test: Copy the command-line symlink with -H
+	opts, err := parseArgs([]string{"-r", "-H", linkDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := runRecursive(t.Context(), opts, linkDir, destDir); err != nil {
+		t.Fatalf("runRecursive() failed: %v", err)
+	}
+
+	// Verify: The command-line symlink was followed
+	info, err := os.Lstat(destDir)
+	if err != nil {
+		t.Fatalf("failed to stat destination: %v", err)
+	}
+
+	if !info.IsDir() {
+		t.Errorf("expected destination to be a directory, got mode %v", info.Mode())
+	}
+
+	// Verify: The in-tree symlink was preserved
+	checkSymlink(t, filepath.Join(destDir, "alias.txt"), "file.txt")
+}
+
+// checkSymlink checks that path is a symlink pointing at target.
+func checkSymlink(t *testing.T, path, target string) {
+	t.Helper()
+
+	got, err := os.Readlink(path)
+	if err != nil {
+		t.Fatalf("expected symlink to be preserved: %v", err)
+	}
+
+	if got != target {
+		t.Errorf("symlink target mismatch: got %q, want %q", got, target)
+	}
+}
+
+// checkRegularFile checks that path is a regular file holding want.
+func checkRegularFile(t *testing.T, path, want string) {
+	t.Helper()
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", path, err)
+	}
+
+	if !info.Mode().IsRegular() {
+		t.Errorf("expected %s to be a regular file, got mode %v", path, info.Mode())
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+
+	if string(got) != want {
+		t.Errorf("content mismatch for %s: got %q, want %q", path, got, want)
+	}
+}
+
+// TestRunRecursive_Dereference tests that -L copies the contents of every
+// symlink, including symlinked directories inside the tree.
+func TestRunRecursive_Dereference(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	sharedDir := filepath.Join(tmpDir, "shared")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create a tree with symlinks to a file and to an outside directory
+	writeFiles(t, tmpDir, map[string]string{"src/file.txt": "data", "shared/lib.txt": "lib"})
+
+	if err := os.Symlink("file.txt", filepath.Join(sourceDir, "alias.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := os.Symlink(sharedDir, filepath.Join(sourceDir, "shared")); err != nil {
+		t.Fatalf("failed to create directory symlink: %v", err)
+	}
+
+	// Test: Copy the tree with -L
+	opts, err := parseArgs([]string{"-r", "-L", "-q", sourceDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := runRecursive(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("runRecursive() failed: %v", err)
+	}
+
+	// Verify: Both symlinks became regular entries with the target's content
+	checkRegularFile(t, filepath.Join(destDir, "alias.txt"), "data")
+	checkRegularFile(t, filepath.Join(destDir, "shared", "lib.txt"), "lib")
+
+	info, err := os.Lstat(filepath.Join(destDir, "shared"))
+	if err != nil || !info.IsDir() {
+		t.Errorf("expected shared to be a directory, got: %v, %v", info, err)
+	}
+}
+
+// TestRunRecursive_DirContents tests that "dir/." copies the contents of dir
+// into an existing destination instead of creating dest/dir.
+func TestRunRecursive_DirContents(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create a source tree and an existing destination directory
+	for _, dir := range []string{filepath.Join(sourceDir, "sub"), destDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+	}
+
+	for name, content := range map[string]string{"a.txt": "a", filepath.Join("sub", "b.txt"): "b"} {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	// Test: Copy the contents of the source directory
+	contents := sourceDir + string(filepath.Separator) + "."
+
+	opts, err := parseArgs([]string{"-r", "-q", contents, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := runRecursive(t.Context(), opts, opts.paths[0], destDir); err != nil {
+		t.Fatalf("runRecursive() failed: %v", err)
+	}
+
+	// Verify: The children landed directly in the destination
+	for _, name := range []string{"a.txt", filepath.Join("sub", "b.txt")} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err != nil {
+			t.Errorf("expected %s in destination: %v", name, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "src")); !os.IsNotExist(err) {
+		t.Errorf("expected no src directory in destination, got: %v", err)
+	}
+}
+
+// TestCopyTree_MaxDepth tests that --max-depth counts the source's direct
+// children as depth 0 and leaves out everything below the given depth.
+func TestCopyTree_MaxDepth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		depth string
+		want  map[string]bool
+	}{
+		{
+			depth: "0",
+			want: map[string]bool{
+				"l1.txt":    true,
+				"d1":        true,
+				"d1/l2.txt": false,
+				"d1/d2":     false,
+			},
+		},
+		{
+			depth: "1",
+			want: map[string]bool{
+				"d1/l2.txt":    true,
+				"d1/d2":        true,
+				"d1/d2/l3.txt": false,
+				"d1/d2/d3":     false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.depth, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceDir := filepath.Join(tmpDir, "src")
+			destDir := filepath.Join(tmpDir, "dst")
+
+			// Setup: Create a four-level tree with a file on every level
+			writeSizedFiles(t, sourceDir, map[string]int{
+				"l1.txt":          2,
+				"d1/l2.txt":       2,
+				"d1/d2/l3.txt":    2,
+				"d1/d2/d3/l4.txt": 2,
+			})
+
+			// Test: Copy the tree down to the depth
+			args := []string{"-r", "-q", "--max-depth=" + tt.depth, sourceDir, destDir}
+
+			opts, err := parseArgs(args)
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+				t.Fatalf("copyTree() failed: %v", err)
+			}
+
+			// Verify: Only the levels down to the depth were copied
+			assertCopied(t, destDir, tt.want)
+		})
+	}
+}
+
+// TestRunRecursive_DereferenceCycle tests that -L reports a symlink pointing
+// back at one of its ancestors instead of following it forever.
+func TestRunRecursive_DereferenceCycle(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+	loop := filepath.Join(sourceDir, "sub", "loop")
+
+	// Setup: Create a tree with a symlink to its own root
+	if err := os.MkdirAll(filepath.Dir(loop), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	if err := os.Symlink(sourceDir, loop); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	// Test: Copy the tree with -L
+	opts, err := parseArgs([]string{"-r", "-L", "-q", sourceDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	err = runRecursive(t.Context(), opts, sourceDir, destDir)
+
+	// Verify: The cycle is reported
+	if err == nil || !strings.Contains(err.Error(), "symlink cycle detected at '"+loop+"'") {
+		t.Fatalf("expected symlink cycle error, got: %v", err)
+	}
+}
+
+// TestRunRecursive_DereferenceMutualCycle tests that -L reports a cycle
+// between two sibling directories linking to each other, which no link's
+// ancestors reveal.
+func TestRunRecursive_DereferenceMutualCycle(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+	loop := filepath.Join(sourceDir, "b", "to-a")
+
+	// Setup: Create directories a and b, each with a symlink to the other
+	for _, dir := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(sourceDir, dir), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+	}
+
+	toB := filepath.Join(sourceDir, "a", "to-b")
+	if err := os.Symlink(filepath.Join("..", "b"), toB); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := os.Symlink(filepath.Join("..", "a"), loop); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	// Test: Copy the tree with -L
+	opts, err := parseArgs([]string{"-r", "-L", "-q", sourceDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	err = runRecursive(t.Context(), opts, sourceDir, destDir)
+
+	// Verify: The cycle is reported at the link closing it
+	if err == nil || !strings.Contains(err.Error(), "symlink cycle detected at '"+loop+"'") {
+		t.Fatalf("expected symlink cycle error, got: %v", err)
+	}
+}
+
+// TestRunRecursive_DereferenceSharedDir tests that -L copies a directory
+// once more for a symlink to it that isn't one of its own ancestors, even
+// after the directory itself has been copied.
+func TestRunRecursive_DereferenceSharedDir(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create a directory and a later sibling symlink to it
+	writeFiles(t, sourceDir, map[string]string{"a_real/f": "shared"})
+
+	if err := os.Symlink("a_real", filepath.Join(sourceDir, "z_link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	// Test: Copy the tree with -L
+	opts, err := parseArgs([]string{"-r", "-L", "-q", sourceDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := runRecursive(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("runRecursive() failed: %v", err)
+	}
+
+	// Verify: Both the directory and the link are copied as directories
+	for _, dir := range []string{"a_real", "z_link"} {
+		content, err := os.ReadFile(filepath.Join(destDir, dir, "f"))
+		if err != nil || string(content) != "shared" {
+			t.Errorf("expected %s/f to be copied, got %q: %v", dir, content, err)
+		}
+	}
+}
+
+// TestCopyTree_IgnoreErrors tests that --ignore-errors continues past a
+// failing entry and reports the failure count.
+func TestCopyTree_IgnoreErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		ignoreErrors bool
+		wantCopied   map[string]bool
+	}{
+		{
+			name:         "fail fast",
+			ignoreErrors: false,
+			wantCopied:   map[string]bool{"a.txt": true, "c.txt": false},
+		},
+		{
+			name:         "ignore errors",
+			ignoreErrors: true,
+			wantCopied:   map[string]bool{"a.txt": true, "c.txt": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceDir := filepath.Join(tmpDir, "src")
+			destDir := filepath.Join(tmpDir, "dst")
+
+			// Setup: Create three files; b.txt can't be written because a
+			// directory already occupies its destination path, which fails
+			// even when running as root
+			if err := os.MkdirAll(sourceDir, 0o755); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+
+			for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+				path := filepath.Join(sourceDir, name)
+				if err := os.WriteFile(path, []byte(name), 0o600); err != nil {
+					t.Fatalf("failed to create file: %v", err)
+				}
+			}
+
+			if err := os.MkdirAll(filepath.Join(destDir, "b.txt"), 0o755); err != nil {
+				t.Fatalf("failed to create blocking directory: %v", err)
+			}
+
+			// Test: Copy the tree
+			opts := new(options)
+			opts.recursive = true
+			opts.ignoreErrors = tt.ignoreErrors
+
+			err := copyTree(t.Context(), opts, sourceDir, destDir)
+
+			// Verify: The copy fails overall
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+
+			if tt.ignoreErrors && err.Error() != "1 files failed" {
+				t.Errorf("unexpected error message: %v", err)
+			}
+
+			// Verify: Expected files were copied
+			assertCopied(t, destDir, tt.wantCopied)
+		})
+	}
+}
+
+// TestCopyTree_UnreadableDir tests that --ignore-errors reports a directory
+// that can't be read and still copies its siblings.
+func TestCopyTree_UnreadableDir(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("unreadable directories can't be created with chmod on Windows")
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("root can read directories regardless of permissions")
+	}
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	lockedDir := filepath.Join(sourceDir, "locked")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create an unreadable directory between two readable ones
+	writeSizedFiles(t, sourceDir, map[string]int{
+		"a/file.txt":        1,
+		"locked/secret.txt": 1,
+		"z/file.txt":        1,
+	})
+
+	if err := os.Chmod(lockedDir, 0); err != nil {
+		t.Fatalf("failed to chmod directory: %v", err)
+	}
+
+	restoreMode(t, lockedDir, 0o755)
+
+	// Test: Copy the tree, ignoring errors
+	opts := new(options)
+	opts.recursive = true
+	opts.ignoreErrors = true
+
+	err := copyTree(t.Context(), opts, sourceDir, destDir)
+
+	// Verify: The unreadable directory is counted as a failure
+	if err == nil || err.Error() != "1 files failed" {
+		t.Errorf("expected one failure, got: %v", err)
+	}
+
+	// Verify: The siblings were copied
+	assertCopied(t, destDir, map[string]bool{"a/file.txt": true, "z/file.txt": true})
+}
+
+// TestCopyTree_PruneEmptyDirs tests that directories left empty by --exclude
+// are removed, while pre-existing empty directories are kept.
+func TestCopyTree_PruneEmptyDirs(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+
+	// Setup: Create a tree where logs/ only holds excluded files
+	writeSizedFiles(t, sourceDir, map[string]int{
+		"keep.txt":        4,
+		"logs/a.log":      4,
+		"logs/b.log":      4,
+		"docs/readme.txt": 4,
+	})
+
+	if err := os.MkdirAll(filepath.Join(sourceDir, "empty"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(destDir, "empty"), 0o755); err != nil {
+		t.Fatalf("failed to create pre-existing directory: %v", err)
+	}
+
+	// Test: Copy excluding logs and pruning empty directories
+	args := []string{"-r", "--exclude=*.log", "--prune-empty-dirs", sourceDir, destDir}
+
+	opts, err := parseArgs(args)
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("copyTree() failed: %v", err)
+	}
+
+	// Verify: logs/ is gone, the rest is there
+	assertCopied(t, destDir, map[string]bool{
+		"logs":            false,
+		"keep.txt":        true,
+		"docs/readme.txt": true,
+		"empty":           true,
+	})
+}
+
+// TestCopyTree_NoFollowMounts tests that --follow-mounts=false stops at a
+// directory on another device and names it.
+func TestCopyTree_NoFollowMounts(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dst")
+	mountDir := filepath.Join(sourceDir, "mnt")
+
+	// Setup: Create a tree whose mnt directory stands in for a mount point
+	if err := os.MkdirAll(mountDir, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(mountDir, "file.txt"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	opts, err := parseArgs([]string{"-r", "--follow-mounts=false", sourceDir, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	opts.device = func(info os.FileInfo) (uint64, bool) {
+		if info.Name() == "mnt" {
+			return 2, true
+		}
+
+		return 1, true
+	}
+
+	// Test: Copy the tree
+	err = copyTree(t.Context(), opts, sourceDir, destDir)
+
+	// Verify: The copy stopped at the mount point
+	want := "'" + mountDir + "' is a mount point"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected error containing %q, got: %v", want, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "mnt")); !os.IsNotExist(err) {
+		t.Errorf("expected mount point not to be copied, got err: %v", err)
+	}
+}
+
+// TestCopyTree_DestinationMode tests that --destination-mode=inherit gives
+// created directories their source's mode and an octal mode sets them alike.
+func TestCopyTree_DestinationMode(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("directory modes aren't supported on Windows")
+	}
+
+	modes := map[string]os.FileMode{
+		".":               0o755,
+		"private":         0o700,
+		"shared":          0o775,
+		"shared/readonly": 0o555,
+	}
+
+	tests := []struct {
+		name string
+		flag string
+		want func(dir string) os.FileMode
+	}{
+		{
+			name: "inherit",
+			flag: "inherit",
+			want: func(dir string) os.FileMode { return modes[dir] },
+		},
+		{name: "uniform", flag: "0750", want: func(string) os.FileMode { return 0o750 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceDir := filepath.Join(tmpDir, "src")
+			destDir := filepath.Join(tmpDir, "dst")
+
+			// Setup: Create directories with mixed modes, deepest last
+			setupModeTree(t, sourceDir, modes)
+
+			// Test: Copy the tree
+			args := []string{"-r", "--destination-mode=" + tt.flag, sourceDir, destDir}
+
+			opts, err := parseArgs(args)
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+				t.Fatalf("copyTree() failed: %v", err)
+			}
+
+			restoreMode(t, filepath.Join(destDir, "shared/readonly"), 0o755)
+
+			// Verify: Every created directory has the expected mode
+			for dir := range modes {
+				checkPerm(t, filepath.Join(destDir, dir), tt.want(dir))
+			}
+
+			if _, err := os.Stat(filepath.Join(destDir, "shared/readonly/file.txt")); err != nil {
+				t.Errorf("expected file in read-only directory to be copied: %v", err)
+			}
+		})
+	}
+}
+
+// setupModeTree creates the directories of modes under root, with a file in
+// shared/readonly, and then gives each its mode, deepest first.
+func setupModeTree(t *testing.T, root string, modes map[string]os.FileMode) {
+	t.Helper()
+
+	for dir := range modes {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+	}
+
+	writeSizedFiles(t, root, map[string]int{"shared/readonly/file.txt": 1})
+
+	for _, dir := range []string{"shared/readonly", "shared", "private", "."} {
+		if err := os.Chmod(filepath.Join(root, dir), modes[dir]); err != nil {
+			t.Fatalf("failed to chmod directory: %v", err)
+		}
+	}
+
+	restoreMode(t, filepath.Join(root, "shared/readonly"), 0o755)
+}
+
+// checkPerm checks the permission bits of the file at path.
+func checkPerm(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", path, err)
+	}
+
+	if got := info.Mode().Perm(); got != want {
+		t.Errorf("mode of %s = %o, want %o", path, got, want)
+	}
+}
+
+// TestCopyTree_SyncDirModes tests that existing destination directories keep
+// their mode by default and take their source's under --sync-dir-modes,
+// without losing the sticky bit of a shared directory.
+func TestCopyTree_SyncDirModes(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("directory modes aren't supported on Windows")
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want os.FileMode
+	}{
+		{name: "default", args: nil, want: 0o777 | os.ModeSticky},
+		{name: "sync", args: []string{"--sync-dir-modes"}, want: 0o750 | os.ModeSticky},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceDir := filepath.Join(tmpDir, "src")
+			destDir := filepath.Join(tmpDir, "dst")
+
+			// Setup: A private source directory and an existing shared one
+			modes := map[string]os.FileMode{sourceDir: 0o750, destDir: 0o777 | os.ModeSticky}
+			for dir, mode := range modes {
+				if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+					t.Fatalf("failed to create directory: %v", err)
+				}
+
+				if err := os.Chmod(filepath.Join(dir, "sub"), mode); err != nil {
+					t.Fatalf("failed to chmod directory: %v", err)
+				}
+			}
+
+			// Test: Copy the tree over the existing one
+			opts, err := parseArgs(append(append([]string{"-r"}, tt.args...), sourceDir, destDir))
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if err := copyTree(t.Context(), opts, sourceDir, destDir); err != nil {
+				t.Fatalf("copyTree() failed: %v", err)
+			}
+
+			// Verify: The existing directory has the expected mode
+			info, err := os.Stat(filepath.Join(destDir, "sub"))
+			if err != nil {
+				t.Fatalf("failed to stat directory: %v", err)
+			}
+
+			if got := info.Mode() & (os.ModePerm | os.ModeSticky); got != tt.want {
+				t.Errorf("mode = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunRecursive_IntoItself tests that copying a directory into its own
+// child is refused.
+func TestRunRecursive_IntoItself(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "dir")
+	childDir := filepath.Join(sourceDir, "sub")
+
+	// Setup: Create a directory with a child directory
+	if err := os.MkdirAll(childDir, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	// Test: Copy the directory into its child
+	opts := new(options)
+	opts.recursive = true
+	opts.quiet = true
+
+	err := runRecursive(t.Context(), opts, sourceDir, childDir)
+
+	// Verify: The copy is refused and nothing was created
+	want := "cannot copy '" + sourceDir + "' into itself"
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got: %v", want, err)
+	}
+
+	entries, err := os.ReadDir(childDir)
+	if err != nil {
+		t.Fatalf("failed to read child directory: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("expected child directory to stay empty, got %d entries", len(entries))
+	}
+}
+
+// TestRunRecursive_PreserveRoot tests that a recursive copy of the root is
+// refused unless --no-preserve-root is given.
+func TestRunRecursive_PreserveRoot(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "fakeroot")
+	destDir := filepath.Join(tmpDir, "dest")
+
+	// Setup: Create a directory standing in for the root
+	if err := os.MkdirAll(filepath.Join(sourceDir, "etc"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	isRoot := func(path string) bool { return path == sourceDir }
+
+	// Test: Copy the fake root
+	opts := new(options)
+	opts.recursive = true
+	opts.quiet = true
+	opts.isRoot = isRoot
+
+	err := runRecursive(t.Context(), opts, sourceDir, destDir)
+
+	// Verify: The copy is refused
+	if err == nil || !strings.Contains(err.Error(), "--no-preserve-root") {
+		t.Fatalf("expected refusal mentioning --no-preserve-root, got: %v", err)
+	}
+
+	// Test: Copy again with the override
+	opts.noPreserveRoot = true
+	if err := runRecursive(t.Context(), opts, sourceDir, destDir); err != nil {
+		t.Fatalf("runRecursive() with --no-preserve-root failed: %v", err)
+	}
+
+	// Verify: The tree was copied
+	if _, err := os.Stat(filepath.Join(destDir, "etc")); err != nil {
+		t.Errorf("expected etc to be copied: %v", err)
+	}
+}
+
+// TestIsFilesystemRoot tests detection of the filesystem root.
+func TestIsFilesystemRoot(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == goosWindows {
+		t.Skip("test uses a slash-separated root")
+	}
+
+	if !isFilesystemRoot("/") {
+		t.Error("expected / to be the root")
+	}
+
+	if isFilesystemRoot(t.TempDir()) {
+		t.Error("expected a temporary directory not to be the root")
+	}
+}
+
+// BenchmarkCopyTree_SmallFiles benchmarks a recursive copy of 5000 small
+// files, whose copy buffers come from a shared pool.
+func BenchmarkCopyTree_SmallFiles(b *testing.B) {
+	tmpDir := b.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+
+	if err := os.Mkdir(sourceDir, 0o755); err != nil {
+		b.Fatalf("failed to create directory: %v", err)
+	}
+
+	for idx := range 5000 {
+		path := filepath.Join(sourceDir, fmt.Sprintf("file-%04d.txt", idx))
+		if err := os.WriteFile(path, []byte("small file content"), 0o600); err != nil {
+			b.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	opts, err := parseArgs([]string{"-r", "-q", sourceDir, tmpDir})
+	if err != nil {
+		b.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for idx := range b.N {
+		destDir := filepath.Join(tmpDir, fmt.Sprintf("dst-%d", idx))
+		if err := copyTree(b.Context(), opts, sourceDir, destDir); err != nil {
+			b.Fatalf("copyTree() failed: %v", err)
+		}
+	}
+}
+
+// restoreMode gives path mode back once the test is done, so that t.TempDir
+// can remove a directory the test made unwritable.
+func restoreMode(t *testing.T, path string, mode os.FileMode) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("failed to restore permissions: %v", err)
+		}
+	})
+}
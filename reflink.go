@@ -0,0 +1,85 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// --reflink modes.
+const (
+	// reflinkAuto clones the source when the filesystem supports it and
+	// falls back to copying the bytes otherwise.
+	reflinkAuto = "auto"
+	// reflinkAlways fails when the source can't be cloned.
+	reflinkAlways = "always"
+	// reflinkNever always copies the bytes.
+	reflinkNever = "never"
+)
+
+// copyMethod tells how the content of a file was copied.
+type copyMethod string
+
+const (
+	// methodCopied means the bytes were read and written.
+	methodCopied copyMethod = "copied"
+	// methodCloned means the destination shares the source's blocks.
+	methodCloned copyMethod = "cloned"
+)
+
+// cloneBlocker is an option that rules out cloning, because the copy has to
+// read or change the bytes it writes.
+type cloneBlocker struct {
+	// flag names the option, or is empty for the hooks of embedding programs.
+	flag string
+	set  func(opts *options) bool
+}
+
+// cloneBlockers returns the options that rule out cloning, including
+// --verify hashing the written bytes.
+func cloneBlockers() []cloneBlocker {
+	return []cloneBlocker{
+		{flag: "--compress", set: func(opts *options) bool { return opts.compress != "" }},
+		{flag: "--transform", set: func(opts *options) bool { return len(opts.transforms) > 0 }},
+		{flag: "--decompress", set: func(opts *options) bool { return opts.decompress }},
+		{flag: "--auto", set: func(opts *options) bool { return opts.auto }},
+		{flag: "--manifest", set: func(opts *options) bool { return opts.manifest != "" }},
+		{
+			flag: "--verify",
+			set:  func(opts *options) bool { return opts.verify && !opts.verifyStrict },
+		},
+		{flag: "--progress", set: func(opts *options) bool { return opts.progress != "" }},
+		{flag: "", set: func(opts *options) bool { return opts.onProgress != nil }},
+		{flag: "", set: func(opts *options) bool { return opts.onChunk != nil }},
+		{flag: "--skip", set: func(opts *options) bool { return opts.skipBytes > 0 }},
+		{flag: "--count", set: func(opts *options) bool { return opts.countBytes > 0 }},
+		{flag: "--append", set: func(opts *options) bool { return opts.appendDest }},
+		{flag: "--text", set: func(opts *options) bool { return opts.text }},
+	}
+}
+
+// canClone reports whether a copy can be made by cloning, which requires the
+// destination to be a byte-for-byte copy that nothing else needs to read.
+func (opts *options) canClone() bool {
+	blocked := slices.ContainsFunc(cloneBlockers(), func(blocker cloneBlocker) bool {
+		return blocker.set(opts)
+	})
+
+	return opts.reflink != reflinkNever && !blocked
+}
+
+// reflinkAlwaysConflict is the error message for --reflink=always combined
+// with --resume, which appends to dest, or with an option in cloneBlockers.
+func reflinkAlwaysConflict() string {
+	flags := []string{"--resume"}
+
+	for _, blocker := range cloneBlockers() {
+		if blocker.flag != "" {
+			flags = append(flags, blocker.flag)
+		}
+	}
+
+	last := len(flags) - 1
+	listed := strings.Join(flags[:last], ", ") + " or " + flags[last]
+
+	return "--reflink=always can't be combined with " + listed
+}
@@ -0,0 +1,16 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// errReflinkUnsupported is returned where files can't be cloned.
+var errReflinkUnsupported = errors.New("cloning not supported on this platform")
+
+// cloneFile reports that cloning isn't available here.
+func cloneFile(_, _ *os.File) error {
+	return errReflinkUnsupported
+}
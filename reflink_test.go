@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// reflinkOptions returns options copying under the --reflink mode.
+func reflinkOptions(mode string) *options {
+	opts := new(options)
+	opts.reflink = mode
+
+	return opts
+}
+
+// TestCopyFile_ReflinkModes tests that on a filesystem without cloning
+// support --reflink=always fails while --reflink=auto falls back to copying.
+func TestCopyFile_ReflinkModes(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, []byte("clone me"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with --reflink=always
+	_, err := copyFile(t.Context(), reflinkOptions(reflinkAlways), sourceFile, destFile)
+	if err == nil {
+		t.Skip("the temporary directory supports cloning")
+	}
+
+	// Verify: The error names the clone failure
+	if !strings.Contains(err.Error(), "cloning") {
+		t.Errorf("expected a cloning error, got: %v", err)
+	}
+
+	// Test: Copy with --reflink=auto
+	opts := reflinkOptions(reflinkAuto)
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() with --reflink=auto failed: %v", err)
+	}
+
+	// Verify: The bytes were copied
+	if content, _ := os.ReadFile(destFile); string(content) != "clone me" {
+		t.Errorf("content mismatch: got %q, want %q", content, "clone me")
+	}
+}
+
+// TestParseArgs_ReflinkAlwaysConflicts tests that --reflink=always rejects
+// options that need to read or change the copied bytes, naming each of them.
+func TestParseArgs_ReflinkAlwaysConflicts(t *testing.T) {
+	t.Parallel()
+
+	flags := []string{
+		"--compress=gzip", "--decompress", "--manifest=sums", "--progress=plain", "--resume",
+		"--text", "--append", "--skip=1", "--count=1", "--verify",
+	}
+
+	for _, flag := range flags {
+		_, err := parseArgs([]string{"--reflink=always", flag, "a", "b"})
+		if err == nil {
+			t.Errorf("expected error for --reflink=always with %s, got nil", flag)
+
+			continue
+		}
+
+		name, _, _ := strings.Cut(flag, "=")
+
+		msg := err.Error()
+		if !strings.HasPrefix(msg, "--reflink=always") || !strings.Contains(msg, name) {
+			t.Errorf("expected --reflink=always error naming %s, got: %v", name, err)
+		}
+	}
+
+	// --verify=strict reads dest back, so the clone can be verified
+	if _, err := parseArgs([]string{"--reflink=always", "--verify=strict", "a", "b"}); err != nil {
+		t.Errorf("expected --reflink=always to allow --verify=strict, got: %v", err)
+	}
+}
+
+// TestCopyFile_ReportsMethod tests that -v reports "cloned" where the
+// filesystem can clone the source and "copied" where it can't, or where
+// --reflink=never rules cloning out.
+func TestCopyFile_ReportsMethod(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create source file and probe whether it can be cloned
+	if err := os.WriteFile(sourceFile, []byte("clone me"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	want := "cloned"
+
+	probe := reflinkOptions(reflinkAlways)
+	if _, err := copyFile(t.Context(), probe, sourceFile, destFile); err != nil {
+		want = "copied"
+	}
+
+	for _, tt := range []struct {
+		reflink string
+		want    string
+	}{
+		{reflink: reflinkAuto, want: want},
+		{reflink: reflinkNever, want: "copied"},
+	} {
+		// Test: Copy verbosely with the reflink mode
+		var out strings.Builder
+
+		opts := reflinkOptions(tt.reflink)
+		opts.verbose, opts.stdout = true, &out
+
+		if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+			t.Fatalf("copyFile() with --reflink=%s failed: %v", tt.reflink, err)
+		}
+
+		// Verify: The method used is reported
+		line := sourceFile + " -> " + destFile + ": " + tt.want
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("--reflink=%s: expected %q in output, got %q", tt.reflink, line, out.String())
+		}
+	}
+}
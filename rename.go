@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// renameFields is the data a --rename template is executed with.
+type renameFields struct {
+	// Base is the source's file name, e.g. "report.txt".
+	Base string
+	// Ext is the extension of Base including the dot, e.g. ".txt".
+	Ext string
+	// Name is Base without Ext, e.g. "report".
+	Name string
+	// Index is the source's position in the batch, starting at 1.
+	Index int
+}
+
+// parseRenameTemplate compiles a --rename template, so mistakes are caught
+// before anything is copied.
+func parseRenameTemplate(value string) (*template.Template, error) {
+	tmpl, err := template.New("rename").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing rename template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// destName returns the name under which source is copied into a directory:
+// its base name, or the result of the --rename template.
+func (opts *options) destName(source string, index int) (string, error) {
+	base := filepath.Base(source)
+	if opts.rename == nil {
+		return base, nil
+	}
+
+	ext := filepath.Ext(base)
+	fields := renameFields{Base: base, Ext: ext, Name: strings.TrimSuffix(base, ext), Index: index}
+
+	var name strings.Builder
+	if err := opts.rename.Execute(&name, fields); err != nil {
+		return "", fmt.Errorf("renaming '%s': %w", source, err)
+	}
+
+	result := name.String()
+	if result == "" || result == "." || result == ".." || strings.ContainsAny(result, `/\`) {
+		return "", fmt.Errorf( //nolint:err113
+			"rename template produced invalid name '%s' for '%s'", result, source,
+		)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRunGlob_Rename tests that --rename names each file of a batch copy.
+func TestRunGlob_Rename(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "src")
+	destDir := filepath.Join(tmpDir, "dest")
+
+	// Setup: Create three sources and the target directory
+	writeFiles(t, sourceDir, map[string]string{
+		"a.txt": "a.txt",
+		"b.txt": "b.txt",
+		"c.txt": "c.txt",
+	})
+
+	if err := os.Mkdir(destDir, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	// Test: Copy the batch with a rename template
+	rename := "--rename={{.Name}}-{{.Index}}{{.Ext}}.bak"
+
+	opts, err := parseArgs([]string{"-q", "--files-from=-", rename, destDir})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if err := runGlob(t.Context(), opts, filepath.Join(sourceDir, "*.txt"), destDir); err != nil {
+		t.Fatalf("runGlob() failed: %v", err)
+	}
+
+	// Verify: Every file was copied under its templated name
+	checkDirContents(t, destDir, map[string]string{
+		"a-1.txt.bak": "a.txt",
+		"b-2.txt.bak": "b.txt",
+		"c-3.txt.bak": "c.txt",
+	})
+}
+
+// checkDirContents checks that dir holds exactly the files in want, keyed by
+// name, with their contents.
+func checkDirContents(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+
+	got := make(map[string]string, len(entries))
+
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", entry.Name(), err)
+		}
+
+		got[entry.Name()] = string(content)
+	}
+
+	if !maps.Equal(got, want) {
+		t.Errorf("contents of %s = %v, want %v", dir, got, want)
+	}
+}
+
+// TestParseArgs_InvalidRename tests that a rename template that doesn't
+// compile is rejected before copying.
+func TestParseArgs_InvalidRename(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseArgs([]string{"--rename={{.Base", "a", "b"})
+	if err == nil || !strings.Contains(err.Error(), "parsing rename template") {
+		t.Fatalf("expected template parse error, got: %v", err)
+	}
+}
+
+// TestParseArgs_RenameWithoutBatch tests that --rename is refused for a copy
+// of a single source, which has nothing to rename.
+func TestParseArgs_RenameWithoutBatch(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseArgs([]string{"--rename={{.Base}}.bak", "a", "b"})
+	if err == nil || !strings.Contains(err.Error(), "--rename requires --files-from") {
+		t.Fatalf("expected --rename to require a batch copy, got: %v", err)
+	}
+}
+
+// TestDestName_Invalid tests that a template producing a path is rejected.
+func TestDestName_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tmpl, err := parseRenameTemplate("sub/{{.Base}}")
+	if err != nil {
+		t.Fatalf("parseRenameTemplate() failed: %v", err)
+	}
+
+	opts := new(options)
+	opts.rename = tmpl
+
+	if _, err := opts.destName("file.txt", 1); err == nil {
+		t.Error("expected an error for a name containing a separator")
+	}
+}
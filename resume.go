@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// resumeSuffix is appended to the destination path to name the sidecar file.
+	resumeSuffix = ".cp-resume"
+	// resumeCheckpoint is how many bytes are written between sidecar updates.
+	resumeCheckpoint = 1 << 20
+	// resumeFileMode is the permission of the sidecar file.
+	resumeFileMode = 0o600
+)
+
+// resumeState is the sidecar record kept next to a destination while a
+// resumable copy is in progress.
+type resumeState struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"mtime"`
+	Offset  int64 `json:"offset"`
+}
+
+// loadResumeState reads a sidecar file, reporting false if it is missing or unreadable.
+func loadResumeState(path string) (resumeState, bool) {
+	var none, state resumeState
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return none, false
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return none, false
+	}
+
+	return state, true
+}
+
+// save writes the state to the sidecar file at path.
+func (s resumeState) save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encoding resume state: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, resumeFileMode); err != nil {
+		return fmt.Errorf("writing resume state: %w", err)
+	}
+
+	return nil
+}
+
+// resumeWriter counts bytes written to the destination and periodically
+// records the progress in the sidecar file.
+type resumeWriter struct {
+	writer  io.Writer
+	path    string
+	state   resumeState
+	pending int64
+}
+
+// Write implements io.Writer.
+func (rw *resumeWriter) Write(data []byte) (int, error) {
+	written, err := rw.writer.Write(data)
+	rw.state.Offset += int64(written)
+	rw.pending += int64(written)
+
+	if err != nil {
+		return written, err //nolint:wrapcheck
+	}
+
+	if rw.pending >= resumeCheckpoint {
+		rw.pending = 0
+
+		if err := rw.state.save(rw.path); err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// copyResumable copies sourceFile to dest, continuing from the offset recorded
+// in the sidecar file when the source is unchanged since it was written.
+// When tee is non-nil, it receives the full source contents, including the
+// part copied by an earlier run.
+func copyResumable(
+	ctx context.Context, sourceFile *os.File, info os.FileInfo, dest string, tee io.Writer,
+) error {
+	statePath := dest + resumeSuffix
+	state := resumeState{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Offset: 0}
+	state.Offset = resumeOffset(statePath, dest, state)
+
+	flag := os.O_WRONLY | os.O_CREATE
+	if state.Offset == 0 {
+		flag |= os.O_TRUNC
+	}
+
+	destFile, err := os.OpenFile(dest, flag, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("creating destination file: %w", err)
+	}
+
+	defer destFile.Close()
+
+	if state.Offset > 0 {
+		if err := skipCopied(sourceFile, destFile, tee, state.Offset); err != nil {
+			return err
+		}
+	}
+
+	if err := state.save(statePath); err != nil {
+		return err
+	}
+
+	writer := &resumeWriter{writer: destFile, path: statePath, state: state, pending: 0}
+
+	var target io.Writer = writer
+	if tee != nil {
+		target = io.MultiWriter(writer, tee)
+	}
+
+	if _, err := copyStream(ctx, target, sourceFile, nil); err != nil {
+		_ = writer.state.save(statePath)
+
+		return fmt.Errorf("copying file: %w", err)
+	}
+
+	if err := destFile.Close(); err != nil {
+		return fmt.Errorf("closing destination file: %w", err)
+	}
+
+	if err := os.Remove(statePath); err != nil {
+		return fmt.Errorf("removing resume state: %w", err)
+	}
+
+	return nil
+}
+
+// resumeOffset returns the offset recorded in the sidecar file at statePath
+// if it was written for the source state describes and dest still holds
+// the bytes up to it, or 0 to copy from the start.
+func resumeOffset(statePath, dest string, state resumeState) int64 {
+	saved, ok := loadResumeState(statePath)
+	if !ok || saved.Size != state.Size || saved.ModTime != state.ModTime ||
+		saved.Offset > saved.Size {
+		return 0
+	}
+
+	if destInfo, err := os.Stat(dest); err != nil || destInfo.Size() < saved.Offset {
+		return 0
+	}
+
+	return saved.Offset
+}
+
+// skipCopied positions sourceFile and destFile past the offset bytes an
+// earlier run copied, dropping anything dest holds beyond them, and feeds
+// those bytes to tee if it is non-nil.
+func skipCopied(sourceFile, destFile *os.File, tee io.Writer, offset int64) error {
+	if tee != nil {
+		prefix := io.NewSectionReader(sourceFile, 0, offset)
+		if _, err := io.Copy(tee, prefix); err != nil {
+			return fmt.Errorf("reading already copied data: %w", err)
+		}
+	}
+
+	if err := destFile.Truncate(offset); err != nil {
+		return fmt.Errorf("truncating destination file: %w", err)
+	}
+
+	if _, err := destFile.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking destination file: %w", err)
+	}
+
+	if _, err := sourceFile.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking source file: %w", err)
+	}
+
+	return nil
+}
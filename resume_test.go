@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFile_ResumeFromPartial tests that --resume continues a partial copy.
+func TestCopyFile_ResumeFromPartial(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+	content := bytes.Repeat([]byte("0123456789"), 300*1024)
+	half := int64(len(content) / 2)
+
+	// Setup: Create source, a partial destination and a matching sidecar
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(destFile, content[:half], 0o600); err != nil {
+		t.Fatalf("failed to create partial destination file: %v", err)
+	}
+
+	info, err := os.Stat(sourceFile)
+	if err != nil {
+		t.Fatalf("failed to stat source file: %v", err)
+	}
+
+	state := resumeState{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Offset: half}
+	if err := state.save(destFile + resumeSuffix); err != nil {
+		t.Fatalf("failed to write resume state: %v", err)
+	}
+
+	// Test: Resume the copy
+	opts := new(options)
+	opts.resume = true
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Content matches and the sidecar is gone
+	got, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if !bytes.Equal(got, content) {
+		t.Errorf("content mismatch after resume: got %d bytes, want %d", len(got), len(content))
+	}
+
+	if _, err := os.Stat(destFile + resumeSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected resume state to be removed, got err: %v", err)
+	}
+}
+
+// TestCopyFile_ResumeStaleState tests that a mismatched sidecar restarts the copy.
+func TestCopyFile_ResumeStaleState(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+	content := []byte("fresh source content")
+
+	// Setup: Create source and a destination with a sidecar for another source
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(destFile, []byte("stale partial"), 0o600); err != nil {
+		t.Fatalf("failed to create partial destination file: %v", err)
+	}
+
+	state := resumeState{Size: 999, ModTime: 1, Offset: 5}
+	if err := state.save(destFile + resumeSuffix); err != nil {
+		t.Fatalf("failed to write resume state: %v", err)
+	}
+
+	// Test: Resume the copy
+	opts := new(options)
+	opts.resume = true
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The copy started over
+	got, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if !bytes.Equal(got, content) {
+		t.Errorf("content mismatch: got %q, want %q", got, content)
+	}
+}
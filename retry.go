@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// defaultRetryDelay is the wait before the first --retry attempt.
+const defaultRetryDelay = 500 * time.Millisecond
+
+// retryable reports whether err looks like a transient I/O failure worth
+// another attempt, as opposed to a missing file, a permission problem or a
+// mistake in the arguments. A copy failing --verify is retried too, since a
+// flaky disk may get it right the next time.
+func retryable(err error) bool {
+	if errors.Is(err, errVerifyMismatch) {
+		return true
+	}
+
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+
+	for _, transient := range append(transientErrnos(), io.ErrUnexpectedEOF) {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withRetry runs attempt, repeating it up to --retry times while it fails with
+// a retryable error. The delay between attempts starts at --retry-delay and
+// doubles each time. Once the attempts run out, the error of the last one is
+// returned, saying so.
+func (opts *options) withRetry(ctx context.Context, source string, attempt func() error) error {
+	delay := opts.retryDelay
+	if delay == 0 {
+		delay = defaultRetryDelay
+	}
+
+	for tries := 1; ; tries++ {
+		err := attempt()
+		if err == nil || !retryable(err) {
+			return err
+		}
+
+		if tries > opts.retry {
+			return fmt.Errorf("giving up on '%s' after %d attempts: %w", source, tries, err)
+		}
+
+		opts.warnf(
+			"copying '%s' failed, retrying in %s (%d/%d): %v",
+			source, delay, tries, opts.retry, err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !plan9
+
+package main
+
+import "syscall"
+
+// transientErrnos lists the system errors --retry treats as transient.
+func transientErrnos() []error {
+	return []error{
+		syscall.EIO, syscall.EAGAIN, syscall.EINTR,
+		syscall.ETIMEDOUT, syscall.ECONNRESET, syscall.ECONNABORTED,
+	}
+}
@@ -0,0 +1,7 @@
+package main
+
+// transientErrnos lists the system errors --retry treats as transient; Plan 9
+// reports errors as strings, so none are recognized.
+func transientErrnos() []error {
+	return nil
+}
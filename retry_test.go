@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// failingReader fails every read with err.
+type failingReader struct {
+	err error
+}
+
+// Read implements io.Reader.
+func (reader failingReader) Read([]byte) (int, error) {
+	return 0, reader.err
+}
+
+// TestCopyFile_Retry tests that --retry repeats a copy whose first two
+// attempts fail with an I/O error until it succeeds.
+func TestCopyFile_Retry(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create source file and a reader failing the first two attempts
+	if err := os.WriteFile(sourceFile, []byte("flaky"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	opts, err := parseArgs([]string{"--retry=3", "--retry-delay=1ms", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	attempts := 0
+	opts.readSource = func(reader io.Reader) io.Reader {
+		attempts++
+		if attempts <= 2 {
+			return failingReader{err: syscall.EIO}
+		}
+
+		return reader
+	}
+
+	// Test: Copy the flaky source
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The third attempt copied the file
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+
+	if got, _ := os.ReadFile(destFile); string(got) != "flaky" {
+		t.Errorf("content = %q, want %q", got, "flaky")
+	}
+}
+
+// TestCopyFile_RetryGivesUp tests that --retry returns the last error once
+// the attempts run out, and doesn't retry errors that aren't transient.
+func TestCopyFile_RetryGivesUp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		err          error
+		wantAttempts int
+	}{
+		{name: "transient", err: syscall.EIO, wantAttempts: 3},
+		{name: "permanent", err: fs.ErrPermission, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "source.txt")
+			destFile := filepath.Join(tmpDir, "dest.txt")
+
+			// Setup: Create source file and a reader that always fails
+			if err := os.WriteFile(sourceFile, []byte("broken"), 0o600); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			opts, err := parseArgs([]string{"--retry=2", "--retry-delay=1ms", sourceFile, destFile})
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			attempts := 0
+			opts.readSource = func(io.Reader) io.Reader {
+				attempts++
+
+				return failingReader{err: tt.err}
+			}
+
+			// Test: Copy the failing source
+			_, err = copyFile(t.Context(), opts, sourceFile, destFile)
+
+			// Verify: The error is returned after the expected attempts
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected error wrapping %v, got: %v", tt.err, err)
+			}
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+// corruptingReader flips the first byte it reads, as a flaky disk might.
+type corruptingReader struct {
+	reader  io.Reader
+	flipped bool
+}
+
+// Read implements io.Reader.
+func (reader *corruptingReader) Read(p []byte) (int, error) {
+	read, err := reader.reader.Read(p)
+	if read > 0 && !reader.flipped {
+		p[0] ^= 0xff
+		reader.flipped = true
+	}
+
+	return read, err //nolint:wrapcheck
+}
+
+// corruptAttempts makes the first corrupt reads of opts' sources flaky, and
+// returns the count of attempts made.
+func corruptAttempts(opts *options, corrupt int) *int {
+	attempts := new(int)
+	opts.readSource = func(reader io.Reader) io.Reader {
+		*attempts++
+		if *attempts <= corrupt {
+			return &corruptingReader{reader: reader, flipped: false}
+		}
+
+		return reader
+	}
+
+	return attempts
+}
+
+// checkNoTempFiles checks that dir holds only its want entries, with no
+// temporary files left behind.
+func checkNoTempFiles(t *testing.T, dir string, want int) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+
+	if len(entries) != want {
+		t.Errorf("expected no temporary files left, found %d entries", len(entries))
+	}
+}
+
+// TestCopyFile_VerifyRetry tests that --verify --retry copies again after a
+// corrupted attempt, and gives up with a clear error once attempts run out
+// without ever replacing the destination with a bad copy.
+func TestCopyFile_VerifyRetry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		corrupt      int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "corrupted once", corrupt: 1, wantAttempts: 2, wantErr: false},
+		{name: "always corrupted", corrupt: 3, wantAttempts: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "source.txt")
+			destFile := filepath.Join(tmpDir, "dest.txt")
+
+			// Setup: Create source file, an old destination and a corrupting reader
+			writeFiles(t, tmpDir, map[string]string{"source.txt": "precious", "dest.txt": "old"})
+
+			args := []string{"--verify", "--retry=2", "--retry-delay=1ms", sourceFile, destFile}
+
+			opts, err := parseArgs(args)
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			opts.stderr = io.Discard
+			attempts := corruptAttempts(opts, tt.corrupt)
+
+			// Test: Copy through the flaky reader
+			_, err = copyFile(t.Context(), opts, sourceFile, destFile)
+
+			// Verify: The expected attempts were made and dest holds a good copy or the old file
+			if *attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", *attempts, tt.wantAttempts)
+			}
+
+			want := "precious"
+			if tt.wantErr {
+				want = "old"
+
+				if !errors.Is(err, errVerifyMismatch) ||
+					!strings.HasPrefix(err.Error(), "giving up on ") {
+					t.Errorf("expected a giving-up verification error, got: %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("copyFile() failed: %v", err)
+			}
+
+			if got, _ := os.ReadFile(destFile); string(got) != want {
+				t.Errorf("content = %q, want %q", got, want)
+			}
+
+			checkNoTempFiles(t, tmpDir, 2)
+		})
+	}
+}
+
+// TestCopyFile_VerifyRetryThroughSymlink tests that --verify --retry writes
+// through a symlinked destination, as a plain copy does, keeping the mode of
+// the file it replaces.
+func TestCopyFile_VerifyRetryThroughSymlink(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	targetFile := filepath.Join(tmpDir, "target.txt")
+	linkFile := filepath.Join(tmpDir, "link.txt")
+
+	// Setup: Create source file and a destination symlink to an older file
+	writeFiles(t, tmpDir, map[string]string{"source.txt": "precious", "target.txt": "old"})
+
+	if err := os.Chmod(targetFile, 0o640); err != nil {
+		t.Fatalf("failed to set target mode: %v", err)
+	}
+
+	if err := os.Symlink(targetFile, linkFile); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	args := []string{"--verify", "--retry=1", "--retry-delay=1ms", sourceFile, linkFile}
+
+	opts, err := parseArgs(args)
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	opts.stderr = io.Discard
+	corruptAttempts(opts, 1)
+
+	// Test: Copy through the flaky reader
+	if _, err := copyFile(t.Context(), opts, sourceFile, linkFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The link is kept and its target holds the copy with its old mode
+	if !isSymlink(linkFile) {
+		t.Error("expected the destination symlink to be kept")
+	}
+
+	if got, _ := os.ReadFile(targetFile); string(got) != "precious" {
+		t.Errorf("target content = %q, want %q", got, "precious")
+	}
+
+	info, err := os.Stat(targetFile)
+	if err != nil {
+		t.Fatalf("failed to stat target: %v", err)
+	}
+
+	if runtime.GOOS != goosWindows && info.Mode().Perm() != 0o640 {
+		t.Errorf("target mode = %v, want %v", info.Mode().Perm(), fs.FileMode(0o640))
+	}
+}
+
+// TestCopyFile_VerifyRetryDanglingSymlink tests that --verify --retry
+// refuses a destination symlink to nothing instead of replacing the link.
+func TestCopyFile_VerifyRetryDanglingSymlink(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	linkFile := filepath.Join(tmpDir, "link.txt")
+
+	// Setup: Create source file and a destination symlink to nothing
+	if err := os.WriteFile(sourceFile, []byte("precious"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Symlink(filepath.Join(tmpDir, "missing.txt"), linkFile); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	opts, err := parseArgs([]string{"--verify", "--retry=1", sourceFile, linkFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	// Test: Copy to the dangling symlink
+	_, err = copyFile(t.Context(), opts, sourceFile, linkFile)
+
+	// Verify: The copy is refused and the link left alone
+	if err == nil || !strings.Contains(err.Error(), "dangling symlink") {
+		t.Errorf("expected a dangling symlink error, got: %v", err)
+	}
+
+	if !isSymlink(linkFile) {
+		t.Error("expected the destination symlink to be kept")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// errSpaceUnsupported is returned where free space can't be queried.
+var errSpaceUnsupported = errors.New("free space check not supported on this platform")
+
+// checkSpace implements --check-space, refusing to copy size bytes to dest
+// when its filesystem doesn't have room for them. An existing destination
+// counts as free space, since it is overwritten.
+func checkSpace(opts *options, dest string, size int64) error {
+	freeSpace := opts.freeSpace
+	if freeSpace == nil {
+		freeSpace = diskFreeSpace
+	}
+
+	available, err := freeSpace(filepath.Dir(dest))
+	if errors.Is(err, errSpaceUnsupported) {
+		opts.warnf("%v", err)
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if destInfo, err := os.Stat(dest); err == nil && destInfo.Mode().IsRegular() {
+		available += uint64(destInfo.Size()) //nolint:gosec
+	}
+
+	if need := uint64(size); need > available { //nolint:gosec
+		return fmt.Errorf( //nolint:err113
+			"insufficient space: need %d bytes, have %d bytes", need, available,
+		)
+	}
+
+	return nil
+}
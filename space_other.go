@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// diskFreeSpace reports that free space can't be queried on this platform.
+func diskFreeSpace(string) (uint64, error) {
+	return 0, errSpaceUnsupported
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCopyFile_CheckSpaceInsufficient tests that --check-space refuses a copy
+// larger than the free space and leaves no destination behind.
+func TestCopyFile_CheckSpaceInsufficient(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+
+	// Setup: Create a 1000-byte source
+	if err := os.WriteFile(sourceFile, make([]byte, 1000), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with a free-space lookup reporting 100 bytes
+	opts := new(options)
+	opts.checkSpace = true
+	opts.freeSpace = func(string) (uint64, error) { return 100, nil }
+
+	_, err := copyFile(t.Context(), opts, sourceFile, destFile)
+
+	// Verify: The copy is refused before creating the destination
+	want := "insufficient space: need 1000 bytes, have 100 bytes"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected error %q, got: %v", want, err)
+	}
+
+	if _, err := os.Stat(destFile); !os.IsNotExist(err) {
+		t.Errorf("expected no destination file, got err: %v", err)
+	}
+}
+
+// TestCopyFile_CheckSpaceCountsOverwrite tests that the size of an existing
+// destination counts as available space.
+func TestCopyFile_CheckSpaceCountsOverwrite(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+
+	// Setup: Create a 1000-byte source and a 950-byte destination
+	if err := os.WriteFile(sourceFile, make([]byte, 1000), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.WriteFile(destFile, make([]byte, 950), 0o600); err != nil {
+		t.Fatalf("failed to create destination file: %v", err)
+	}
+
+	// Test: Copy with a free-space lookup reporting 100 bytes
+	opts := new(options)
+	opts.checkSpace = true
+	opts.freeSpace = func(string) (uint64, error) { return 100, nil }
+
+	// Verify: The overwrite fits
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+}
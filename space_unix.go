@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// diskFreeSpace returns the bytes available to unprivileged users on the
+// filesystem holding dir.
+func diskFreeSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("checking free space: %w", err)
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil //nolint:gosec
+}
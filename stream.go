@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+const (
+	// streamBufferSize is the default size of copy buffers (--buffer-size).
+	streamBufferSize = 32 * 1024
+	// maxBufferSize is the largest --buffer-size accepted.
+	maxBufferSize = 1 << 30
+)
+
+// errCopyTimedOut is returned when --timeout expires during a copy.
+var errCopyTimedOut = errors.New("copy timed out")
+
+// bufferPool hands out copy buffers of one size, so that a copy of many
+// files reuses a few buffers instead of allocating one per file. It is safe
+// for concurrent use.
+type bufferPool struct {
+	pool sync.Pool
+}
+
+// newBufferPool returns a pool of size-byte buffers, or streamBufferSize
+// bytes if size is zero.
+func newBufferPool(size int) *bufferPool {
+	if size <= 0 {
+		size = streamBufferSize
+	}
+
+	return &bufferPool{pool: sync.Pool{New: func() any {
+		buf := make([]byte, size)
+
+		return &buf
+	}}}
+}
+
+// get takes a buffer from the pool.
+func (pool *bufferPool) get() *[]byte {
+	return pool.pool.Get().(*[]byte) //nolint:forcetypeassert
+}
+
+// put returns a buffer taken with get to the pool.
+func (pool *bufferPool) put(buf *[]byte) {
+	pool.pool.Put(buf)
+}
+
+// copyBuffers returns the pool of copy buffers shared by every file of this
+// copy, creating it on first use.
+func (opts *options) copyBuffers() *bufferPool {
+	if opts.buffers == nil {
+		opts.buffers = newBufferPool(opts.copyBufferSize())
+	}
+
+	return opts.buffers
+}
+
+// copyBufferSize returns the size of copy buffers: --chunk-size if given, as
+// chunks are read into them whole, and otherwise --buffer-size.
+func (opts *options) copyBufferSize() int {
+	if opts.chunkSize > 0 {
+		return opts.chunkSize
+	}
+
+	return opts.bufferSize
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	read   int64
+}
+
+// Read implements io.Reader.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.read += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// copyStream copies src to dst through buf, checking ctx between chunks.
+// Without a cancelable context it defers to io.CopyBuffer to keep the fast
+// paths of io.Copy. A nil buf allocates one.
+func copyStream(ctx context.Context, dst io.Writer, src io.Reader, buf []byte) (int64, error) {
+	if ctx.Done() == nil {
+		return io.CopyBuffer(dst, src, buf) //nolint:wrapcheck
+	}
+
+	if buf == nil {
+		buf = make([]byte, streamBufferSize)
+	}
+
+	var written int64
+
+	for {
+		if err := canceled(ctx); err != nil {
+			return written, err
+		}
+
+		nr, readErr := src.Read(buf)
+		if nr > 0 {
+			nw, writeErr := dst.Write(buf[:nr])
+			written += int64(nw)
+
+			if writeErr != nil {
+				return written, writeErr //nolint:wrapcheck
+			}
+
+			if nw != nr {
+				return written, io.ErrShortWrite
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			return written, nil
+		}
+
+		if readErr != nil {
+			return written, readErr //nolint:wrapcheck
+		}
+	}
+}
+
+// copyChunks implements --chunk-size, copying src to dst in chunks of
+// len(buf) bytes and passing each to onChunk, if set, before it is written.
+// Every chunk but the last is full, so a source of n bytes makes
+// ceil(n/len(buf)) calls. An error from onChunk stops the copy.
+func copyChunks(
+	ctx context.Context, dst io.Writer, src io.Reader, buf []byte, onChunk func(chunk []byte) error,
+) (int64, error) {
+	var written int64
+
+	for {
+		if err := canceled(ctx); err != nil {
+			return written, err
+		}
+
+		nr, readErr := io.ReadFull(src, buf)
+		if nr > 0 {
+			nw, err := writeChunk(dst, buf[:nr], onChunk)
+			written += int64(nw)
+
+			if err != nil {
+				return written, err
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return written, nil
+		}
+
+		if readErr != nil {
+			return written, readErr //nolint:wrapcheck
+		}
+	}
+}
+
+// writeChunk passes chunk to onChunk, if set, and then writes it to dst.
+func writeChunk(dst io.Writer, chunk []byte, onChunk func(chunk []byte) error) (int, error) {
+	if onChunk != nil {
+		if err := onChunk(chunk); err != nil {
+			return 0, err
+		}
+	}
+
+	written, err := dst.Write(chunk)
+	if err != nil {
+		return written, err //nolint:wrapcheck
+	}
+
+	if written != len(chunk) {
+		return written, io.ErrShortWrite
+	}
+
+	return written, nil
+}
+
+// canceled returns the error ending a copy when ctx is done, or nil.
+func canceled(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errCopyTimedOut
+	}
+
+	return err //nolint:wrapcheck
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowReader returns one byte per read, sleeping before each.
+type slowReader struct {
+	delay time.Duration
+}
+
+// Read implements io.Reader.
+func (r slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+
+	p[0] = 'x'
+
+	return 1, nil
+}
+
+// TestCopyStream_Timeout tests that a slow copy stops once the deadline passes.
+func TestCopyStream_Timeout(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	// Test: Copy from a reader that never ends
+	var dst bytes.Buffer
+
+	_, err := copyStream(ctx, &dst, slowReader{delay: 5 * time.Millisecond}, nil)
+
+	// Verify: The copy timed out
+	if !errors.Is(err, errCopyTimedOut) {
+		t.Errorf("expected %v, got %v", errCopyTimedOut, err)
+	}
+}
+
+// TestCopyFile_TimeoutRemovesPartial tests that a timed out copy leaves no
+// destination behind.
+func TestCopyFile_TimeoutRemovesPartial(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create source file and an already expired deadline
+	if err := os.WriteFile(sourceFile, []byte("too late"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 0)
+	defer cancel()
+
+	// Test: Copy with the expired deadline
+	_, err := copyFile(ctx, new(options), sourceFile, destFile)
+
+	// Verify: The copy timed out and the destination was removed
+	if !errors.Is(err, errCopyTimedOut) {
+		t.Errorf("expected %v, got %v", errCopyTimedOut, err)
+	}
+
+	if _, err := os.Stat(destFile); !os.IsNotExist(err) {
+		t.Errorf("expected partial destination to be removed, got err: %v", err)
+	}
+}
+
+// TestCopyFile_PartialSuffix tests that --partial-suffix keeps the bytes
+// written by an interrupted copy, and renames the file once a copy succeeds.
+func TestCopyFile_PartialSuffix(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+	partialFile := destFile + ".part"
+
+	// Setup: Create source file and a deadline that expires mid-copy
+	if err := os.WriteFile(sourceFile, []byte("complete"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	opts, err := parseArgs([]string{"--partial-suffix=.part", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	opts.readSource = func(io.Reader) io.Reader {
+		return slowReader{delay: 5 * time.Millisecond}
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	// Test: Copy until the deadline interrupts it
+	_, err = copyFile(ctx, opts, sourceFile, destFile)
+
+	// Verify: The partial file holds the bytes copied so far
+	if !errors.Is(err, errCopyTimedOut) {
+		t.Fatalf("expected %v, got %v", errCopyTimedOut, err)
+	}
+
+	partial, err := os.ReadFile(partialFile)
+	if err != nil {
+		t.Fatalf("expected partial file to remain: %v", err)
+	}
+
+	if len(partial) == 0 || strings.Trim(string(partial), "x") != "" {
+		t.Errorf("partial content = %q, want the bytes read so far", partial)
+	}
+
+	assertCopied(t, tmpDir, map[string]bool{"dest.txt": false})
+
+	// Test: Copy again without interruption
+	opts.readSource = nil
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The partial file was renamed to the destination
+	if got, _ := os.ReadFile(destFile); string(got) != "complete" {
+		t.Errorf("content = %q, want %q", got, "complete")
+	}
+
+	assertCopied(t, tmpDir, map[string]bool{"dest.txt.part": false})
+}
+
+// TestCopyFile_SourceChanged tests that a source yielding more bytes than
+// its size when stat'ed is copied with a warning, or fails under --strict.
+func TestCopyFile_SourceChanged(t *testing.T) {
+	t.Parallel()
+
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "app.log")
+			destFile := filepath.Join(tmpDir, "copy.log")
+
+			// Setup: Create a source that grows while it is read
+			if err := os.WriteFile(sourceFile, []byte("line 1\n"), 0o600); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			var stderr bytes.Buffer
+
+			opts := new(options)
+			opts.strict = strict
+			opts.stderr = &stderr
+			opts.readSource = func(reader io.Reader) io.Reader {
+				return io.MultiReader(reader, strings.NewReader("line 2\n"))
+			}
+
+			// Test: Copy the growing source
+			_, err := copyFile(t.Context(), opts, sourceFile, destFile)
+
+			// Verify: The change is a warning, or an error under --strict
+			want := "source changed during copy: expected 7, copied 14"
+
+			if strict {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("expected source changed error, got: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("copyFile() failed: %v", err)
+			}
+
+			if !strings.Contains(stderr.String(), "Warning: "+sourceFile+": "+want) {
+				t.Errorf("expected source changed warning, got %q", stderr.String())
+			}
+		})
+	}
+}
+
+// TestCopyFile_ChunkCallbacks tests that --chunk-size hands the chunk hook
+// ceil(size/chunkSize) chunks that add up to the source.
+func TestCopyFile_ChunkCallbacks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		size int
+		want int
+	}{
+		{name: "empty", size: 0, want: 0},
+		{name: "exact multiple", size: 4096, want: 4},
+		{name: "partial last chunk", size: 4097, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "source.bin")
+			destFile := filepath.Join(tmpDir, "dest.bin")
+
+			// Setup: Create source file
+			content := bytes.Repeat([]byte("c"), tt.size)
+			if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			opts, err := parseArgs([]string{"--chunk-size=1K", sourceFile, destFile})
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			var chunks, total int
+
+			opts.onChunk = func(chunk []byte) error {
+				chunks++
+				total += len(chunk)
+
+				return nil
+			}
+
+			// Test: Copy in chunks
+			if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+				t.Fatalf("copyFile() failed: %v", err)
+			}
+
+			// Verify: One callback per chunk, covering the whole source
+			if chunks != tt.want {
+				t.Errorf("chunk callbacks = %d, want %d", chunks, tt.want)
+			}
+
+			if total != tt.size {
+				t.Errorf("chunk bytes = %d, want %d", total, tt.size)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// copyStats counts the outcome of a copy for --summary.
+type copyStats struct {
+	files   int
+	bytes   int64
+	skipped int
+	errors  int
+}
+
+// summaryLine formats the --summary line for a copy that took elapsed.
+func (opts *options) summaryLine(elapsed time.Duration) string {
+	return fmt.Sprintf(
+		"%d files, %d bytes copied, %d skipped, %d errors in %s",
+		opts.stats.files,
+		opts.stats.bytes,
+		opts.stats.skipped,
+		opts.stats.errors,
+		elapsed.Round(time.Millisecond),
+	)
+}
+
+// printSummary prints the --summary line for a copy started at start.
+func (opts *options) printSummary(start time.Time) {
+	opts.successf("%s\n", opts.summaryLine(time.Since(start)))
+}
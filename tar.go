@@ -0,0 +1,285 @@
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// archiveMode is the permission given to newly created archives.
+const archiveMode = 0o644
+
+// runToTar implements --to-tar, adding sources to a new or existing archive.
+// Existing entries are carried over into a temporary archive that replaces
+// the original once all sources were added.
+func runToTar(opts *options, sources []string) error {
+	archive := opts.toTar
+
+	temp, err := os.CreateTemp(filepath.Dir(archive), ".cp-tar-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary archive: %w", err)
+	}
+
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	archives, err := prepareTempArchive(temp, archive)
+	if err != nil {
+		return err
+	}
+
+	writer := tar.NewWriter(temp)
+
+	if err := copyTarEntries(writer, archive); err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		if err := addToTar(opts, writer, source, archives); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("finishing archive: %w", err)
+	}
+
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("closing archive: %w", err)
+	}
+
+	if err := os.Rename(temp.Name(), archive); err != nil {
+		return fmt.Errorf("replacing archive: %w", err)
+	}
+
+	opts.successf("Added %d source(s) to %s successfully.\n", len(sources), archive)
+
+	return nil
+}
+
+// prepareTempArchive gives temp the mode of the archive it replaces and
+// returns the archives a tree being added must leave out: temp itself and
+// the existing archive, if any.
+func prepareTempArchive(temp *os.File, archive string) ([]fs.FileInfo, error) {
+	mode := fs.FileMode(archiveMode)
+
+	existing, statErr := os.Stat(archive)
+	if statErr == nil {
+		mode = existing.Mode().Perm()
+	}
+
+	if err := temp.Chmod(mode); err != nil {
+		return nil, fmt.Errorf("setting archive mode: %w", err)
+	}
+
+	// The archive being written may lie in a directory being added.
+	own, err := temp.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("getting temporary archive info: %w", err)
+	}
+
+	archives := []fs.FileInfo{own}
+	if statErr == nil {
+		archives = append(archives, existing)
+	}
+
+	return archives, nil
+}
+
+// copyTarEntries copies every entry of an existing archive to writer.
+// A missing archive is treated as empty.
+func copyTarEntries(writer *tar.Writer, archive string) error {
+	file, err := os.Open(archive)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("opening archive: %w", err)
+	}
+
+	defer file.Close()
+
+	reader := tar.NewReader(file)
+
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+
+		if err := writer.WriteHeader(header); err != nil {
+			return fmt.Errorf("writing archive: %w", err)
+		}
+
+		if _, err := io.Copy(writer, reader); err != nil { //nolint:gosec
+			return fmt.Errorf("writing archive: %w", err)
+		}
+	}
+}
+
+// addToTar adds source to the archive. Directories are added with their
+// whole tree under -r and rejected otherwise, leaving out the archives
+// being read and written.
+func addToTar(opts *options, writer *tar.Writer, source string, archives []fs.FileInfo) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("getting source file info: %w", err)
+	}
+
+	if !info.IsDir() {
+		return addTarEntry(writer, source, filepath.Base(source), info)
+	}
+
+	if !opts.recursive {
+		return fmt.Errorf("omitting directory '%s' (use -r)", source) //nolint:err113
+	}
+
+	parent := filepath.Dir(filepath.Clean(source))
+
+	walkErr := filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(parent, path)
+		if err != nil {
+			return fmt.Errorf("resolving relative path: %w", err)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("getting file info: %w", err)
+		}
+
+		isArchive := func(archive fs.FileInfo) bool { return os.SameFile(archive, info) }
+		if slices.ContainsFunc(archives, isArchive) {
+			return nil
+		}
+
+		return addTarEntry(writer, path, rel, info)
+	})
+	if walkErr != nil {
+		return fmt.Errorf("adding directory: %w", walkErr)
+	}
+
+	return nil
+}
+
+// addTarEntry writes a header for path under name, followed by its content
+// when it is a regular file.
+func addTarEntry(writer *tar.Writer, path, name string, info fs.FileInfo) error {
+	link := ""
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("reading symlink: %w", err)
+		}
+
+		link = target
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return fmt.Errorf("creating archive header: %w", err)
+	}
+
+	header.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err := writer.WriteHeader(header); err != nil {
+		return fmt.Errorf("writing archive header: %w", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening source file: %w", err)
+	}
+
+	defer file.Close()
+
+	if _, err := io.Copy(writer, file); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+
+	return nil
+}
+
+// runFromTar implements --from-tar, extracting the archive member named by
+// entry to dest.
+func runFromTar(opts *options, entry, dest string) error {
+	file, err := os.Open(opts.fromTar)
+	if err != nil {
+		return fmt.Errorf("opening archive: %w", err)
+	}
+
+	defer file.Close()
+
+	reader := tar.NewReader(file)
+	want := strings.TrimPrefix(filepath.ToSlash(entry), "./")
+
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("entry '%s' not found in %s", entry, opts.fromTar) //nolint:err113
+		}
+
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+
+		if strings.TrimPrefix(header.Name, "./") != want {
+			continue
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return fmt.Errorf("entry '%s' is not a regular file", entry) //nolint:err113
+		}
+
+		if err := extractTarEntry(reader, dest, header.FileInfo().Mode().Perm()); err != nil {
+			return err
+		}
+
+		opts.successf("File extracted from %s:%s to %s successfully.\n", opts.fromTar, entry, dest)
+
+		return nil
+	}
+}
+
+// extractTarEntry writes the current archive entry to dest, creating it with
+// the entry's permission bits.
+func extractTarEntry(reader io.Reader, dest string, perm fs.FileMode) error {
+	destFile, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("creating destination file: %w", err)
+	}
+
+	defer destFile.Close()
+
+	if _, err := io.Copy(destFile, reader); err != nil {
+		return fmt.Errorf("extracting entry: %w", err)
+	}
+
+	if err := destFile.Close(); err != nil {
+		return fmt.Errorf("closing destination file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readTar returns the regular file contents of an archive keyed by name.
+func readTar(t *testing.T, archive string) map[string]string {
+	t.Helper()
+
+	file, err := os.Open(archive)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	entries := make(map[string]string)
+	reader := tar.NewReader(file)
+
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read entry %s: %v", header.Name, err)
+		}
+
+		entries[header.Name] = string(content)
+	}
+}
+
+// firstTarHeader returns the header of the first entry of an archive.
+func firstTarHeader(t *testing.T, archive string) *tar.Header {
+	t.Helper()
+
+	file, err := os.Open(archive)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	header, err := tar.NewReader(file).Next()
+	if err != nil {
+		t.Fatalf("failed to read first header: %v", err)
+	}
+
+	return header
+}
+
+// writeTarSources creates a file with the given mtime and a small tree
+// holding site/css/main.css.
+func writeTarSources(t *testing.T, sourceFile, sourceDir string, mtime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(sourceFile, []byte("key: value"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Chtimes(sourceFile, mtime, mtime); err != nil {
+		t.Fatalf("failed to set times: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(sourceDir, "css"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	cssFile := filepath.Join(sourceDir, "css", "main.css")
+	if err := os.WriteFile(cssFile, []byte("body{}"), 0o600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+}
+
+// TestRunToTar tests adding files and a tree to a new and then existing archive.
+func TestRunToTar(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	archive := filepath.Join(tmpDir, "out.tar")
+	sourceFile := filepath.Join(tmpDir, "config.yaml")
+	sourceDir := filepath.Join(tmpDir, "site")
+	mtime := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	// Setup: Create a file and a small tree
+	writeTarSources(t, sourceFile, sourceDir, mtime)
+
+	opts := new(options)
+	opts.toTar = archive
+
+	// Test: Create the archive with one file, then append the tree
+	if err := runToTar(opts, []string{sourceFile}); err != nil {
+		t.Fatalf("runToTar() failed: %v", err)
+	}
+
+	opts.recursive = true
+
+	if err := runToTar(opts, []string{sourceDir}); err != nil {
+		t.Fatalf("runToTar() failed: %v", err)
+	}
+
+	// Verify: Both the original and appended entries are present
+	entries := readTar(t, archive)
+
+	if entries["config.yaml"] != "key: value" {
+		t.Errorf("config.yaml mismatch: got %q", entries["config.yaml"])
+	}
+
+	if entries["site/css/main.css"] != "body{}" {
+		t.Errorf("site/css/main.css mismatch: got %q", entries["site/css/main.css"])
+	}
+
+	if _, ok := entries["site/css/"]; !ok {
+		t.Errorf("expected directory entry site/css/, got %v", entries)
+	}
+
+	// Verify: Header keeps mode and mtime
+	header := firstTarHeader(t, archive)
+
+	if header.Mode&0o777 != 0o600 || !header.ModTime.Equal(mtime) {
+		t.Errorf("header mismatch: mode %o, mtime %v", header.Mode, header.ModTime)
+	}
+}
+
+// TestRunToTar_ArchiveInSource tests that archiving a directory into an
+// archive inside it leaves out the archive itself, on creation and reruns.
+func TestRunToTar_ArchiveInSource(t *testing.T) {
+	t.Parallel()
+	sourceDir := filepath.Join(t.TempDir(), "d")
+	archive := filepath.Join(sourceDir, "out.tar")
+
+	// Setup: Create a directory with a file
+	if err := os.Mkdir(sourceDir, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	sourceFile := filepath.Join(sourceDir, "file.txt")
+	if err := os.WriteFile(sourceFile, []byte("content"), 0o600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	// Test: Archive the directory into itself twice
+	for run := range 2 {
+		opts := new(options)
+		opts.toTar = archive
+		opts.recursive = true
+		opts.quiet = true
+
+		if err := runToTar(opts, []string{sourceDir}); err != nil {
+			t.Fatalf("runToTar() run %d failed: %v", run+1, err)
+		}
+	}
+
+	// Verify: Each run added the directory and file but no archive
+	names := make(map[string]int)
+
+	file, err := os.Open(archive)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	reader := tar.NewReader(file)
+
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+
+		names[header.Name]++
+	}
+
+	want := map[string]int{"d/": 2, "d/file.txt": 2}
+	if !maps.Equal(names, want) {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+}
+
+// writeTar creates an archive holding members as regular files.
+func writeTar(t *testing.T, archive string, members map[string]string) {
+	t.Helper()
+
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	defer file.Close()
+
+	writer := tar.NewWriter(file)
+
+	for name, content := range members {
+		header := new(tar.Header)
+		header.Name = name
+		header.Mode = 0o644
+		header.Size = int64(len(content))
+		header.Typeflag = tar.TypeReg
+
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write content: %v", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to finish archive: %v", err)
+	}
+}
+
+// TestRunFromTar tests extracting a single member from an archive.
+func TestRunFromTar(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	archive := filepath.Join(tmpDir, "release.tar")
+	destFile := filepath.Join(tmpDir, "app.conf")
+	members := map[string]string{
+		"README":          "readme",
+		"etc/app.conf":    "listen = 8080",
+		"bin/app.sh":      "#!/bin/sh",
+		"etc/other.conf":  "other",
+		"share/notes.txt": "notes",
+	}
+
+	// Setup: Build an archive with several members
+	writeTar(t, archive, members)
+
+	opts := new(options)
+	opts.fromTar = archive
+
+	// Test: Extract one member
+	if err := runFromTar(opts, "etc/app.conf", destFile); err != nil {
+		t.Fatalf("runFromTar() failed: %v", err)
+	}
+
+	// Verify: Only that member's content was extracted
+	got, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if string(got) != members["etc/app.conf"] {
+		t.Errorf("content mismatch: got %q, want %q", got, members["etc/app.conf"])
+	}
+
+	// Test & Verify: A missing member is reported
+	err = runFromTar(opts, "etc/missing.conf", filepath.Join(tmpDir, "missing.conf"))
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
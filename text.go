@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// --eol styles.
+const (
+	eolLF   = "lf"
+	eolCRLF = "crlf"
+)
+
+// errBinaryText is returned when --text meets a NUL byte.
+var errBinaryText = errors.New("refusing text conversion on binary file")
+
+// eolWriter rewrites every line ending, LF or CRLF, to a single style before
+// passing the bytes on. A CR not followed by LF is kept as it is.
+type eolWriter struct {
+	writer    io.Writer
+	eol       []byte
+	pendingCR bool
+}
+
+// newEOLWriter returns a writer converting line endings to style.
+func newEOLWriter(writer io.Writer, style string) *eolWriter {
+	eol := []byte("\n")
+	if style == eolCRLF {
+		eol = []byte("\r\n")
+	}
+
+	return &eolWriter{writer: writer, eol: eol, pendingCR: false}
+}
+
+// Write implements io.Writer.
+func (ew *eolWriter) Write(data []byte) (int, error) {
+	out := make([]byte, 0, len(data)+len(data)/8)
+
+	for _, octet := range data {
+		if octet == 0 {
+			return 0, errBinaryText
+		}
+
+		if ew.pendingCR {
+			ew.pendingCR = false
+
+			if octet == '\n' {
+				out = append(out, ew.eol...)
+
+				continue
+			}
+
+			out = append(out, '\r')
+		}
+
+		switch octet {
+		case '\r':
+			ew.pendingCR = true
+		case '\n':
+			out = append(out, ew.eol...)
+		default:
+			out = append(out, octet)
+		}
+	}
+
+	if _, err := ew.writer.Write(out); err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return len(data), nil
+}
+
+// Close writes a trailing CR held back by the last Write.
+func (ew *eolWriter) Close() error {
+	if !ew.pendingCR {
+		return nil
+	}
+
+	ew.pendingCR = false
+
+	if _, err := ew.writer.Write([]byte{'\r'}); err != nil {
+		return fmt.Errorf("writing text: %w", err)
+	}
+
+	return nil
+}
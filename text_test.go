@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFile_TextEOL tests converting line endings in both directions.
+func TestCopyFile_TextEOL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		eol   string
+		input string
+		want  string
+	}{
+		{name: "crlf to lf", eol: eolLF, input: "one\r\ntwo\r\nthree", want: "one\ntwo\nthree"},
+		{name: "lf to crlf", eol: eolCRLF, input: "one\ntwo\n", want: "one\r\ntwo\r\n"},
+		{name: "mixed to crlf", eol: eolCRLF, input: "a\r\nb\nc\r", want: "a\r\nb\r\nc\r"},
+		{name: "lone cr kept", eol: eolLF, input: "a\rb\r\n", want: "a\rb\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			sourceFile := filepath.Join(tmpDir, "source.txt")
+			destFile := filepath.Join(tmpDir, "dest.txt")
+
+			// Setup: Create source file
+			if err := os.WriteFile(sourceFile, []byte(tt.input), 0o600); err != nil {
+				t.Fatalf("failed to create source file: %v", err)
+			}
+
+			// Test: Copy in text mode
+			opts, err := parseArgs([]string{"--eol=" + tt.eol, sourceFile, destFile})
+			if err != nil {
+				t.Fatalf("parseArgs() failed: %v", err)
+			}
+
+			if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+				t.Fatalf("copyFile() failed: %v", err)
+			}
+
+			// Verify: Line endings were converted
+			got, err := os.ReadFile(destFile)
+			if err != nil {
+				t.Fatalf("failed to read destination file: %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCopyFile_TextRefusesBinary tests that --text rejects a source with NUL
+// bytes and leaves no destination behind.
+func TestCopyFile_TextRefusesBinary(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.bin")
+	destFile := filepath.Join(tmpDir, "dest.bin")
+
+	// Setup: Create a binary source
+	if err := os.WriteFile(sourceFile, []byte("text\r\n\x00\x01\x02"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy in text mode
+	opts := new(options)
+	opts.text = true
+
+	_, err := copyFile(t.Context(), opts, sourceFile, destFile)
+
+	// Verify: The copy is refused
+	if !errors.Is(err, errBinaryText) {
+		t.Fatalf("expected %v, got: %v", errBinaryText, err)
+	}
+
+	if _, err := os.Stat(destFile); !os.IsNotExist(err) {
+		t.Errorf("expected no destination file, got err: %v", err)
+	}
+}
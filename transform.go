@@ -0,0 +1,85 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// transformFactory wraps w in a writer that transforms the bytes written to
+// it before passing them on. Closing the writer flushes any buffered output
+// to w but doesn't close w.
+type transformFactory func(w io.Writer) io.WriteCloser
+
+// transformRegistry maps --transform names to their writer factories.
+func transformRegistry() map[string]transformFactory {
+	return map[string]transformFactory{
+		"gzip": func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		"base64": func(w io.Writer) io.WriteCloser {
+			return base64.NewEncoder(base64.StdEncoding, w)
+		},
+	}
+}
+
+// parseTransforms parses a comma-separated --transform list.
+func parseTransforms(value string) ([]string, error) {
+	registry := transformRegistry()
+	names := strings.Split(value, ",")
+
+	for idx, name := range names {
+		name = strings.TrimSpace(name)
+		if _, ok := registry[name]; !ok {
+			return nil, fmt.Errorf("unknown transform '%s'", name) //nolint:err113
+		}
+
+		names[idx] = name
+	}
+
+	return names, nil
+}
+
+// transformChain applies a --transform list to the bytes written to it, in
+// order, so the first transform sees the source and the last one writes to
+// the destination.
+type transformChain struct {
+	io.Writer
+
+	// stages holds the transforms, first to last.
+	stages []io.WriteCloser
+}
+
+// newTransformChain builds the transforms named in names around writer.
+func newTransformChain(writer io.Writer, names []string) *transformChain {
+	registry := transformRegistry()
+	stages := make([]io.WriteCloser, len(names))
+
+	for idx := len(names) - 1; idx >= 0; idx-- {
+		stages[idx] = registry[names[idx]](writer)
+		writer = stages[idx]
+	}
+
+	return &transformChain{Writer: writer, stages: stages}
+}
+
+// Close flushes every transform, first to last, so each one's trailing
+// output passes through the transforms after it.
+func (chain *transformChain) Close() error {
+	var errs []error
+
+	for _, stage := range chain.stages {
+		if err := stage.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("finishing transform: %w", err)
+	}
+
+	return nil
+}
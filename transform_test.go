@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCopyFile_TransformChain tests that --transform=gzip,base64 writes a
+// destination that decodes and decompresses back to the source.
+func TestCopyFile_TransformChain(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+	content := []byte(strings.Repeat("transform me\n", 100))
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, content, 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy through gzip and then base64
+	opts, err := parseArgs([]string{"--transform=gzip,base64", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Reversing the transforms yields the source
+	encoded, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		t.Fatalf("destination is not base64: %v", err)
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("decoded destination is not gzip: %v", err)
+	}
+
+	got, err := io.ReadAll(gzipReader)
+	if err != nil {
+		t.Fatalf("failed to decompress destination: %v", err)
+	}
+
+	if !bytes.Equal(got, content) {
+		t.Errorf("round-tripped content mismatch: got %d bytes, want %d", len(got), len(content))
+	}
+}
+
+// TestParseArgs_UnknownTransform tests that an unregistered transform name
+// is rejected.
+func TestParseArgs_UnknownTransform(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseArgs([]string{"--transform=gzip,rot13", "a", "b"})
+	if err == nil || !strings.Contains(err.Error(), "unknown transform 'rot13'") {
+		t.Fatalf("expected unknown transform error, got: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// trashTimeFormat stamps the names of files moved to the --trash directory,
+// so that successive versions of a destination don't collide.
+const trashTimeFormat = "20060102T150405.000000000"
+
+// trashDest implements --trash, moving an existing regular file at dest into
+// the trash directory before it is overwritten, named after dest and the
+// current time. The directory is created if needed. Where it lies on another
+// filesystem the file is copied there and removed instead.
+func trashDest(opts *options, dest string) error {
+	info, err := os.Lstat(dest)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && !info.Mode().IsRegular() {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("getting destination file info: %w", err)
+	}
+
+	if err := os.MkdirAll(opts.trash, dirMode); err != nil {
+		return fmt.Errorf("creating trash directory: %w", err)
+	}
+
+	trashed := filepath.Join(opts.trash, filepath.Base(dest)+"."+time.Now().Format(trashTimeFormat))
+
+	if err := os.Rename(dest, trashed); err != nil {
+		if err := copyToTrash(dest, trashed, info.Mode().Perm()); err != nil {
+			return err
+		}
+
+		if err := os.Remove(dest); err != nil {
+			return fmt.Errorf("removing trashed destination: %w", err)
+		}
+	}
+
+	if opts.verbose {
+		fmt.Fprintf(opts.output(), "Moved %s to %s\n", dest, trashed)
+	}
+
+	return nil
+}
+
+// copyToTrash copies dest to the new file trashed, for a trash directory on
+// another filesystem.
+func copyToTrash(dest, trashed string, perm fs.FileMode) error {
+	source, err := os.Open(dest)
+	if err != nil {
+		return fmt.Errorf("opening destination for trash: %w", err)
+	}
+
+	defer source.Close()
+
+	target, err := os.OpenFile(trashed, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("creating trash file: %w", err)
+	}
+
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		os.Remove(trashed)
+
+		return fmt.Errorf("copying destination to trash: %w", err)
+	}
+
+	if err := target.Close(); err != nil {
+		os.Remove(trashed)
+
+		return fmt.Errorf("closing trash file: %w", err)
+	}
+
+	return nil
+}
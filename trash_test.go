@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCopyFile_Trash tests that --trash moves the overwritten destination
+// into a newly created trash directory, where its old content survives.
+func TestCopyFile_Trash(t *testing.T) {
+	t.Parallel()
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+	trashDir := filepath.Join(tmpDir, "trash", "cp")
+
+	// Setup: Create source file and an existing destination
+	writeFiles(t, tmpDir, map[string]string{"source.txt": newContent, "dest.txt": oldContent})
+
+	// Test: Overwrite the destination twice with --trash
+	opts, err := parseArgs([]string{"--trash=" + trashDir, sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	for range 2 {
+		if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+			t.Fatalf("copyFile() failed: %v", err)
+		}
+	}
+
+	// Verify: The destination holds the new content and the trash both
+	// overwritten versions under distinct names
+	if content, _ := os.ReadFile(destFile); string(content) != newContent {
+		t.Errorf("destination content = %q, want %q", content, newContent)
+	}
+
+	entries, err := os.ReadDir(trashDir)
+	if err != nil {
+		t.Fatalf("failed to read trash directory: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 trashed files, got %d", len(entries))
+	}
+
+	if name := entries[0].Name(); !strings.HasPrefix(name, "dest.txt.") {
+		t.Errorf("trashed file %q isn't named after the destination", name)
+	}
+
+	content, err := os.ReadFile(filepath.Join(trashDir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("failed to read trashed file: %v", err)
+	}
+
+	if string(content) != oldContent {
+		t.Errorf("trashed content = %q, want %q", content, oldContent)
+	}
+}
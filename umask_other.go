@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// applyUmask is a no-op where there is no umask.
+func applyUmask(opts *options, _ fs.FileMode) func() {
+	opts.warnf("--umask is not supported on this platform")
+
+	return func() {}
+}
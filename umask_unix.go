@@ -0,0 +1,18 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// applyUmask implements --umask, replacing the process umask with mask and
+// returning a function that restores the previous one.
+func applyUmask(_ *options, mask fs.FileMode) func() {
+	previous := syscall.Umask(int(mask))
+
+	return func() {
+		syscall.Umask(previous)
+	}
+}
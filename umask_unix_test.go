@@ -0,0 +1,52 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFile_Umask tests that --umask=077 strips group and other
+// permissions from a new destination. The umask is process-wide, so this
+// test doesn't run in parallel with others.
+func TestCopyFile_Umask(t *testing.T) { //nolint:paralleltest
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create a world-readable source file
+	if err := os.WriteFile(sourceFile, []byte("private"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	if err := os.Chmod(sourceFile, 0o644); err != nil {
+		t.Fatalf("failed to chmod source file: %v", err)
+	}
+
+	// Test: Copy under --umask=077
+	opts, err := parseArgs([]string{"--umask=077", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	restore := applyUmask(opts, *opts.umask)
+	_, err = copyFile(t.Context(), opts, sourceFile, destFile)
+
+	restore()
+
+	if err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The umask masked the source permissions
+	info, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatalf("failed to stat destination file: %v", err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("destination mode = %o, want 600", perm)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// writeStage is a writer of the chain that has to be closed to flush its
+// trailing output.
+type writeStage struct {
+	closer io.Closer
+	// failure describes a Close error, or is empty if the stage's errors
+	// describe themselves.
+	failure string
+}
+
+// writeChain is the stack of writers a copy writes through on its way to the
+// destination file. Each feature adds its stage on top of the ones below it,
+// so the first stage added is the closest to the file.
+type writeChain struct {
+	io.Writer
+
+	// stages holds the stages to close, closest to the file first.
+	stages []writeStage
+}
+
+// newWriteChain assembles the writers over destFile for opts: --flush-interval,
+// then the tee of --manifest and --verify, then --transform, --compress and
+// --text, the last of which sees the source bytes first.
+func newWriteChain(opts *options, destFile, sourceFile *os.File, tee io.Writer) *writeChain {
+	chain := &writeChain{Writer: destFile, stages: nil}
+
+	chain.addFlusher(opts, destFile)
+	chain.addTee(tee)
+	chain.addTransforms(opts)
+	chain.addCompressor(opts, sourceFile)
+	chain.addConverter(opts)
+
+	return chain
+}
+
+// Close flushes the stages from the top of the chain down, so each one's
+// trailing output passes through the stages below it. It leaves the
+// destination file open.
+func (chain *writeChain) Close() error {
+	for idx := len(chain.stages) - 1; idx >= 0; idx-- {
+		stage := chain.stages[idx]
+
+		err := stage.closer.Close()
+		if err != nil && stage.failure != "" {
+			return fmt.Errorf("%s: %w", stage.failure, err)
+		}
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+// push puts stage on top of the chain.
+func (chain *writeChain) push(stage io.WriteCloser, failure string) {
+	chain.Writer = stage
+	chain.stages = append(chain.stages, writeStage{closer: stage, failure: failure})
+}
+
+// addFlusher implements --flush-interval.
+func (chain *writeChain) addFlusher(opts *options, destFile *os.File) {
+	if opts.flushInterval > 0 {
+		chain.Writer = newFlushWriter(opts, destFile)
+	}
+}
+
+// addTee copies every byte written to the destination to tee, if non-nil.
+func (chain *writeChain) addTee(tee io.Writer) {
+	if tee != nil {
+		chain.Writer = io.MultiWriter(chain.Writer, tee)
+	}
+}
+
+// addTransforms implements --transform.
+func (chain *writeChain) addTransforms(opts *options) {
+	if len(opts.transforms) > 0 {
+		chain.push(newTransformChain(chain.Writer, opts.transforms), "")
+	}
+}
+
+// addCompressor implements --compress, noting under -v a source that
+// already looks compressed.
+func (chain *writeChain) addCompressor(opts *options, sourceFile *os.File) {
+	if opts.compress == "" {
+		return
+	}
+
+	if opts.verbose && looksCompressed(sourceFile) {
+		fmt.Fprintf(opts.output(), "Note: %s already looks compressed\n", sourceFile.Name())
+	}
+
+	chain.push(gzip.NewWriter(chain.Writer), "finishing compression")
+}
+
+// addConverter implements --text.
+func (chain *writeChain) addConverter(opts *options) {
+	if opts.text {
+		chain.push(newEOLWriter(chain.Writer, opts.eol), "")
+	}
+}
+
+// copyTee is the writer every byte of a copy is also written to, hashing
+// the copied bytes for --manifest and, where it can, --verify.
+type copyTee struct {
+	io.Writer
+
+	// manifest hashes the copy for --manifest, if given.
+	manifest hash.Hash
+	// written hashes the copy for --verify, unless the copy is verified by
+	// reading dest back.
+	written hash.Hash
+}
+
+// newCopyTee returns the tee of a copy under opts, whose Writer is nil if
+// nothing hashes the copied bytes.
+func newCopyTee(opts *options) (copyTee, error) {
+	var tee copyTee
+
+	var writers []io.Writer
+
+	if opts.manifest != "" {
+		tee.manifest = sha256.New()
+		writers = append(writers, tee.manifest)
+	}
+
+	// A resumed copy only writes the missing tail, so it's verified by
+	// reading dest back.
+	if opts.verify && !opts.verifyStrict && !opts.resume {
+		written, err := newHash(opts.checksum, opts.checksumSeed)
+		if err != nil {
+			return tee, err
+		}
+
+		tee.written = written
+		writers = append(writers, written)
+	}
+
+	if len(writers) > 0 {
+		tee.Writer = io.MultiWriter(writers...)
+	}
+
+	return tee, nil
+}
@@ -0,0 +1,78 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// copyXattrs copies the extended attributes of source onto dest.
+func copyXattrs(source, dest string) error {
+	size, err := syscall.Listxattr(source, nil)
+	if errors.Is(err, syscall.ENOTSUP) {
+		return nil
+	}
+
+	if err != nil || size == 0 {
+		return err //nolint:wrapcheck
+	}
+
+	names := make([]byte, size)
+
+	size, err = syscall.Listxattr(source, names)
+	if err != nil {
+		return fmt.Errorf("listing attributes: %w", err)
+	}
+
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+
+		if err := copyXattr(source, dest, string(name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// selinuxAttr is the extended attribute holding a file's SELinux context.
+const selinuxAttr = "security.selinux"
+
+// selinuxEnforcePath exists only where the SELinux filesystem is mounted.
+const selinuxEnforcePath = "/sys/fs/selinux/enforce"
+
+// copySecurityContext copies the SELinux context of source onto dest.
+func copySecurityContext(source, dest string) error {
+	if _, err := os.Stat(selinuxEnforcePath); err != nil {
+		return errSELinuxDisabled
+	}
+
+	return copyXattr(source, dest, selinuxAttr)
+}
+
+// copyXattr copies a single extended attribute from source to dest.
+func copyXattr(source, dest, name string) error {
+	size, err := syscall.Getxattr(source, name, nil)
+	if err != nil {
+		return fmt.Errorf("reading attribute %s: %w", name, err)
+	}
+
+	value := make([]byte, size)
+
+	size, err = syscall.Getxattr(source, name, value)
+	if err != nil {
+		return fmt.Errorf("reading attribute %s: %w", name, err)
+	}
+
+	if err := syscall.Setxattr(dest, name, value[:size], 0); err != nil {
+		return fmt.Errorf("writing attribute %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// readSecurityContext returns the SELinux context of path.
+func readSecurityContext(t *testing.T, path string) string {
+	t.Helper()
+
+	value := make([]byte, 256)
+
+	size, err := syscall.Getxattr(path, selinuxAttr, value)
+	if err != nil {
+		t.Fatalf("failed to read security context of %s: %v", path, err)
+	}
+
+	return string(value[:size])
+}
+
+// TestCopyFile_PreserveContext tests that --preserve=context carries the
+// SELinux context of the source over to the destination.
+func TestCopyFile_PreserveContext(t *testing.T) {
+	t.Parallel()
+
+	if _, err := os.Stat(selinuxEnforcePath); err != nil {
+		t.Skip("SELinux is not enabled")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, []byte("labelled"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with --preserve=context
+	opts, err := parseArgs([]string{"--preserve=context", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: Both files carry the same context
+	want := readSecurityContext(t, sourceFile)
+	if got := readSecurityContext(t, destFile); got != want {
+		t.Errorf("destination context = %q, want %q", got, want)
+	}
+}
+
+// TestCopyFile_PreserveContextDisabled tests that --preserve=context only
+// warns where SELinux isn't enabled.
+func TestCopyFile_PreserveContextDisabled(t *testing.T) {
+	t.Parallel()
+
+	if _, err := os.Stat(selinuxEnforcePath); err == nil {
+		t.Skip("SELinux is enabled")
+	}
+
+	tmpDir := t.TempDir()
+	sourceFile := filepath.Join(tmpDir, "source.txt")
+	destFile := filepath.Join(tmpDir, "dest.txt")
+
+	// Setup: Create source file
+	if err := os.WriteFile(sourceFile, []byte("unlabelled"), 0o600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Test: Copy with --preserve=context
+	opts, err := parseArgs([]string{"--preserve=context", sourceFile, destFile})
+	if err != nil {
+		t.Fatalf("parseArgs() failed: %v", err)
+	}
+
+	var stderr strings.Builder
+
+	opts.stderr = &stderr
+
+	if _, err := copyFile(t.Context(), opts, sourceFile, destFile); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify: The copy went ahead with a warning
+	if !strings.Contains(stderr.String(), "SELinux is not enabled") {
+		t.Errorf("expected SELinux warning, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+// copyXattrs is a no-op on platforms without extended attribute support.
+func copyXattrs(_, _ string) error {
+	return nil
+}
+
+// copySecurityContext reports that there is no SELinux on this platform.
+func copySecurityContext(_, _ string) error {
+	return errSELinuxDisabled
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// XXH64 primes.
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// XXH64 rotations, named by their bit counts.
+const (
+	xxRot1  = 1
+	xxRot7  = 7
+	xxRot11 = 11
+	xxRot12 = 12
+	xxRot18 = 18
+	xxRot23 = 23
+	xxRot27 = 27
+	xxRot31 = 31
+)
+
+// XXH64 avalanche shifts, named by their bit counts.
+const (
+	xxShift29 = 29
+	xxShift32 = 32
+	xxShift33 = 33
+)
+
+const (
+	// xxhashSize is the size of an XXH64 checksum in bytes.
+	xxhashSize = 8
+	// xxhashBlockSize is the number of bytes XXH64 consumes per round of its
+	// four accumulators.
+	xxhashBlockSize = 32
+	// xxLaneSize is the number of bytes of input mixed in at once.
+	xxLaneSize = 8
+	// xxHalfLaneSize is the number of bytes of a tail too short for a lane
+	// mixed in at once.
+	xxHalfLaneSize = 4
+)
+
+// xxhash64 is a streaming XXH64, a fast non-cryptographic hash, for
+// --checksum=xxhash. Its seed comes from --checksum-seed.
+type xxhash64 struct {
+	seed  uint64
+	acc   [4]uint64
+	total uint64
+	// buf holds input that doesn't yet fill a block.
+	buf  [xxhashBlockSize]byte
+	used int
+}
+
+// newXXHash64 returns an XXH64 hash starting from seed.
+func newXXHash64(seed uint64) *xxhash64 {
+	hasher := &xxhash64{
+		seed:  seed,
+		acc:   [4]uint64{},
+		total: 0,
+		buf:   [xxhashBlockSize]byte{},
+		used:  0,
+	}
+	hasher.Reset()
+
+	return hasher
+}
+
+// Reset implements hash.Hash.
+func (hasher *xxhash64) Reset() {
+	seed := hasher.seed
+
+	hasher.acc = [4]uint64{seed + xxPrime1 + xxPrime2, seed + xxPrime2, seed, seed - xxPrime1}
+	hasher.total = 0
+	hasher.used = 0
+}
+
+// Size implements hash.Hash.
+func (hasher *xxhash64) Size() int {
+	return xxhashSize
+}
+
+// BlockSize implements hash.Hash.
+func (hasher *xxhash64) BlockSize() int {
+	return xxhashBlockSize
+}
+
+// Write implements io.Writer; it never fails.
+func (hasher *xxhash64) Write(input []byte) (int, error) {
+	size := len(input)
+	hasher.total += uint64(size)
+
+	if hasher.used > 0 {
+		filled := copy(hasher.buf[hasher.used:], input)
+		hasher.used += filled
+		input = input[filled:]
+
+		if hasher.used < xxhashBlockSize {
+			return size, nil
+		}
+
+		hasher.block(hasher.buf[:])
+		hasher.used = 0
+	}
+
+	for len(input) >= xxhashBlockSize {
+		hasher.block(input[:xxhashBlockSize])
+		input = input[xxhashBlockSize:]
+	}
+
+	hasher.used = copy(hasher.buf[:], input)
+
+	return size, nil
+}
+
+// Sum64 implements hash.Hash64.
+func (hasher *xxhash64) Sum64() uint64 {
+	var sum uint64
+
+	if hasher.total >= xxhashBlockSize {
+		sum = bits.RotateLeft64(hasher.acc[0], xxRot1) + bits.RotateLeft64(hasher.acc[1], xxRot7) +
+			bits.RotateLeft64(hasher.acc[2], xxRot12) + bits.RotateLeft64(hasher.acc[3], xxRot18)
+
+		for _, lane := range hasher.acc {
+			sum ^= xxRound(0, lane)
+			sum = sum*xxPrime1 + xxPrime4
+		}
+	} else {
+		sum = hasher.seed + xxPrime5
+	}
+
+	sum += hasher.total
+
+	tail := hasher.buf[:hasher.used]
+
+	for ; len(tail) >= xxLaneSize; tail = tail[xxLaneSize:] {
+		sum ^= xxRound(0, binary.LittleEndian.Uint64(tail))
+		sum = bits.RotateLeft64(sum, xxRot27)*xxPrime1 + xxPrime4
+	}
+
+	if len(tail) >= xxHalfLaneSize {
+		sum ^= uint64(binary.LittleEndian.Uint32(tail)) * xxPrime1
+		sum = bits.RotateLeft64(sum, xxRot23)*xxPrime2 + xxPrime3
+		tail = tail[xxHalfLaneSize:]
+	}
+
+	for _, octet := range tail {
+		sum ^= uint64(octet) * xxPrime5
+		sum = bits.RotateLeft64(sum, xxRot11) * xxPrime1
+	}
+
+	sum ^= sum >> xxShift33
+	sum *= xxPrime2
+	sum ^= sum >> xxShift29
+	sum *= xxPrime3
+	sum ^= sum >> xxShift32
+
+	return sum
+}
+
+// Sum implements hash.Hash, appending the big-endian Sum64 to prefix.
+func (hasher *xxhash64) Sum(prefix []byte) []byte {
+	return binary.BigEndian.AppendUint64(prefix, hasher.Sum64())
+}
+
+// block feeds one full block to the accumulators.
+func (hasher *xxhash64) block(input []byte) {
+	for lane := range hasher.acc {
+		word := binary.LittleEndian.Uint64(input[lane*xxLaneSize:])
+		hasher.acc[lane] = xxRound(hasher.acc[lane], word)
+	}
+}
+
+// xxRound mixes one 64-bit lane of input into acc.
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, xxRot31)
+
+	return acc * xxPrime1
+}